@@ -16,20 +16,30 @@ import (
 type Browser interface {
 	// Name is browser's name
 	Name() string
-	// BrowsingData returns all browsing data in the browser.
-	BrowsingData(isFullExport bool) (*browserdata.BrowserData, error)
+	// BrowsingData copies the browser's data files into workDir and returns all browsing data in the browser.
+	BrowsingData(isFullExport bool, workDir string) (*browserdata.BrowserData, error)
 }
 
-// PickBrowsers returns a list of browsers that match the name and profile.
-func PickBrowsers(name, profile string) ([]Browser, error) {
+// PickBrowsers returns a list of browsers that match the name and profile of the options.
+func PickBrowsers(opts Options) ([]Browser, error) {
+	keyOptions, err := opts.chromiumKeyOptions()
+	if err != nil {
+		return nil, err
+	}
+	firefoxKeyOptions, err := opts.firefoxKeyOptions()
+	if err != nil {
+		return nil, err
+	}
+	name, profile := opts.Name, opts.ProfilePath
+	valueLimit := browserdata.ValueLimit{MaxLength: opts.StorageValueLimit, Dir: opts.StorageValueDir}
 	var browsers []Browser
-	clist := pickChromium(name, profile)
+	clist := pickChromium(name, profile, keyOptions, valueLimit)
 	for _, b := range clist {
 		if b != nil {
 			browsers = append(browsers, b)
 		}
 	}
-	flist := pickFirefox(name, profile)
+	flist := pickFirefox(name, profile, firefoxKeyOptions, valueLimit)
 	for _, b := range flist {
 		if b != nil {
 			browsers = append(browsers, b)
@@ -38,7 +48,7 @@ func PickBrowsers(name, profile string) ([]Browser, error) {
 	return browsers, nil
 }
 
-func pickChromium(name, profile string) []Browser {
+func pickChromium(name, profile string, keyOptions chromium.KeyOptions, valueLimit browserdata.ValueLimit) []Browser {
 	var browsers []Browser
 	name = strings.ToLower(name)
 	if name == "all" {
@@ -47,7 +57,7 @@ func pickChromium(name, profile string) []Browser {
 				log.Warnf("find browser failed, profile folder does not exist, browser %s", v.name)
 				continue
 			}
-			multiChromium, err := chromium.New(v.name, v.storage, v.profilePath, v.dataTypes)
+			multiChromium, err := chromium.New(v.name, v.storage, v.profilePath, v.dataTypes, keyOptions, valueLimit)
 			if err != nil {
 				log.Errorf("new chromium error %v", err)
 				continue
@@ -65,7 +75,7 @@ func pickChromium(name, profile string) []Browser {
 		if !fileutil.IsDirExists(filepath.Clean(profile)) {
 			log.Errorf("find browser failed, profile folder does not exist, browser %s", c.name)
 		}
-		chromes, err := chromium.New(c.name, c.storage, profile, c.dataTypes)
+		chromes, err := chromium.New(c.name, c.storage, profile, c.dataTypes, keyOptions, valueLimit)
 		if err != nil {
 			log.Errorf("new chromium error %v", err)
 		}
@@ -77,7 +87,7 @@ func pickChromium(name, profile string) []Browser {
 	return browsers
 }
 
-func pickFirefox(name, profile string) []Browser {
+func pickFirefox(name, profile string, keyOptions firefox.KeyOptions, valueLimit browserdata.ValueLimit) []Browser {
 	var browsers []Browser
 	name = strings.ToLower(name)
 	if name == "all" || name == "firefox" {
@@ -93,7 +103,7 @@ func pickFirefox(name, profile string) []Browser {
 				continue
 			}
 
-			if multiFirefox, err := firefox.New(profile, v.dataTypes); err == nil {
+			if multiFirefox, err := firefox.New(profile, v.dataTypes, keyOptions, valueLimit); err == nil {
 				for _, b := range multiFirefox {
 					log.Warnf("find browser success, browser %s", b.Name())
 					browsers = append(browsers, b)
@@ -55,6 +55,12 @@ var (
 			storage:     braveStorageName,
 			dataTypes:   types.DefaultChromiumTypes,
 		},
+		"yandex": {
+			name:        yandexName,
+			storage:     yandexStorageName,
+			profilePath: yandexProfilePath,
+			dataTypes:   types.DefaultYandexTypes,
+		},
 	}
 	firefoxList = map[string]struct {
 		name        string
@@ -79,6 +85,7 @@ var (
 	chromeBetaProfilePath = homeDir + "/.config/google-chrome-beta/Default/"
 	operaProfilePath      = homeDir + "/.config/opera/Default/"
 	vivaldiProfilePath    = homeDir + "/.config/vivaldi/Default/"
+	yandexProfilePath     = homeDir + "/.config/yandex-browser/Default/"
 )
 
 const (
@@ -89,4 +96,5 @@ const (
 	chromeBetaStorageName = "Chrome Safe Storage"
 	operaStorageName      = "Chromium Safe Storage"
 	vivaldiStorageName    = "Chrome Safe Storage"
+	yandexStorageName     = "Yandex Safe Storage"
 )
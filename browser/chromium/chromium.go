@@ -4,9 +4,12 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/moond4rk/hackbrowserdata/browserdata"
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/crypto/dpapi"
 	"github.com/moond4rk/hackbrowserdata/log"
 	"github.com/moond4rk/hackbrowserdata/types"
 	"github.com/moond4rk/hackbrowserdata/utils/fileutil"
@@ -18,12 +21,40 @@ type Chromium struct {
 	storage     string
 	profilePath string
 	masterKey   []byte
+	keyOptions  KeyOptions
+	valueLimit  browserdata.ValueLimit
 	dataTypes   []types.DataType
 	Paths       map[types.DataType]string
 }
 
+// KeyOptions configures how the master key is obtained, the zero value discovers it from the OS key store.
+type KeyOptions struct {
+	// MasterKey is the key supplied for offline analysis, the key discovery is skipped if it's not empty.
+	MasterKey []byte
+	// SafeStorageOS is the OS of the profile the supplied key comes from, linux or darwin,
+	// it's the OS of this host if empty.
+	SafeStorageOS string
+	// PasswordStore is the Linux key store holding the Safe Storage password, named like
+	// the --password-store switch of Chromium: basic, gnome-libsecret, kwallet, kwallet5, kwallet6.
+	// It's detected from the desktop environment if empty.
+	PasswordStore string
+	// KeyringFile is a GNOME keyring file, eg: ~/.local/share/keyrings/login.keyring, the Safe Storage
+	// password is read from it with KeyringPassword, the login password of the user, instead of the key store.
+	KeyringFile     string
+	KeyringPassword string
+	// DPAPIMasterKeyDir is the Protect/<SID> dir of a Windows user, eg: AppData/Roaming/Microsoft/Protect/S-1-5-21-...,
+	// the master key in Local State of a Windows profile is decrypted offline with the DPAPI master key files
+	// in it and DPAPICredential, on any OS.
+	DPAPIMasterKeyDir string
+	DPAPICredential   dpapi.Credential
+	// KeychainFile is a macOS login.keychain-db, the master key of a macOS profile is derived offline
+	// from the Safe Storage password in it, unlocked with KeychainPassword, the login password of the user.
+	KeychainFile     string
+	KeychainPassword string
+}
+
 // New create instance of Chromium browser, fill item's path if item is existed.
-func New(name, storage, profilePath string, dataTypes []types.DataType) ([]*Chromium, error) {
+func New(name, storage, profilePath string, dataTypes []types.DataType, keyOptions KeyOptions, valueLimit browserdata.ValueLimit) ([]*Chromium, error) {
 	c := &Chromium{
 		name:        name,
 		storage:     storage,
@@ -37,10 +68,13 @@ func New(name, storage, profilePath string, dataTypes []types.DataType) ([]*Chro
 	chromiumList := make([]*Chromium, 0, len(multiDataTypePaths))
 	for user, itemPaths := range multiDataTypePaths {
 		chromiumList = append(chromiumList, &Chromium{
-			name:      fileutil.BrowserName(name, user),
-			dataTypes: typeutil.Keys(itemPaths),
-			Paths:     itemPaths,
-			storage:   storage,
+			name:       fileutil.BrowserName(name, user),
+			dataTypes:  typeutil.Keys(itemPaths),
+			Paths:      itemPaths,
+			storage:    storage,
+			masterKey:  keyOptions.MasterKey,
+			keyOptions: keyOptions,
+			valueLimit: valueLimit,
 		})
 	}
 	return chromiumList, nil
@@ -50,7 +84,7 @@ func (c *Chromium) Name() string {
 	return c.name
 }
 
-func (c *Chromium) BrowsingData(isFullExport bool) (*browserdata.BrowserData, error) {
+func (c *Chromium) BrowsingData(isFullExport bool, workDir string) (*browserdata.BrowserData, error) {
 	// delete chromiumKey from dataTypes, doesn't need to export key
 	var dataTypes []types.DataType
 	for _, dt := range c.dataTypes {
@@ -64,27 +98,63 @@ func (c *Chromium) BrowsingData(isFullExport bool) (*browserdata.BrowserData, er
 	}
 
 	data := browserdata.New(dataTypes)
+	data.SetValueLimit(c.valueLimit)
 
-	if err := c.copyItemToLocal(); err != nil {
+	localPaths, err := c.copyItemToLocal(workDir)
+	if err != nil {
 		return nil, err
 	}
 
-	masterKey, err := c.GetMasterKey()
-	if err != nil {
-		return nil, err
+	var keys crypto.MasterKeys
+	switch {
+	case len(c.masterKey) != 0:
+		log.Debugf("use supplied master key, skip key discovery, browser %s", c.name)
+		keys = c.suppliedMasterKeys()
+	case c.keyOptions.DPAPIMasterKeyDir != "":
+		masterKey, err := c.getDPAPIMasterKey(localPaths[types.ChromiumKey])
+		if err != nil {
+			return nil, err
+		}
+		c.masterKey = masterKey
+		keys = crypto.MasterKeys{V10: masterKey}
+	case c.keyOptions.KeychainFile != "":
+		// the v10 ciphertexts of a macOS profile are encrypted by the keychain key, not the peanuts key of Linux
+		masterKey, err := c.getKeychainMasterKey()
+		if err != nil {
+			return nil, err
+		}
+		c.masterKey = masterKey
+		keys = crypto.MasterKeys{V10: masterKey}
+	default:
+		masterKey, err := c.GetMasterKey(localPaths[types.ChromiumKey])
+		if err != nil {
+			return nil, err
+		}
+		c.masterKey = masterKey
+		keys = masterKeys(masterKey)
 	}
 
-	c.masterKey = masterKey
-	if err := data.Recovery(c.masterKey); err != nil {
+	if err := data.Recovery(keys, localPaths); err != nil {
 		return nil, err
 	}
 
 	return data, nil
 }
 
-func (c *Chromium) copyItemToLocal() error {
-	for i, path := range c.Paths {
-		filename := i.TempFilename()
+// copyItemToLocal copies the item files into workDir, returns the copied path of each item,
+// the items kept in one file share a single copy, eg: the credit cards and addresses in Web Data
+func (c *Chromium) copyItemToLocal(workDir string) (map[types.DataType]string, error) {
+	localPaths := make(map[types.DataType]string, len(c.Paths))
+	copied := make(map[string]string, len(c.Paths))
+	items := typeutil.Keys(c.Paths)
+	sort.Slice(items, func(i, j int) bool { return items[i] < items[j] })
+	for _, i := range items {
+		path := c.Paths[i]
+		if filename, ok := copied[path]; ok {
+			localPaths[i] = filename
+			continue
+		}
+		filename := filepath.Join(workDir, i.TempFilename())
 		var err error
 		switch {
 		case fileutil.IsDirExists(path):
@@ -94,6 +164,15 @@ func (c *Chromium) copyItemToLocal() error {
 			if i == types.ChromiumSessionStorage {
 				err = fileutil.CopyDir(path, filename, "lock")
 			}
+			if i == types.ChromiumSession {
+				err = fileutil.CopyDir(path, filename, "lock")
+			}
+			if i == types.ChromiumIndexedDB {
+				// the blobs of the values stored out of the leveldb aren't read
+				err = fileutil.CopyDir(path, filename, "lock", ".indexeddb.blob")
+			}
+		case i.HasSecondaryFiles():
+			err = copyItemFiles(i, filepath.Dir(path), filename)
 		default:
 			err = fileutil.CopyFile(path, filename)
 		}
@@ -101,8 +180,13 @@ func (c *Chromium) copyItemToLocal() error {
 			log.Errorf("copy item to local, path %s, filename %s err %v", path, filename, err)
 			continue
 		}
+		copied[path] = filename
+		localPaths[i] = filename
 	}
-	return nil
+	if webData, ok := localPaths[types.ChromiumSearchEngine]; ok {
+		copyPreferences(c.Paths[types.ChromiumSearchEngine], webData)
+	}
+	return localPaths, nil
 }
 
 // userDataTypePaths return a map of user to item path, map[profile 1][item's name & path key pair]
@@ -147,7 +231,7 @@ func chromiumWalkFunc(items []types.DataType, multiItemPaths map[string]map[type
 			return err
 		}
 		for _, v := range items {
-			if info.Name() != v.Filename() {
+			if !isItemFile(v, info.Name()) {
 				continue
 			}
 			if strings.Contains(path, "System Profile") {
@@ -160,6 +244,11 @@ func chromiumWalkFunc(items []types.DataType, multiItemPaths map[string]map[type
 			if strings.Contains(filepath.ToSlash(path), "/Network/Cookies") {
 				profileFolder = fileutil.BaseDir(strings.ReplaceAll(filepath.ToSlash(path), "/Network/Cookies", ""))
 			}
+			// an item kept in several files is found by any of them, the primary file is preferred
+			// and the others next to it are copied along
+			if prev, ok := multiItemPaths[profileFolder][v]; ok && v.HasSecondaryFiles() && filepath.Base(prev) == v.Filename() {
+				continue
+			}
 			if _, exist := multiItemPaths[profileFolder]; exist {
 				multiItemPaths[profileFolder][v] = path
 			} else {
@@ -170,6 +259,46 @@ func chromiumWalkFunc(items []types.DataType, multiItemPaths map[string]map[type
 	}
 }
 
+// isItemFile reports whether the file is the item or one of its secondary files
+func isItemFile(item types.DataType, name string) bool {
+	for _, filename := range item.Filenames() {
+		if name == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// copyItemFiles copies the files of the item found in the profile dir into dst,
+// eg: dst/Login Data, dst/Login Data For Account
+func copyItemFiles(item types.DataType, profileDir, dst string) error {
+	if err := os.MkdirAll(dst, 0o700); err != nil {
+		return err
+	}
+	for _, name := range item.Filenames() {
+		src := filepath.Join(profileDir, name)
+		if !fileutil.IsFileExists(src) {
+			continue
+		}
+		if err := fileutil.CopyFile(src, filepath.Join(dst, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyPreferences copies the Preferences next to the Web Data of the profile next to its copy,
+// eg: workDir/Preferences, the search engines are read without it if it's missing
+func copyPreferences(webData, localWebData string) {
+	preferences := filepath.Join(filepath.Dir(webData), types.FileChromiumPreferences)
+	if !fileutil.IsFileExists(preferences) {
+		return
+	}
+	if err := fileutil.CopyFile(preferences, filepath.Join(filepath.Dir(localWebData), types.FileChromiumPreferences)); err != nil {
+		log.Errorf("copy preferences to local, path %s err %v", preferences, err)
+	}
+}
+
 func fillLocalStoragePath(itemPaths map[types.DataType]string, storage types.DataType) {
 	if p, ok := itemPaths[types.ChromiumHistory]; ok {
 		lsp := filepath.Join(filepath.Dir(p), storage.Filename())
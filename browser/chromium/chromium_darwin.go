@@ -4,16 +4,13 @@ package chromium
 
 import (
 	"bytes"
-	"crypto/sha1"
 	"errors"
 	"fmt"
-	"os"
 	"os/exec"
 	"strings"
 
 	"github.com/moond4rk/hackbrowserdata/crypto"
 	"github.com/moond4rk/hackbrowserdata/log"
-	"github.com/moond4rk/hackbrowserdata/types"
 )
 
 var (
@@ -21,9 +18,8 @@ var (
 	errCouldNotFindInKeychain = errors.New("could not be find in keychain")
 )
 
-func (c *Chromium) GetMasterKey() ([]byte, error) {
+func (c *Chromium) GetMasterKey(_ string) ([]byte, error) {
 	// don't need chromium key file for macOS
-	defer os.Remove(types.ChromiumKey.TempFilename())
 	// Get the master key from the keychain
 	// $ security find-generic-password -wa 'Chrome'
 	var (
@@ -47,13 +43,22 @@ func (c *Chromium) GetMasterKey() ([]byte, error) {
 	if len(secret) == 0 {
 		return nil, errWrongSecurityCommand
 	}
-	salt := []byte("saltysalt")
-	// @https://source.chromium.org/chromium/chromium/src/+/master:components/os_crypt/os_crypt_mac.mm;l=157
-	key := crypto.PBKDF2Key(secret, salt, 1003, 16, sha1.New)
-	if key == nil {
-		return nil, errWrongSecurityCommand
+	key, err := DeriveMasterKey(secret)
+	if err != nil {
+		return nil, err
 	}
 	c.masterKey = key
 	log.Debugf("get master key success, browser %s", c.name)
 	return key, nil
 }
+
+// masterKeys returns the keys of a macOS profile, which encrypts the v10 ciphertexts only.
+func masterKeys(key []byte) crypto.MasterKeys {
+	return crypto.MasterKeys{V10: key}
+}
+
+// DeriveMasterKey derives the master key from the Safe Storage password
+// @https://source.chromium.org/chromium/chromium/src/+/master:components/os_crypt/os_crypt_mac.mm;l=157
+func DeriveMasterKey(secret []byte) ([]byte, error) {
+	return deriveMacMasterKey(secret), nil
+}
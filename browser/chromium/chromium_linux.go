@@ -3,74 +3,81 @@
 package chromium
 
 import (
-	"crypto/sha1"
-	"fmt"
-	"os"
+	"errors"
 
 	"github.com/godbus/dbus/v5"
-	keyring "github.com/ppacher/go-dbus-keyring"
 
 	"github.com/moond4rk/hackbrowserdata/crypto"
 	"github.com/moond4rk/hackbrowserdata/log"
-	"github.com/moond4rk/hackbrowserdata/types"
 )
 
-func (c *Chromium) GetMasterKey() ([]byte, error) {
-	// what is d-bus @https://dbus.freedesktop.org/
-	// don't need chromium key file for Linux
-	defer os.Remove(types.ChromiumKey.TempFilename())
-
-	conn, err := dbus.SessionBus()
+// GetMasterKey returns the key derived from the Safe Storage password in the key store,
+// it decrypts the v11 ciphertexts, the v10 ciphertexts are decrypted by the key derived
+// from "peanuts", see masterKeys.
+func (c *Chromium) GetMasterKey(_ string) ([]byte, error) {
+	secret, err := c.safeStoragePassword()
 	if err != nil {
 		return nil, err
 	}
-	svc, err := keyring.GetSecretService(conn)
-	if err != nil {
-		return nil, err
+	if len(secret) == 0 {
+		// set default secret @https://source.chromium.org/chromium/chromium/src/+/main:components/os_crypt/os_crypt_linux.cc;l=100
+		secret = []byte("peanuts")
 	}
-	session, err := svc.OpenSession()
+	key, err := DeriveMasterKey(secret)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		if err := session.Close(); err != nil {
-			log.Errorf("close dbus session error: %v", err)
+	c.masterKey = key
+	log.Debugf("get master key success, browser %s", c.name)
+	return key, nil
+}
+
+// masterKeys returns the keys of a Linux profile, the v10 ciphertexts are decrypted by the key derived
+// from "peanuts" whatever the source of the key is, a 32 bytes key is the key of a Windows profile.
+func masterKeys(key []byte) crypto.MasterKeys {
+	if len(key) == 32 {
+		return crypto.MasterKeys{V10: key}
+	}
+	return crypto.LinuxMasterKeys(key)
+}
+
+// safeStoragePassword returns the Safe Storage password from the supplied keyring file,
+// or from the first key store on the session bus holding it, nil if it's not found.
+func (c *Chromium) safeStoragePassword() ([]byte, error) {
+	if c.keyOptions.KeyringFile != "" {
+		store := keyringFile{path: c.keyOptions.KeyringFile, password: c.keyOptions.KeyringPassword}
+		secret, err := store.safeStoragePassword(c.storage)
+		if errors.Is(err, errPasswordNotFound) {
+			log.Warnf("%s not found in keyring file %s", c.storage, store.path)
+			return nil, nil
 		}
-	}()
-	collections, err := svc.GetAllCollections()
-	if err != nil {
+		return secret, err
+	}
+
+	stores, err := pickPasswordStores(c.keyOptions.PasswordStore)
+	if err != nil || len(stores) == 0 {
 		return nil, err
 	}
-	var secret []byte
-	for _, col := range collections {
-		items, err := col.GetAllItems()
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		log.Warnf("connect session bus error: %v", err)
+		return nil, nil
+	}
+	defer conn.Close()
+	for _, name := range stores {
+		secret, err := busKeyStores[name](conn).safeStoragePassword(c.storage)
 		if err != nil {
-			return nil, err
-		}
-		for _, i := range items {
-			label, err := i.GetLabel()
-			if err != nil {
-				log.Warnf("get label from dbus: %v", err)
-				continue
-			}
-			if label == c.storage {
-				se, err := i.GetSecret(session.Path())
-				if err != nil {
-					return nil, fmt.Errorf("get storage from dbus: %w", err)
-				}
-				secret = se.Value
-			}
+			log.Debugf("get %s from %s error: %v", c.storage, name, err)
+			continue
 		}
+		log.Debugf("get %s from %s success", c.storage, name)
+		return secret, nil
 	}
+	return nil, nil
+}
 
-	if len(secret) == 0 {
-		// set default secret @https://source.chromium.org/chromium/chromium/src/+/main:components/os_crypt/os_crypt_linux.cc;l=100
-		secret = []byte("peanuts")
-	}
-	salt := []byte("saltysalt")
-	// @https://source.chromium.org/chromium/chromium/src/+/master:components/os_crypt/os_crypt_linux.cc
-	key := crypto.PBKDF2Key(secret, salt, 1, 16, sha1.New)
-	c.masterKey = key
-	log.Debugf("get master key success, browser %s", c.name)
-	return key, nil
+// DeriveMasterKey derives the master key from the Safe Storage password
+// @https://source.chromium.org/chromium/chromium/src/+/master:components/os_crypt/os_crypt_linux.cc
+func DeriveMasterKey(secret []byte) ([]byte, error) {
+	return deriveLinuxMasterKey(secret), nil
 }
@@ -0,0 +1,118 @@
+//go:build linux
+
+package chromium
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/browserdata/password"
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/internal/testutil"
+	"github.com/moond4rk/hackbrowserdata/types"
+)
+
+func TestChromium_BrowsingDataKeychainKey(t *testing.T) {
+	dir := t.TempDir()
+	keychainPath := filepath.Join(dir, "login.keychain-db")
+	keychain := newTestKeychain(t, "moond4rk", []keychainItem{
+		{service: "Chrome Safe Storage", account: "Chrome", password: []byte("chrome-secret")},
+	})
+	require.NoError(t, os.WriteFile(keychainPath, keychain, 0o600))
+
+	// the password of a macOS profile is a v10 ciphertext of the keychain key, find one
+	// which also passes the padding check of the peanuts key of Linux
+	key := deriveMacMasterKey([]byte("chrome-secret"))
+	peanutsKey, err := DeriveMasterKey([]byte("peanuts"))
+	require.NoError(t, err)
+	iv := bytes.Repeat([]byte{' '}, 16)
+	var value string
+	var encrypted []byte
+	for i := 0; encrypted == nil; i++ {
+		v := fmt.Sprintf("password-%d", i)
+		ciphertext, err := crypto.AES128CBCEncrypt(key, iv, []byte(v))
+		require.NoError(t, err)
+		if _, err := crypto.AES128CBCDecrypt(peanutsKey, iv, ciphertext); err == nil {
+			value, encrypted = v, append([]byte(crypto.SchemeV10), ciphertext...)
+		}
+	}
+
+	loginPath := filepath.Join(dir, "Default", "Login Data")
+	require.NoError(t, os.MkdirAll(filepath.Dir(loginPath), 0o700))
+	testutil.NewSQLite(t, loginPath, `CREATE TABLE logins (origin_url VARCHAR NOT NULL, username_value VARCHAR,
+		password_value BLOB, date_created INTEGER NOT NULL DEFAULT 0)`)
+	testutil.ExecSQLite(t, loginPath, `INSERT INTO logins VALUES ('https://github.com/login', 'user', ?, 0)`, encrypted)
+
+	c := &Chromium{
+		name:      "chrome",
+		storage:   "Chrome",
+		dataTypes: []types.DataType{types.ChromiumPassword},
+		Paths:     map[types.DataType]string{types.ChromiumPassword: loginPath},
+		keyOptions: KeyOptions{
+			KeychainFile:     keychainPath,
+			KeychainPassword: "moond4rk",
+		},
+	}
+	data, err := c.BrowsingData(true, t.TempDir())
+	require.NoError(t, err)
+	extractors := data.Extractors()
+	require.Len(t, extractors, 1)
+	logins, ok := extractors[0].(*password.ChromiumPassword)
+	require.True(t, ok)
+	require.Len(t, *logins, 1)
+	assert.Equal(t, value, (*logins)[0].Password)
+}
+
+func TestChromium_BrowsingDataSuppliedKey(t *testing.T) {
+	// an offline Linux profile keeps v10 ciphertexts of the peanuts key along with the v11 ones of its keyring key
+	key, err := DeriveMasterKey([]byte("keyring-secret"))
+	require.NoError(t, err)
+	peanutsKey, err := DeriveMasterKey([]byte("peanuts"))
+	require.NoError(t, err)
+	iv := bytes.Repeat([]byte{' '}, 16)
+	encrypt := func(scheme string, key []byte, value string) []byte {
+		ciphertext, err := crypto.AES128CBCEncrypt(key, iv, []byte(value))
+		require.NoError(t, err)
+		return append([]byte(scheme), ciphertext...)
+	}
+
+	loginPath := filepath.Join(t.TempDir(), "Default", "Login Data")
+	require.NoError(t, os.MkdirAll(filepath.Dir(loginPath), 0o700))
+	testutil.NewSQLite(t, loginPath, `CREATE TABLE logins (origin_url VARCHAR NOT NULL, username_value VARCHAR,
+		password_value BLOB, date_created INTEGER NOT NULL DEFAULT 0)`)
+	insert := `INSERT INTO logins VALUES (?, 'user', ?, ?)`
+	testutil.ExecSQLite(t, loginPath, insert, "https://v10.example.com", encrypt(crypto.SchemeV10, peanutsKey, "v10-password"), 3)
+	testutil.ExecSQLite(t, loginPath, insert, "https://v11.example.com", encrypt(crypto.SchemeV11, key, "v11-password"), 2)
+	// the v11 ciphertext of the peanuts key fails, the other key is never tried
+	testutil.ExecSQLite(t, loginPath, insert, "https://wrong.example.com", encrypt(crypto.SchemeV11, peanutsKey, "wrong"), 1)
+
+	c := &Chromium{
+		name:       "chrome",
+		storage:    "Chrome",
+		masterKey:  key,
+		keyOptions: KeyOptions{MasterKey: key},
+		dataTypes:  []types.DataType{types.ChromiumPassword},
+		Paths:      map[types.DataType]string{types.ChromiumPassword: loginPath},
+	}
+	data, err := c.BrowsingData(true, t.TempDir())
+	require.NoError(t, err)
+	extractors := data.Extractors()
+	require.Len(t, extractors, 1)
+	logins, ok := extractors[0].(*password.ChromiumPassword)
+	require.True(t, ok)
+	require.Len(t, *logins, 3)
+	passwords := make(map[string]password.LoginData)
+	for _, login := range *logins {
+		passwords[login.LoginURL] = login
+	}
+	assert.Equal(t, "v10-password", passwords["https://v10.example.com"].Password)
+	assert.Equal(t, crypto.SchemeV10, passwords["https://v10.example.com"].EncryptScheme)
+	assert.Equal(t, "v11-password", passwords["https://v11.example.com"].Password)
+	assert.True(t, passwords["https://wrong.example.com"].DecryptFailed)
+}
@@ -0,0 +1,100 @@
+package chromium
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/types"
+)
+
+func TestChromiumWalkFunc_SecondaryFiles(t *testing.T) {
+	userData := t.TempDir()
+	writeFile := func(path string) string {
+		path = filepath.Join(userData, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(path), 0o600))
+		return path
+	}
+	both := writeFile("Default/Login Data")
+	writeFile("Default/Login Data For Account")
+	accountOnly := writeFile("Profile 1/Login Data For Account")
+
+	items := []types.DataType{types.ChromiumPassword}
+	multiItemPaths := make(map[string]map[types.DataType]string)
+	require.NoError(t, filepath.Walk(userData, chromiumWalkFunc(items, multiItemPaths)))
+	assert.Equal(t, both, multiItemPaths["Default"][types.ChromiumPassword])
+	assert.Equal(t, accountOnly, multiItemPaths["Profile 1"][types.ChromiumPassword])
+
+	c := &Chromium{Paths: multiItemPaths["Default"]}
+	localPaths, err := c.copyItemToLocal(t.TempDir())
+	require.NoError(t, err)
+	dst := localPaths[types.ChromiumPassword]
+	assert.FileExists(t, filepath.Join(dst, "Login Data"))
+	assert.FileExists(t, filepath.Join(dst, "Login Data For Account"))
+
+	c = &Chromium{Paths: multiItemPaths["Profile 1"]}
+	localPaths, err = c.copyItemToLocal(t.TempDir())
+	require.NoError(t, err)
+	dst = localPaths[types.ChromiumPassword]
+	assert.NoFileExists(t, filepath.Join(dst, "Login Data"))
+	assert.FileExists(t, filepath.Join(dst, "Login Data For Account"))
+}
+
+func TestCopyItemToLocal_SharedFile(t *testing.T) {
+	profile := t.TempDir()
+	webData := filepath.Join(profile, types.FileChromiumWebData)
+	require.NoError(t, os.WriteFile(webData, []byte("web data"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(profile, types.FileChromiumPreferences), []byte("{}"), 0o600))
+
+	c := &Chromium{Paths: map[types.DataType]string{
+		types.ChromiumCreditCard:   webData,
+		types.ChromiumAutofill:     webData,
+		types.ChromiumAddress:      webData,
+		types.ChromiumSearchEngine: webData,
+	}}
+	workDir := t.TempDir()
+	localPaths, err := c.copyItemToLocal(workDir)
+	require.NoError(t, err)
+	local := localPaths[types.ChromiumCreditCard]
+	for _, i := range []types.DataType{types.ChromiumAutofill, types.ChromiumAddress, types.ChromiumSearchEngine} {
+		assert.Equal(t, local, localPaths[i])
+	}
+	entries, err := os.ReadDir(workDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.FileExists(t, filepath.Join(filepath.Dir(local), types.FileChromiumPreferences))
+}
+
+func TestDeriveSafeStorageKey(t *testing.T) {
+	key, err := DeriveSafeStorageKey([]byte("peanuts"), SafeStorageOSLinux)
+	require.NoError(t, err)
+	assert.Equal(t, crypto.PBKDF2Key([]byte("peanuts"), []byte("saltysalt"), 1, 16, sha1.New), key)
+
+	key, err = DeriveSafeStorageKey([]byte("peanuts"), SafeStorageOSDarwin)
+	require.NoError(t, err)
+	assert.Equal(t, crypto.PBKDF2Key([]byte("peanuts"), []byte("saltysalt"), 1003, 16, sha1.New), key)
+
+	_, err = DeriveSafeStorageKey([]byte("peanuts"), "windows")
+	assert.Error(t, err)
+}
+
+func TestChromium_SuppliedMasterKeys(t *testing.T) {
+	key := bytes.Repeat([]byte("moond4rk"), 2)
+	c := &Chromium{masterKey: key, keyOptions: KeyOptions{SafeStorageOS: SafeStorageOSLinux}}
+	assert.Equal(t, crypto.LinuxMasterKeys(key), c.suppliedMasterKeys())
+
+	c.keyOptions.SafeStorageOS = SafeStorageOSDarwin
+	assert.Equal(t, crypto.MasterKeys{V10: key}, c.suppliedMasterKeys())
+
+	// a 32 bytes key is the key of a Windows profile
+	key = bytes.Repeat(key, 2)
+	c = &Chromium{masterKey: key, keyOptions: KeyOptions{SafeStorageOS: SafeStorageOSLinux}}
+	assert.Equal(t, crypto.MasterKeys{V10: key}, c.suppliedMasterKeys())
+}
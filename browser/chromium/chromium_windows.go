@@ -3,37 +3,20 @@
 package chromium
 
 import (
-	"encoding/base64"
 	"errors"
-	"os"
-
-	"github.com/tidwall/gjson"
 
 	"github.com/moond4rk/hackbrowserdata/crypto"
 	"github.com/moond4rk/hackbrowserdata/log"
-	"github.com/moond4rk/hackbrowserdata/types"
-	"github.com/moond4rk/hackbrowserdata/utils/fileutil"
 )
 
-var errDecodeMasterKeyFailed = errors.New("decode master key failed")
+var errSafeStorageNotSupported = errors.New("safe storage password is not supported on windows, the master key is protected by DPAPI")
 
-func (c *Chromium) GetMasterKey() ([]byte, error) {
-	b, err := fileutil.ReadFile(types.ChromiumKey.TempFilename())
-	if err != nil {
+func (c *Chromium) GetMasterKey(keyPath string) ([]byte, error) {
+	key, err := localStateEncryptedKey(keyPath)
+	if err != nil || key == nil {
 		return nil, err
 	}
-	defer os.Remove(types.ChromiumKey.TempFilename())
-
-	encryptedKey := gjson.Get(b, "os_crypt.encrypted_key")
-	if !encryptedKey.Exists() {
-		return nil, nil
-	}
-
-	key, err := base64.StdEncoding.DecodeString(encryptedKey.String())
-	if err != nil {
-		return nil, errDecodeMasterKeyFailed
-	}
-	c.masterKey, err = crypto.DecryptWithDPAPI(key[5:])
+	c.masterKey, err = crypto.DecryptWithDPAPI(key)
 	if err != nil {
 		log.Errorf("decrypt master key failed, err %v", err)
 		return nil, err
@@ -41,3 +24,15 @@ func (c *Chromium) GetMasterKey() ([]byte, error) {
 	log.Debugf("get master key success, browser %s", c.name)
 	return c.masterKey, nil
 }
+
+// masterKeys returns the keys of a Windows profile, which encrypts the v10 ciphertexts only.
+func masterKeys(key []byte) crypto.MasterKeys {
+	return crypto.MasterKeys{V10: key}
+}
+
+// DeriveMasterKey is not supported on Windows, the master key is
+// encrypted with DPAPI and stored in Local State instead of being
+// derived from a Safe Storage password.
+func DeriveMasterKey(_ []byte) ([]byte, error) {
+	return nil, errSafeStorageNotSupported
+}
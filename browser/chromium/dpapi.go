@@ -0,0 +1,55 @@
+package chromium
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/moond4rk/hackbrowserdata/crypto/dpapi"
+	"github.com/moond4rk/hackbrowserdata/log"
+	"github.com/moond4rk/hackbrowserdata/utils/fileutil"
+)
+
+var errDecodeMasterKeyFailed = errors.New("decode master key failed")
+
+// dpapiKeyPrefix prefixes the DPAPI blob of os_crypt.encrypted_key in Local State
+const dpapiKeyPrefix = "DPAPI"
+
+// localStateEncryptedKey returns the DPAPI blob of the master key in Local State,
+// it returns nil if Local State has no encrypted key.
+func localStateEncryptedKey(keyPath string) ([]byte, error) {
+	b, err := fileutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey := gjson.Get(b, "os_crypt.encrypted_key")
+	if !encryptedKey.Exists() {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encryptedKey.String())
+	if err != nil || !bytes.HasPrefix(key, []byte(dpapiKeyPrefix)) {
+		return nil, errDecodeMasterKeyFailed
+	}
+	return key[len(dpapiKeyPrefix):], nil
+}
+
+// getDPAPIMasterKey decrypts the master key in Local State of a Windows profile offline,
+// with the DPAPI master key files of the user and the supplied credential.
+func (c *Chromium) getDPAPIMasterKey(keyPath string) ([]byte, error) {
+	blob, err := localStateEncryptedKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if blob == nil {
+		return nil, fmt.Errorf("no encrypted key in local state %s", keyPath)
+	}
+	key, err := dpapi.Unprotect(blob, c.keyOptions.DPAPIMasterKeyDir, c.keyOptions.DPAPICredential)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt master key with dpapi master key files: %w", err)
+	}
+	log.Debugf("get master key from dpapi master key files success, browser %s", c.name)
+	return key, nil
+}
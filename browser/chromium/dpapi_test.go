@@ -0,0 +1,37 @@
+package chromium
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStateEncryptedKey(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		name       string
+		localState string
+		want       []byte
+		wantErr    error
+	}{
+		{name: "dpapi key", localState: `{"os_crypt":{"encrypted_key":"RFBBUEltb29uZDRyaw=="}}`, want: []byte("moond4rk")},
+		{name: "no key", localState: `{"os_crypt":{}}`},
+		{name: "no dpapi prefix", localState: `{"os_crypt":{"encrypted_key":"bW9vbmQ0cms="}}`, wantErr: errDecodeMasterKeyFailed},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keyPath := filepath.Join(dir, tc.name)
+			require.NoError(t, os.WriteFile(keyPath, []byte(tc.localState), 0o600))
+			key, err := localStateEncryptedKey(keyPath)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, key)
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package chromium
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/log"
+)
+
+var (
+	errPasswordNotFound          = errors.New("safe storage password not found")
+	errInvalidKeychain           = errors.New("invalid macOS keychain file")
+	errKeychainPasswordIncorrect = errors.New("keychain password is incorrect")
+)
+
+// the record types of the tables in the keychain
+// @https://opensource.apple.com/source/libsecurity_cssm/libsecurity_cssm-36064/lib/cssmtype.h
+const (
+	keychainGenericPassword = 0x80000000
+	keychainMetadata        = 0x80008000
+	keychainSymmetricKey    = 0x00000011
+)
+
+const (
+	keychainSignature = "kych"
+	// the size of the file header, the offsets of the tables are relative to the end of it
+	keychainHeaderSize = 20
+	tableHeaderSize    = 28
+	// the DB blob follows the header of the metadata table and its record
+	dbBlobOffset          = 0x38
+	dbBlobMagic           = 0xfade0711
+	keyRecordHeaderSize   = 132
+	passwordRecordHeader  = 88
+	keychainKeyLen        = 24
+	keychainKeyIterations = 1000
+)
+
+// keyWrapIV is the IV of the outer layer of the wrapped keys, from the CMS key wrap of RFC 3217
+var keyWrapIV = []byte{0x4a, 0xdd, 0xa2, 0x2c, 0x79, 0xe8, 0x21, 0x05}
+
+// keychainFile reads the Safe Storage password from a macOS login.keychain-db without the security
+// command, the keychain is unlocked with the login password of the user.
+// @https://github.com/n0fate/chainbreaker
+type keychainFile struct {
+	path     string
+	password string
+}
+
+// keychainItem is a decrypted generic password of the keychain, eg: the service Chrome Safe Storage
+// with the account Chrome.
+type keychainItem struct {
+	service  string
+	account  string
+	password []byte
+}
+
+// safeStoragePassword returns the password of the storage, which is the account name in the darwin
+// browser table, eg: Chrome, or the service name in the linux one, eg: Chrome Safe Storage.
+func (k keychainFile) safeStoragePassword(storage string) ([]byte, error) {
+	b, err := os.ReadFile(k.path)
+	if err != nil {
+		return nil, fmt.Errorf("read keychain file: %w", err)
+	}
+	items, err := decryptKeychain(b, []byte(k.password))
+	if err != nil {
+		return nil, err
+	}
+	account := strings.TrimSuffix(storage, " Safe Storage")
+	for _, item := range items {
+		if item.service == storage || (item.service == account+" Safe Storage" && item.account == account) {
+			return item.password, nil
+		}
+	}
+	return nil, errPasswordNotFound
+}
+
+// getKeychainMasterKey derives the master key from the Safe Storage password in the keychain file,
+// with the 1003 iterations of macOS, so a copied macOS profile is decrypted on any OS.
+func (c *Chromium) getKeychainMasterKey() ([]byte, error) {
+	store := keychainFile{path: c.keyOptions.KeychainFile, password: c.keyOptions.KeychainPassword}
+	secret, err := store.safeStoragePassword(c.storage)
+	if err != nil {
+		return nil, fmt.Errorf("get %s from keychain file %s: %w", c.storage, store.path, err)
+	}
+	log.Debugf("get master key from keychain file success, browser %s", c.name)
+	return deriveMacMasterKey(secret), nil
+}
+
+// deriveMacMasterKey derives the master key from the Safe Storage password like macOS
+// @https://source.chromium.org/chromium/chromium/src/+/master:components/os_crypt/os_crypt_mac.mm;l=157
+func deriveMacMasterKey(secret []byte) []byte {
+	return crypto.PBKDF2Key(secret, []byte("saltysalt"), 1003, 16, sha1.New)
+}
+
+// decryptKeychain unlocks the keychain with the password and decrypts its generic passwords:
+//  1. the DB key in the metadata table is decrypted with the key derived from the password
+//  2. the keys in the symmetric key table are unwrapped with the DB key, named by their SSGP label
+//  3. each generic password is decrypted with the key of its SSGP label
+func decryptKeychain(b, password []byte) ([]keychainItem, error) {
+	if !bytes.HasPrefix(b, []byte(keychainSignature)) {
+		return nil, errInvalidKeychain
+	}
+	tables, err := keychainTables(b)
+	if err != nil {
+		return nil, err
+	}
+	metadata, ok := tables[keychainMetadata]
+	if !ok {
+		return nil, fmt.Errorf("%w: no metadata table", errInvalidKeychain)
+	}
+	dbKey, err := decryptDBKey(b, metadata.offset+dbBlobOffset, password)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string][]byte)
+	var unwrapErr error
+	for _, offset := range tables[keychainSymmetricKey].records {
+		label, key, err := unwrapKeyRecord(b, offset, dbKey)
+		if err != nil {
+			log.Debugf("unwrap keychain key at %d error: %v", offset, err)
+			unwrapErr = err
+			continue
+		}
+		keys[string(label)] = key
+	}
+	// a DB key decrypted with a wrong password may pass the padding check, but unwraps no key
+	if len(keys) == 0 && errors.Is(unwrapErr, errKeychainPasswordIncorrect) {
+		return nil, errKeychainPasswordIncorrect
+	}
+
+	var items []keychainItem
+	for _, offset := range tables[keychainGenericPassword].records {
+		item, err := decryptPasswordRecord(b, offset, keys)
+		if err != nil {
+			log.Debugf("decrypt keychain password at %d error: %v", offset, err)
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// keychainTable holds the absolute offsets of a table and its records.
+type keychainTable struct {
+	offset  int
+	records []int
+}
+
+// keychainTables returns the tables by the record type, the file is laid out as:
+//
+//	| signature | version | header size | schema offset | auth offset |
+//	| schema size | table count | table offsets | tables |
+//
+// Each table is | size | type | record count | records | indexes | free list | record numbers count | record offsets |
+func keychainTables(b []byte) (map[uint32]keychainTable, error) {
+	tableCount, err := be32(b, keychainHeaderSize+4)
+	if err != nil {
+		return nil, err
+	}
+	tables := make(map[uint32]keychainTable)
+	for i := 0; i < int(tableCount); i++ {
+		tableOffset, err := be32(b, keychainHeaderSize+8+4*i)
+		if err != nil {
+			return nil, err
+		}
+		offset := keychainHeaderSize + int(tableOffset)
+		tableType, err := be32(b, offset+4)
+		if err != nil {
+			return nil, err
+		}
+		recordCount, err := be32(b, offset+8)
+		if err != nil {
+			return nil, err
+		}
+		table := keychainTable{offset: offset}
+		// deleted records leave zero offsets in the list, read until all records are found
+		for j := offset + tableHeaderSize; len(table.records) < int(recordCount); j += 4 {
+			recordOffset, err := be32(b, j)
+			if err != nil {
+				return nil, err
+			}
+			if recordOffset != 0 && recordOffset%4 == 0 {
+				table.records = append(table.records, offset+int(recordOffset))
+			}
+		}
+		tables[tableType] = table
+	}
+	return tables, nil
+}
+
+// decryptDBKey decrypts the DB key of the DB blob with the key derived from the password:
+//
+//	| magic | version | crypto offset | total length | signature | sequence | idle timeout |
+//	| lock on sleep | salt | iv | blob signature | ... | encrypted DB key |
+func decryptDBKey(b []byte, offset int, password []byte) ([]byte, error) {
+	blob, err := slice(b, offset, offset+92)
+	if err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint32(blob) != dbBlobMagic {
+		return nil, fmt.Errorf("%w: bad db blob magic", errInvalidKeychain)
+	}
+	start := int(binary.BigEndian.Uint32(blob[8:]))
+	total := int(binary.BigEndian.Uint32(blob[12:]))
+	salt, iv := blob[44:64], blob[64:72]
+	encrypted, err := slice(b, offset+start, offset+total)
+	if err != nil {
+		return nil, err
+	}
+	masterKey := crypto.PBKDF2Key(password, salt, keychainKeyIterations, keychainKeyLen, sha1.New)
+	dbKey, err := keychainDecrypt(masterKey, iv, encrypted)
+	if err != nil || len(dbKey) < keychainKeyLen {
+		return nil, errKeychainPasswordIncorrect
+	}
+	return dbKey[:keychainKeyLen], nil
+}
+
+// unwrapKeyRecord returns the SSGP label and the key of the record, the key blob is
+//
+//	| magic | version | crypto offset | total length | iv | ... | wrapped key | ... | label |
+//
+// The key is wrapped twice by 3DES, with the blob IV and then reversed with keyWrapIV.
+func unwrapKeyRecord(b []byte, offset int, dbKey []byte) ([]byte, []byte, error) {
+	recordSize, err := be32(b, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	record, err := slice(b, offset+keyRecordHeaderSize, offset+int(recordSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(record) < 24 {
+		return nil, nil, errInvalidKeychain
+	}
+	start := int(binary.BigEndian.Uint32(record[8:]))
+	total := int(binary.BigEndian.Uint32(record[12:]))
+	iv := record[16:24]
+	label, err := slice(record, total+8, total+28)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.HasPrefix(label, []byte("ssgp")) {
+		return nil, nil, errors.New("not a secure storage key")
+	}
+	wrapped, err := slice(record, start, total)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := keychainDecrypt(dbKey, keyWrapIV, wrapped)
+	if err != nil || len(plaintext) < 32 {
+		return nil, nil, fmt.Errorf("unwrap key: %w", errKeychainPasswordIncorrect)
+	}
+	reversed := make([]byte, 32)
+	for i := range reversed {
+		reversed[i] = plaintext[31-i]
+	}
+	plaintext, err = keychainDecrypt(dbKey, iv, reversed)
+	if err != nil || len(plaintext) != 4+keychainKeyLen {
+		return nil, nil, fmt.Errorf("unwrap key: %w", errKeychainPasswordIncorrect)
+	}
+	return label, plaintext[4:], nil
+}
+
+// decryptPasswordRecord decrypts the generic password record, the header holds the size of
+// the SSGP area and the offsets of the attributes, the SSGP area is | ssgp | label | iv | encrypted password |
+func decryptPasswordRecord(b []byte, offset int, keys map[string][]byte) (keychainItem, error) {
+	header, err := slice(b, offset, offset+passwordRecordHeader)
+	if err != nil {
+		return keychainItem{}, err
+	}
+	field := func(i int) uint32 {
+		return binary.BigEndian.Uint32(header[4*i:])
+	}
+	ssgp, err := slice(b, offset+passwordRecordHeader, offset+passwordRecordHeader+int(field(4)))
+	if err != nil {
+		return keychainItem{}, err
+	}
+	if len(ssgp) < 28 || !bytes.HasPrefix(ssgp, []byte("ssgp")) {
+		return keychainItem{}, errors.New("no secure storage password")
+	}
+	key, ok := keys[string(ssgp[:20])]
+	if !ok {
+		return keychainItem{}, errors.New("no key of the password")
+	}
+	password, err := keychainDecrypt(key, ssgp[20:28], ssgp[28:])
+	if err != nil {
+		return keychainItem{}, err
+	}
+	return keychainItem{
+		account:  keychainAttribute(b, offset, field(19)),
+		service:  keychainAttribute(b, offset, field(20)),
+		password: password,
+	}, nil
+}
+
+// keychainAttribute reads the length prefixed attribute, the lowest bit of the offset is a flag.
+func keychainAttribute(b []byte, recordOffset int, attrOffset uint32) string {
+	attrOffset &^= 1
+	if attrOffset == 0 {
+		return ""
+	}
+	n, err := be32(b, recordOffset+int(attrOffset))
+	if err != nil {
+		return ""
+	}
+	v, err := slice(b, recordOffset+int(attrOffset)+4, recordOffset+int(attrOffset)+4+int(n))
+	if err != nil {
+		return ""
+	}
+	return string(v)
+}
+
+// keychainDecrypt decrypts the ciphertext with 3DES-CBC and checks every padding byte,
+// which tells a wrong key apart more often than crypto.DES3Decrypt.
+func keychainDecrypt(key, iv, ciphertext []byte) ([]byte, error) {
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%des.BlockSize != 0 {
+		return nil, crypto.ErrCiphertextLengthIsInvalid
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	padding := int(plaintext[len(plaintext)-1])
+	if padding < 1 || padding > des.BlockSize {
+		return nil, errors.New("invalid padding")
+	}
+	for _, v := range plaintext[len(plaintext)-padding:] {
+		if int(v) != padding {
+			return nil, errors.New("invalid padding")
+		}
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+func be32(b []byte, offset int) (uint32, error) {
+	v, err := slice(b, offset, offset+4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(v), nil
+}
+
+func slice(b []byte, from, to int) ([]byte, error) {
+	if from < 0 || to < from || to > len(b) {
+		return nil, fmt.Errorf("%w: offset %d out of range", errInvalidKeychain, from)
+	}
+	return b[from:to], nil
+}
@@ -0,0 +1,201 @@
+package chromium
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+)
+
+// keychainWriter writes the big endian fields of a test keychain.
+type keychainWriter struct {
+	bytes.Buffer
+}
+
+func (w *keychainWriter) uint32(v uint32) {
+	_ = binary.Write(w, binary.BigEndian, v)
+}
+
+// table writes a table of the records, the record offsets are relative to the table.
+func (w *keychainWriter) table(tableType uint32, records [][]byte) {
+	offset := tableHeaderSize + 4*len(records)
+	size := offset
+	for _, r := range records {
+		size += len(r)
+	}
+	w.uint32(uint32(size))
+	w.uint32(tableType)
+	w.uint32(uint32(len(records)))
+	w.Write(make([]byte, 12))
+	w.uint32(uint32(len(records)))
+	for _, r := range records {
+		w.uint32(uint32(offset))
+		offset += len(r)
+	}
+	for _, r := range records {
+		w.Write(r)
+	}
+}
+
+// align pads the record to 4 bytes like the keychain does.
+func align(b []byte) []byte {
+	if n := len(b) % 4; n != 0 {
+		b = append(b, make([]byte, 4-n)...)
+	}
+	return b
+}
+
+// newTestKeychain builds a keychain holding the generic passwords of the service and account.
+func newTestKeychain(t *testing.T, password string, items []keychainItem) []byte {
+	t.Helper()
+	salt := bytes.Repeat([]byte{'s'}, 20)
+	dbIV := bytes.Repeat([]byte{'i'}, 8)
+	dbKey := bytes.Repeat([]byte{'d'}, keychainKeyLen)
+
+	// the metadata record holds the DB blob at dbBlobOffset of the table
+	masterKey := crypto.PBKDF2Key([]byte(password), salt, keychainKeyIterations, keychainKeyLen, sha1.New)
+	encryptedDBKey, err := crypto.DES3Encrypt(masterKey, dbIV, dbKey)
+	require.NoError(t, err)
+	var metadata keychainWriter
+	metadata.Write(make([]byte, dbBlobOffset-tableHeaderSize-4))
+	metadata.uint32(dbBlobMagic)
+	metadata.uint32(0x100)
+	metadata.uint32(92)
+	metadata.uint32(uint32(92 + len(encryptedDBKey)))
+	metadata.Write(make([]byte, 28))
+	metadata.Write(salt)
+	metadata.Write(dbIV)
+	metadata.Write(make([]byte, 20))
+	metadata.Write(encryptedDBKey)
+
+	var keyRecords, passwordRecords [][]byte
+	for i, item := range items {
+		label := append([]byte("ssgp"), bytes.Repeat([]byte{byte('a' + i)}, 16)...)
+		itemKey := bytes.Repeat([]byte{byte('A' + i)}, keychainKeyLen)
+		blobIV := bytes.Repeat([]byte{byte('0' + i)}, 8)
+
+		inner, err := crypto.DES3Encrypt(dbKey, blobIV, append([]byte{0, 0, 0, 0}, itemKey...))
+		require.NoError(t, err)
+		reversed := make([]byte, len(inner))
+		for j := range inner {
+			reversed[j] = inner[len(inner)-1-j]
+		}
+		wrapped, err := crypto.DES3Encrypt(dbKey, keyWrapIV, reversed)
+		require.NoError(t, err)
+		var blob keychainWriter
+		blob.uint32(0xfade0711)
+		blob.uint32(0x100)
+		blob.uint32(24)
+		blob.uint32(uint32(24 + len(wrapped)))
+		blob.Write(blobIV)
+		blob.Write(wrapped)
+		blob.Write(make([]byte, 8))
+		blob.Write(label)
+		var key keychainWriter
+		key.uint32(uint32(keyRecordHeaderSize + blob.Len()))
+		key.Write(make([]byte, keyRecordHeaderSize-4))
+		key.Write(blob.Bytes())
+		keyRecords = append(keyRecords, key.Bytes())
+
+		encrypted, err := crypto.DES3Encrypt(itemKey, blobIV, item.password)
+		require.NoError(t, err)
+		ssgp := append(append(append([]byte{}, label...), blobIV...), encrypted...)
+		attributes := func(s string) []byte {
+			var w keychainWriter
+			w.uint32(uint32(len(s)))
+			w.WriteString(s)
+			return align(w.Bytes())
+		}
+		account, service := attributes(item.account), attributes(item.service)
+		header := make([]uint32, passwordRecordHeader/4)
+		header[4] = uint32(len(ssgp))
+		header[19] = uint32(passwordRecordHeader+len(align(ssgp))) | 1
+		header[20] = header[19] - 1 + uint32(len(account)) | 1
+		header[0] = header[20] - 1 + uint32(len(service))
+		var record keychainWriter
+		for _, v := range header {
+			record.uint32(v)
+		}
+		record.Write(align(ssgp))
+		record.Write(account)
+		record.Write(service)
+		passwordRecords = append(passwordRecords, record.Bytes())
+	}
+
+	var tables [3]keychainWriter
+	tables[0].table(keychainMetadata, [][]byte{metadata.Bytes()})
+	tables[1].table(keychainSymmetricKey, keyRecords)
+	tables[2].table(keychainGenericPassword, passwordRecords)
+
+	var w keychainWriter
+	w.WriteString(keychainSignature)
+	w.uint32(0x10000)
+	w.uint32(0x10)
+	w.uint32(keychainHeaderSize)
+	w.uint32(0)
+	w.uint32(0) // schema size
+	w.uint32(uint32(len(tables)))
+	offset := 8 + 4*len(tables)
+	for _, table := range tables {
+		w.uint32(uint32(offset))
+		offset += table.Len()
+	}
+	for _, table := range tables {
+		w.Write(table.Bytes())
+	}
+	return w.Bytes()
+}
+
+func TestGetKeychainMasterKey(t *testing.T) {
+	keychainPath := filepath.Join(t.TempDir(), "login.keychain-db")
+	keychain := newTestKeychain(t, "moond4rk", []keychainItem{
+		{service: "Chrome Safe Storage", account: "Chrome", password: []byte("chrome-secret")},
+		{service: "Microsoft Edge Safe Storage", account: "Microsoft Edge", password: []byte("edge-secret")},
+	})
+	require.NoError(t, os.WriteFile(keychainPath, keychain, 0o600))
+
+	testCases := []struct {
+		name     string
+		storage  string
+		password string
+		secret   string
+		wantErr  error
+	}{
+		{name: "darwin storage", storage: "Chrome", password: "moond4rk", secret: "chrome-secret"},
+		{name: "linux storage", storage: "Chrome Safe Storage", password: "moond4rk", secret: "chrome-secret"},
+		{name: "edge", storage: "Microsoft Edge", password: "moond4rk", secret: "edge-secret"},
+		{name: "not found", storage: "Brave", password: "moond4rk", wantErr: errPasswordNotFound},
+		{name: "incorrect password", storage: "Chrome", password: "wrong", wantErr: errKeychainPasswordIncorrect},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Chromium{name: tc.name, storage: tc.storage, keyOptions: KeyOptions{
+				KeychainFile:     keychainPath,
+				KeychainPassword: tc.password,
+			}}
+			key, err := c.getKeychainMasterKey()
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, crypto.PBKDF2Key([]byte(tc.secret), []byte("saltysalt"), 1003, 16, sha1.New), key)
+		})
+	}
+}
+
+func TestDecryptKeychain_Invalid(t *testing.T) {
+	_, err := decryptKeychain([]byte("bplist00"), nil)
+	assert.ErrorIs(t, err, errInvalidKeychain)
+
+	keychain := newTestKeychain(t, "moond4rk", nil)
+	_, err = decryptKeychain(keychain[:200], []byte("moond4rk"))
+	assert.ErrorIs(t, err, errInvalidKeychain)
+}
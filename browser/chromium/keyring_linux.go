@@ -0,0 +1,197 @@
+//go:build linux
+
+package chromium
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// keyringFile reads the password from a GNOME keyring file without a running session,
+// the keyring is encrypted with the login password of the user.
+// @https://gitlab.gnome.org/GNOME/gnome-keyring/-/blob/main/pkcs11/secret-store/gkm-secret-binary.c
+type keyringFile struct {
+	path     string
+	password string
+}
+
+var (
+	errInvalidKeyring           = errors.New("invalid gnome keyring file")
+	errKeyringPasswordIncorrect = errors.New("keyring password is incorrect")
+)
+
+func (k keyringFile) safeStoragePassword(storage string) ([]byte, error) {
+	b, err := os.ReadFile(k.path)
+	if err != nil {
+		return nil, fmt.Errorf("read keyring file: %w", err)
+	}
+	items, err := decryptKeyring(b, []byte(k.password))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.displayName == storage {
+			return item.secret, nil
+		}
+	}
+	return nil, errPasswordNotFound
+}
+
+const keyringMagic = "GnomeKeyring\n\r\x00\n"
+
+// keyringItem is a decrypted item of the keyring, the display name is the label of the secret.
+type keyringItem struct {
+	displayName string
+	secret      []byte
+}
+
+// decryptKeyring decrypts the items of a GNOME keyring file with the password, the file is laid out as:
+//
+//	| magic | version | crypto | hash | name | ctime | mtime | flags | lock timeout | hash iterations |
+//	| salt | reserved | items with hashed attributes | encrypted length | encrypted items |
+func decryptKeyring(b, password []byte) ([]keyringItem, error) {
+	r := &keyringReader{b: b}
+	if !bytes.HasPrefix(b, []byte(keyringMagic)) {
+		return nil, errInvalidKeyring
+	}
+	r.skip(len(keyringMagic))
+	// major and minor version, crypto and hash algorithms, only AES and SHA256 are defined
+	if version := r.bytes(4); !bytes.Equal(version, []byte{0, 0, 0, 0}) {
+		return nil, fmt.Errorf("%w: unsupported version %x", errInvalidKeyring, version)
+	}
+	r.string() // keyring name
+	r.skip(8)  // ctime
+	r.skip(8)  // mtime
+	r.uint32() // flags
+	r.uint32() // lock timeout
+	iterations := r.uint32()
+	salt := r.bytes(8)
+	r.skip(16) // reserved
+	numItems := r.uint32()
+	for i := uint32(0); i < numItems && r.err == nil; i++ {
+		r.uint32() // id
+		r.uint32() // type
+		r.attributes()
+	}
+	encrypted := r.bytes(int(r.uint32()))
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(encrypted) == 0 || len(encrypted)%aes.BlockSize != 0 {
+		return nil, errInvalidKeyring
+	}
+
+	key, iv := deriveKeyringKey(password, salt, int(iterations))
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, encrypted)
+	// the decrypted data starts with the MD5 of the rest, a wrong password fails the check
+	hash := md5.Sum(decrypted[md5.Size:])
+	if !bytes.Equal(hash[:], decrypted[:md5.Size]) {
+		return nil, errKeyringPasswordIncorrect
+	}
+
+	r = &keyringReader{b: decrypted[md5.Size:]}
+	items := make([]keyringItem, 0, numItems)
+	for i := uint32(0); i < numItems && r.err == nil; i++ {
+		item := keyringItem{displayName: r.string()}
+		item.secret = []byte(r.string())
+		r.skip(8)  // ctime
+		r.skip(8)  // mtime
+		r.string() // reserved
+		r.skip(16) // reserved
+		r.attributes()
+		numACL := r.uint32()
+		for j := uint32(0); j < numACL && r.err == nil; j++ {
+			r.uint32() // allowed types
+			r.string() // display name
+			r.string() // path name
+			r.string() // reserved
+			r.uint32() // reserved
+		}
+		items = append(items, item)
+	}
+	return items, r.err
+}
+
+// deriveKeyringKey derives the AES-128 key and IV from the password and salt,
+// by iterated SHA256 like egg_symkey_generate_simple of gnome-keyring.
+func deriveKeyringKey(password, salt []byte, iterations int) ([]byte, []byte) {
+	h := sha256.New()
+	h.Write(password)
+	h.Write(salt)
+	digest := h.Sum(nil)
+	for i := 1; i < iterations; i++ {
+		sum := sha256.Sum256(digest)
+		digest = sum[:]
+	}
+	// a single SHA256 digest fills both the 16 bytes key and the 16 bytes IV
+	return digest[:16], digest[16:32]
+}
+
+// keyringReader reads the big endian fields of the keyring, the first error is kept in err.
+type keyringReader struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (r *keyringReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.off+n > len(r.b) {
+		r.err = errInvalidKeyring
+		return nil
+	}
+	b := r.b[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *keyringReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *keyringReader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// string reads a length prefixed string, the length 0xffffffff is a NULL string.
+func (r *keyringReader) string() string {
+	n := r.uint32()
+	if n == 0xffffffff {
+		return ""
+	}
+	return string(r.bytes(int(n)))
+}
+
+// attributes skips the attributes of an item, the value is a string or an uint32 by the type.
+func (r *keyringReader) attributes() {
+	n := r.uint32()
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		r.string() // name
+		switch r.uint32() {
+		case 0:
+			r.string()
+		case 1:
+			r.uint32()
+		default:
+			r.err = errInvalidKeyring
+		}
+	}
+}
@@ -0,0 +1,145 @@
+//go:build linux
+
+package chromium
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keyringWriter writes the big endian fields of a test keyring.
+type keyringWriter struct {
+	bytes.Buffer
+}
+
+func (w *keyringWriter) uint32(v uint32) {
+	_ = binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *keyringWriter) string(s string) {
+	w.uint32(uint32(len(s)))
+	w.WriteString(s)
+}
+
+// attributes writes a string and an uint32 attribute, chromium stores the application name.
+func (w *keyringWriter) attributes(application string) {
+	w.uint32(2)
+	w.string("application")
+	w.uint32(0)
+	w.string(application)
+	w.string("version")
+	w.uint32(1)
+	w.uint32(11)
+}
+
+// newTestKeyring builds a GNOME keyring file holding the secrets by the display name.
+func newTestKeyring(t *testing.T, password string, secrets [][2]string) []byte {
+	t.Helper()
+	salt := []byte("saltsalt")
+	const iterations = 1953
+
+	var w keyringWriter
+	w.WriteString(keyringMagic)
+	w.Write([]byte{0, 0, 0, 0})
+	w.string("login")
+	w.Write(make([]byte, 16)) // ctime, mtime
+	w.uint32(0)               // flags
+	w.uint32(0)               // lock timeout
+	w.uint32(iterations)
+	w.Write(salt)
+	w.Write(make([]byte, 16))
+	w.uint32(uint32(len(secrets)))
+	for i, secret := range secrets {
+		w.uint32(uint32(i + 1))
+		w.uint32(0)
+		w.attributes(secret[0])
+	}
+
+	var items keyringWriter
+	for _, secret := range secrets {
+		items.string(secret[0])
+		items.string(secret[1])
+		items.Write(make([]byte, 16)) // ctime, mtime
+		items.uint32(0xffffffff)      // reserved NULL string
+		items.Write(make([]byte, 16))
+		items.attributes(secret[0])
+		items.uint32(1)
+		items.uint32(3)
+		items.string("chrome")
+		items.string("/opt/google/chrome/chrome")
+		items.string("")
+		items.uint32(0)
+	}
+	plaintext := items.Bytes()
+	if n := (md5.Size + len(plaintext)) % aes.BlockSize; n != 0 {
+		plaintext = append(plaintext, make([]byte, aes.BlockSize-n)...)
+	}
+	hash := md5.Sum(plaintext)
+	plaintext = append(hash[:], plaintext...)
+
+	key, iv := deriveKeyringKey([]byte(password), salt, iterations)
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	encrypted := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, plaintext)
+	w.uint32(uint32(len(encrypted)))
+	w.Write(encrypted)
+	return w.Bytes()
+}
+
+func TestGetMasterKey_KeyringFile(t *testing.T) {
+	keyringPath := filepath.Join(t.TempDir(), "login.keyring")
+	keyring := newTestKeyring(t, "moond4rk", [][2]string{
+		{"Chromium Safe Storage", "chromium-secret"},
+		{"Chrome Safe Storage", "chrome-secret"},
+	})
+	require.NoError(t, os.WriteFile(keyringPath, keyring, 0o600))
+
+	testCases := []struct {
+		name     string
+		storage  string
+		password string
+		secret   string
+		wantErr  error
+	}{
+		{name: "chrome", storage: "Chrome Safe Storage", password: "moond4rk", secret: "chrome-secret"},
+		{name: "chromium", storage: "Chromium Safe Storage", password: "moond4rk", secret: "chromium-secret"},
+		{name: "not found", storage: "Brave Safe Storage", password: "moond4rk", secret: "peanuts"},
+		{name: "incorrect password", storage: "Chrome Safe Storage", password: "wrong", wantErr: errKeyringPasswordIncorrect},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Chromium{name: tc.name, storage: tc.storage, keyOptions: KeyOptions{
+				KeyringFile:     keyringPath,
+				KeyringPassword: tc.password,
+			}}
+			key, err := c.GetMasterKey("")
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			want, err := DeriveMasterKey([]byte(tc.secret))
+			require.NoError(t, err)
+			assert.Equal(t, want, key)
+		})
+	}
+}
+
+func TestDecryptKeyring_Invalid(t *testing.T) {
+	_, err := decryptKeyring([]byte("[keyring]\ndisplay-name=login\n"), nil)
+	assert.ErrorIs(t, err, errInvalidKeyring)
+
+	keyring := newTestKeyring(t, "moond4rk", [][2]string{{"Chrome Safe Storage", "chrome-secret"}})
+	_, err = decryptKeyring(keyring[:len(keyring)-8], []byte("moond4rk"))
+	assert.ErrorIs(t, err, errInvalidKeyring)
+}
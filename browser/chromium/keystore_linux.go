@@ -0,0 +1,142 @@
+//go:build linux
+
+package chromium
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/moond4rk/hackbrowserdata/internal/keystore"
+	"github.com/moond4rk/hackbrowserdata/log"
+)
+
+// keyStore reads the Safe Storage password of the browser from a key store,
+// storage is the name of the password, eg: Chrome Safe Storage
+type keyStore interface {
+	safeStoragePassword(storage string) ([]byte, error)
+}
+
+// password stores named like the --password-store switch of Chromium
+const (
+	passwordStoreBasic          = "basic"
+	passwordStoreGnomeLibsecret = "gnome-libsecret"
+	passwordStoreKWallet        = "kwallet"
+	passwordStoreKWallet5       = "kwallet5"
+	passwordStoreKWallet6       = "kwallet6"
+)
+
+// busKeyStores holds the key stores on the session bus by the password store name.
+var busKeyStores = map[string]func(conn *dbus.Conn) keyStore{
+	passwordStoreGnomeLibsecret: func(conn *dbus.Conn) keyStore {
+		return secretService{conn: conn}
+	},
+	passwordStoreKWallet5: func(conn *dbus.Conn) keyStore {
+		return kwallet{conn: conn, service: "org.kde.kwalletd5", path: "/modules/kwalletd5"}
+	},
+	passwordStoreKWallet6: func(conn *dbus.Conn) keyStore {
+		return kwallet{conn: conn, service: "org.kde.kwalletd6", path: "/modules/kwalletd6"}
+	},
+}
+
+var errUnknownPasswordStore = errors.New("unknown password store")
+
+// pickPasswordStores returns the names of the key stores to query in order, the
+// password store is detected from the desktop environment like Chromium if it's empty.
+// The basic store keeps no password, the "peanuts" password is used instead.
+func pickPasswordStores(passwordStore string) ([]string, error) {
+	switch passwordStore {
+	case "":
+		if isKDE() {
+			return []string{passwordStoreKWallet6, passwordStoreKWallet5, passwordStoreGnomeLibsecret}, nil
+		}
+		return []string{passwordStoreGnomeLibsecret}, nil
+	case passwordStoreBasic:
+		return nil, nil
+	case passwordStoreKWallet:
+		return []string{passwordStoreKWallet6, passwordStoreKWallet5}, nil
+	}
+	if _, ok := busKeyStores[passwordStore]; ok {
+		return []string{passwordStore}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", errUnknownPasswordStore, passwordStore)
+}
+
+// isKDE reports whether the current desktop environment is KDE, where Chromium uses KWallet.
+func isKDE() bool {
+	for _, desktop := range strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":") {
+		if strings.EqualFold(desktop, "KDE") {
+			return true
+		}
+	}
+	return os.Getenv("KDE_FULL_SESSION") == "true"
+}
+
+// secretService reads the password from the freedesktop Secret Service, eg: GNOME Keyring
+// what is d-bus @https://dbus.freedesktop.org/
+type secretService struct {
+	conn *dbus.Conn
+}
+
+func (s secretService) safeStoragePassword(storage string) ([]byte, error) {
+	secret, err := keystore.SecretServiceSecret(s.conn, storage)
+	if errors.Is(err, keystore.ErrSecretNotFound) {
+		return nil, errPasswordNotFound
+	}
+	return secret, err
+}
+
+const (
+	kwalletInterface = "org.kde.KWallet"
+	kwalletAppID     = "hack-browser-data"
+)
+
+// kwallet reads the password from the network wallet of KDE Wallet,
+// Chromium keeps it in the folder of the browser, eg: Chrome Safe Storage in Chrome Keys
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/os_crypt/sync/key_storage_kwallet.cc
+type kwallet struct {
+	conn    *dbus.Conn
+	service string
+	path    dbus.ObjectPath
+}
+
+func (k kwallet) safeStoragePassword(storage string) ([]byte, error) {
+	obj := k.conn.Object(k.service, k.path)
+
+	var wallet string
+	if err := obj.Call(kwalletInterface+".networkWallet", 0).Store(&wallet); err != nil {
+		return nil, fmt.Errorf("get network wallet: %w", err)
+	}
+	var handle int32
+	if err := obj.Call(kwalletInterface+".open", 0, wallet, int64(0), kwalletAppID).Store(&handle); err != nil {
+		return nil, fmt.Errorf("open wallet %s: %w", wallet, err)
+	}
+	if handle < 0 {
+		return nil, fmt.Errorf("open wallet %s: access denied", wallet)
+	}
+	defer func() {
+		if call := obj.Call(kwalletInterface+".close", 0, handle, false, kwalletAppID); call.Err != nil {
+			log.Errorf("close wallet %s error: %v", wallet, call.Err)
+		}
+	}()
+
+	folder := strings.TrimSuffix(storage, " Safe Storage") + " Keys"
+	var hasFolder bool
+	if err := obj.Call(kwalletInterface+".hasFolder", 0, handle, folder, kwalletAppID).Store(&hasFolder); err != nil {
+		return nil, fmt.Errorf("find folder %s: %w", folder, err)
+	}
+	if !hasFolder {
+		return nil, errPasswordNotFound
+	}
+	var password string
+	if err := obj.Call(kwalletInterface+".readPassword", 0, handle, folder, storage, kwalletAppID).Store(&password); err != nil {
+		return nil, fmt.Errorf("read password %s: %w", storage, err)
+	}
+	if password == "" {
+		return nil, errPasswordNotFound
+	}
+	return []byte(password), nil
+}
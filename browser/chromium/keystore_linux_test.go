@@ -0,0 +1,140 @@
+//go:build linux
+
+package chromium
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startTestSessionBus starts a private session bus and points DBUS_SESSION_BUS_ADDRESS to it.
+func startTestSessionBus(t *testing.T) {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	cmd := exec.Command(daemon, "--session", "--nofork", "--address=unix:dir="+t.TempDir(), "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	require.NoError(t, err)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", strings.TrimSpace(address))
+}
+
+// fakeWallet implements the KWallet methods used to read the Safe Storage password.
+type fakeWallet struct {
+	folders map[string]map[string]string
+}
+
+func (w *fakeWallet) NetworkWallet() (string, *dbus.Error) {
+	return "kdewallet", nil
+}
+
+func (w *fakeWallet) Open(wallet string, _ int64, _ string) (int32, *dbus.Error) {
+	if wallet != "kdewallet" {
+		return -1, nil
+	}
+	return 1, nil
+}
+
+func (w *fakeWallet) HasFolder(_ int32, folder, _ string) (bool, *dbus.Error) {
+	_, ok := w.folders[folder]
+	return ok, nil
+}
+
+func (w *fakeWallet) ReadPassword(_ int32, folder, key, _ string) (string, *dbus.Error) {
+	return w.folders[folder][key], nil
+}
+
+func (w *fakeWallet) Close(_ int32, _ bool, _ string) (int32, *dbus.Error) {
+	return 0, nil
+}
+
+// serveFakeWallet exports the wallet on the session bus as the kwallet service.
+func serveFakeWallet(t *testing.T, service string, path dbus.ObjectPath, wallet *fakeWallet) {
+	t.Helper()
+	conn, err := dbus.ConnectSessionBus()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	methods := map[string]string{
+		"NetworkWallet": "networkWallet",
+		"Open":          "open",
+		"HasFolder":     "hasFolder",
+		"ReadPassword":  "readPassword",
+		"Close":         "close",
+	}
+	require.NoError(t, conn.ExportWithMap(wallet, methods, path, kwalletInterface))
+	reply, err := conn.RequestName(service, dbus.NameFlagDoNotQueue)
+	require.NoError(t, err)
+	require.Equal(t, dbus.RequestNameReplyPrimaryOwner, reply)
+}
+
+func TestGetMasterKey_KWallet(t *testing.T) {
+	startTestSessionBus(t)
+	wallet := &fakeWallet{folders: map[string]map[string]string{
+		"Chrome Keys":   {"Chrome Safe Storage": "chrome-secret"},
+		"Chromium Keys": {"Chromium Safe Storage": "chromium-secret"},
+	}}
+	// serve both versions, the bus would activate the real wallet if it's installed
+	serveFakeWallet(t, "org.kde.kwalletd5", "/modules/kwalletd5", wallet)
+	serveFakeWallet(t, "org.kde.kwalletd6", "/modules/kwalletd6", wallet)
+
+	testCases := []struct {
+		name          string
+		storage       string
+		passwordStore string
+		desktop       string
+		secret        string
+	}{
+		{name: "chrome", storage: "Chrome Safe Storage", passwordStore: passwordStoreKWallet5, secret: "chrome-secret"},
+		{name: "chromium", storage: "Chromium Safe Storage", passwordStore: passwordStoreKWallet, secret: "chromium-secret"},
+		{name: "detected on kde", storage: "Chrome Safe Storage", desktop: "KDE", secret: "chrome-secret"},
+		{name: "missing folder", storage: "Brave Safe Storage", passwordStore: passwordStoreKWallet5, secret: "peanuts"},
+		{name: "basic", storage: "Chrome Safe Storage", passwordStore: passwordStoreBasic, secret: "peanuts"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("XDG_CURRENT_DESKTOP", tc.desktop)
+			t.Setenv("KDE_FULL_SESSION", "")
+			c := &Chromium{name: tc.name, storage: tc.storage, keyOptions: KeyOptions{PasswordStore: tc.passwordStore}}
+			key, err := c.GetMasterKey("")
+			require.NoError(t, err)
+			want, err := DeriveMasterKey([]byte(tc.secret))
+			require.NoError(t, err)
+			assert.Equal(t, want, key)
+		})
+	}
+}
+
+func TestPickPasswordStores(t *testing.T) {
+	t.Setenv("KDE_FULL_SESSION", "")
+	t.Setenv("XDG_CURRENT_DESKTOP", "GNOME")
+	stores, err := pickPasswordStores("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{passwordStoreGnomeLibsecret}, stores)
+
+	t.Setenv("XDG_CURRENT_DESKTOP", "ubuntu:KDE")
+	stores, err = pickPasswordStores("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{passwordStoreKWallet6, passwordStoreKWallet5, passwordStoreGnomeLibsecret}, stores)
+
+	stores, err = pickPasswordStores(passwordStoreBasic)
+	require.NoError(t, err)
+	assert.Empty(t, stores)
+
+	_, err = pickPasswordStores("kwallet4")
+	assert.ErrorIs(t, err, errUnknownPasswordStore)
+}
@@ -0,0 +1,50 @@
+package chromium
+
+import (
+	"crypto/sha1"
+	"fmt"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+)
+
+// the OS of the profile the supplied Safe Storage password or master key comes from, the password of
+// Linux is derived with 1 iteration and the one of macOS with 1003, the v10 ciphertexts of Linux are
+// encrypted by the key derived from "peanuts" instead of the supplied key.
+const (
+	SafeStorageOSLinux  = "linux"
+	SafeStorageOSDarwin = "darwin"
+)
+
+// DeriveSafeStorageKey derives the master key from the Safe Storage password of a profile of the OS,
+// an empty OS is the OS of this host.
+func DeriveSafeStorageKey(secret []byte, profileOS string) ([]byte, error) {
+	switch profileOS {
+	case "":
+		return DeriveMasterKey(secret)
+	case SafeStorageOSLinux:
+		return deriveLinuxMasterKey(secret), nil
+	case SafeStorageOSDarwin:
+		return deriveMacMasterKey(secret), nil
+	default:
+		return nil, fmt.Errorf("unknown safe storage os %s", profileOS)
+	}
+}
+
+// deriveLinuxMasterKey derives the master key from the Safe Storage password like Linux
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/os_crypt/sync/os_crypt_linux.cc
+func deriveLinuxMasterKey(secret []byte) []byte {
+	return crypto.PBKDF2Key(secret, []byte("saltysalt"), 1, 16, sha1.New)
+}
+
+// suppliedMasterKeys returns the keys of the supplied master key by the OS of the profile, a 32 bytes
+// key is the key of a Windows profile.
+func (c *Chromium) suppliedMasterKeys() crypto.MasterKeys {
+	switch {
+	case len(c.masterKey) == 32, c.keyOptions.SafeStorageOS == SafeStorageOSDarwin:
+		return crypto.MasterKeys{V10: c.masterKey}
+	case c.keyOptions.SafeStorageOS == SafeStorageOSLinux:
+		return crypto.LinuxMasterKeys(c.masterKey)
+	default:
+		return masterKeys(c.masterKey)
+	}
+}
@@ -24,38 +24,79 @@ type Firefox struct {
 	storage     string
 	profilePath string
 	masterKey   []byte
+	keyOptions  KeyOptions
+	valueLimit  browserdata.ValueLimit
 	items       []types.DataType
 	itemPaths   map[types.DataType]string
 }
 
-var ErrProfilePathNotFound = errors.New("profile path not found")
+// KeyOptions configures how the keys of the profile are obtained.
+type KeyOptions struct {
+	// PrimaryPassword is the primary (master) password of the profile, empty if the profile doesn't set one.
+	PrimaryPassword string
+	// OSKeyStoreKey is the 32 bytes key of the OS key store encrypting the credit card numbers,
+	// it's read from the Secret Service on Linux if empty.
+	OSKeyStoreKey []byte
+}
+
+var (
+	ErrProfilePathNotFound      = errors.New("profile path not found")
+	ErrPrimaryPasswordRequired  = errors.New("profile is protected by a primary password, supply it to decrypt")
+	ErrPrimaryPasswordIncorrect = errors.New("primary password is incorrect")
+)
 
 // New returns new Firefox instances.
-func New(profilePath string, items []types.DataType) ([]*Firefox, error) {
+func New(profilePath string, items []types.DataType, keyOptions KeyOptions, valueLimit browserdata.ValueLimit) ([]*Firefox, error) {
 	multiItemPaths := make(map[string]map[types.DataType]string)
 	// ignore walk dir error since it can be produced by a single entry
 	_ = filepath.WalkDir(profilePath, firefoxWalkFunc(items, multiItemPaths))
 
 	firefoxList := make([]*Firefox, 0, len(multiItemPaths))
 	for name, itemPaths := range multiItemPaths {
+		dropMigratedItems(itemPaths)
 		firefoxList = append(firefoxList, &Firefox{
-			name:      fmt.Sprintf("firefox-%s", name),
-			items:     typeutil.Keys(itemPaths),
-			itemPaths: itemPaths,
+			name:       fmt.Sprintf("firefox-%s", name),
+			items:      typeutil.Keys(itemPaths),
+			itemPaths:  itemPaths,
+			keyOptions: keyOptions,
+			valueLimit: valueLimit,
 		})
 	}
 
 	return firefoxList, nil
 }
 
-func (f *Firefox) copyItemToLocal() error {
+// dropMigratedItems drops the legacy key3.db and signons.sqlite which are left
+// in the profile after Firefox migrates them to key4.db and logins.json
+func dropMigratedItems(itemPaths map[types.DataType]string) {
+	if _, ok := itemPaths[types.FirefoxKey4]; ok {
+		delete(itemPaths, types.FirefoxKey3)
+	}
+	if _, ok := itemPaths[types.FirefoxPassword]; ok {
+		delete(itemPaths, types.FirefoxLegacyPassword)
+	}
+}
+
+// copyItemToLocal copies the item files into workDir, returns the copied path of each item
+func (f *Firefox) copyItemToLocal(workDir string) (map[types.DataType]string, error) {
+	localPaths := make(map[types.DataType]string, len(f.itemPaths))
 	for i, path := range f.itemPaths {
-		filename := i.TempFilename()
-		if err := fileutil.CopyFile(path, filename); err != nil {
-			return err
+		filename := filepath.Join(workDir, i.TempFilename())
+		var err error
+		switch i {
+		case types.FirefoxLocalStorage:
+			err = copyLocalStorage(filepath.Dir(path), filename)
+		case types.FirefoxIndexedDB:
+			err = copyOriginStorage(filepath.Dir(path), filename, dirIndexedDB)
+		default:
+			err = fileutil.CopyFile(path, filename)
+		}
+		if err != nil {
+			return nil, err
 		}
+		localPaths[i] = filename
 	}
-	return nil
+	return localPaths, nil
 }
 
 func firefoxWalkFunc(items []types.DataType, multiItemPaths map[string]map[types.DataType]string) fs.WalkDirFunc {
@@ -67,31 +108,124 @@ func firefoxWalkFunc(items []types.DataType, multiItemPaths map[string]map[types
 			}
 			return err
 		}
+		name, parentBaseDir := info.Name(), fileutil.ParentBaseDir(path)
+		if name == fileRecovery && parentBaseDir == dirSessionBackups {
+			// the running Firefox keeps the session in sessionstore-backups/recovery.jsonlz4
+			name = types.FirefoxSession.Filename()
+			parentBaseDir = fileutil.ParentBaseDir(fileutil.ParentDir(path))
+		}
+		isStorage := name == dirStorage && info.IsDir() && fileutil.IsDirExists(filepath.Join(path, dirStorageDefault))
 		for _, v := range items {
-			if info.Name() == v.Filename() {
-				parentBaseDir := fileutil.ParentBaseDir(path)
-				if _, exist := multiItemPaths[parentBaseDir]; exist {
-					multiItemPaths[parentBaseDir][v] = path
-				} else {
-					multiItemPaths[parentBaseDir] = map[types.DataType]string{v: path}
-				}
+			if !firefoxItemMatch(v, name, isStorage) {
+				continue
+			}
+			if _, exist := multiItemPaths[parentBaseDir]; !exist {
+				multiItemPaths[parentBaseDir] = make(map[types.DataType]string)
+			}
+			// sessionstore.jsonlz4 is written at shutdown, keep the newer of it and recovery.jsonlz4
+			if prev, ok := multiItemPaths[parentBaseDir][v]; ok && !isNewerFile(path, prev) {
+				continue
 			}
+			multiItemPaths[parentBaseDir][v] = path
 		}
 
 		return nil
 	}
 }
 
-// GetMasterKey returns master key of Firefox. from key4.db
-func (f *Firefox) GetMasterKey() ([]byte, error) {
-	tempFilename := types.FirefoxKey4.TempFilename()
+const (
+	dirSessionBackups = "sessionstore-backups"
+	fileRecovery      = "recovery.jsonlz4"
+	dirStorage        = "storage"
+	dirStorageDefault = "default"
+	dirLocalStorage   = "ls"
+	dirIndexedDB      = "idb"
+)
+
+// firefoxItemMatch reports whether the walked file is the item, the storage dir holds the IndexedDB
+// and, since Firefox 68, the localStorage of each origin in storage/default/<origin>
+func firefoxItemMatch(item types.DataType, name string, isStorage bool) bool {
+	switch item {
+	case types.FirefoxLocalStorage:
+		return name == item.Filename() || isStorage
+	case types.FirefoxIndexedDB:
+		return isStorage
+	}
+	return name == item.Filename()
+}
 
+// copyLocalStorage copies the legacy webappsstore.sqlite and the ls dir of each origin in
+// storage/default of the profile into dst, eg: dst/default/https+++github.com/ls/data.sqlite
+func copyLocalStorage(profileDir, dst string) error {
+	if err := copyOriginStorage(profileDir, dst, dirLocalStorage); err != nil {
+		return err
+	}
+	legacy := filepath.Join(profileDir, types.FirefoxLocalStorage.Filename())
+	if !fileutil.IsFileExists(legacy) {
+		return nil
+	}
+	return fileutil.CopyFile(legacy, filepath.Join(dst, types.FirefoxLocalStorage.Filename()))
+}
+
+// copyOriginStorage copies the files in the client dir of each origin in storage/default of the
+// profile into dst, the sub dirs like the blobs of IndexedDB in idb/*.files are skipped.
+func copyOriginStorage(profileDir, dst, client string) error {
+	if err := os.MkdirAll(dst, 0o700); err != nil {
+		return err
+	}
+	defaultDir := filepath.Join(profileDir, dirStorage, dirStorageDefault)
+	origins, err := os.ReadDir(defaultDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, origin := range origins {
+		if !origin.IsDir() {
+			continue
+		}
+		src := filepath.Join(defaultDir, origin.Name(), client)
+		files, err := os.ReadDir(src)
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join(dst, dirStorageDefault, origin.Name(), client)
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				return err
+			}
+			if err := fileutil.CopyFile(filepath.Join(src, f.Name()), filepath.Join(dir, f.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isNewerFile reports whether the file a is modified after the file b.
+func isNewerFile(a, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return true
+	}
+	return infoA.ModTime().After(infoB.ModTime())
+}
+
+// GetMasterKey returns master key of Firefox. from the copied key4.db
+func (f *Firefox) GetMasterKey(keyPath string) ([]byte, error) {
 	// Open and defer close of the database.
-	keyDB, err := sql.Open("sqlite", tempFilename)
+	keyDB, err := sql.Open("sqlite", keyPath)
 	if err != nil {
 		return nil, fmt.Errorf("open key4.db error: %w", err)
 	}
-	defer os.Remove(tempFilename)
 	defer keyDB.Close()
 
 	metaItem1, metaItem2, err := queryMetaData(keyDB)
@@ -104,7 +238,7 @@ func (f *Firefox) GetMasterKey() ([]byte, error) {
 		return nil, fmt.Errorf("query NSS private error: %w", err)
 	}
 
-	return processMasterKey(metaItem1, metaItem2, nssA11, nssA102)
+	return processMasterKey(metaItem1, metaItem2, nssA11, nssA102, []byte(f.keyOptions.PrimaryPassword))
 }
 
 func queryMetaData(db *sql.DB) ([]byte, []byte, error) {
@@ -125,25 +259,33 @@ func queryNssPrivate(db *sql.DB) ([]byte, []byte, error) {
 	return nssA11, nssA102, nil
 }
 
+// keyLin is the CKA_ID of the NSS private key which holds the 3DES key of the logins
+var keyLin = []byte{248, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+
 // processMasterKey process master key of Firefox.
 // Process the metaBytes and nssA11 with the corresponding cryptographic operations.
-func processMasterKey(metaItem1, metaItem2, nssA11, nssA102 []byte) ([]byte, error) {
+// NSS derives the keys from the SHA1 of the global salt and the primary password,
+// so the password is appended to the global salt, it's empty if no primary password is set.
+func processMasterKey(metaItem1, metaItem2, nssA11, nssA102, primaryPassword []byte) ([]byte, error) {
 	metaPBE, err := crypto.NewASN1PBE(metaItem2)
 	if err != nil {
 		return nil, fmt.Errorf("error creating ASN1PBE from metaItem2: %w", err)
 	}
 
-	flag, err := metaPBE.Decrypt(metaItem1)
-	if err != nil {
-		return nil, fmt.Errorf("error decrypting master key: %w", err)
-	}
-	const passwordCheck = "password-check"
+	globalSalt := make([]byte, 0, len(metaItem1)+len(primaryPassword))
+	globalSalt = append(globalSalt, metaItem1...)
+	globalSalt = append(globalSalt, primaryPassword...)
 
-	if !bytes.Contains(flag, []byte(passwordCheck)) {
-		return nil, errors.New("flag verification failed: password-check not found")
+	const passwordCheck = "password-check"
+	flag, err := metaPBE.Decrypt(globalSalt)
+	if err != nil || !bytes.Contains(flag, []byte(passwordCheck)) {
+		// a wrong key almost always fails the padding check, otherwise password-check is not found
+		if len(primaryPassword) == 0 {
+			return nil, ErrPrimaryPasswordRequired
+		}
+		return nil, ErrPrimaryPasswordIncorrect
 	}
 
-	keyLin := []byte{248, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
 	if !bytes.Equal(nssA102, keyLin) {
 		return nil, errors.New("master key verification failed: nssA102 not equal to expected value")
 	}
@@ -153,7 +295,7 @@ func processMasterKey(metaItem1, metaItem2, nssA11, nssA102 []byte) ([]byte, err
 		return nil, fmt.Errorf("error creating ASN1PBE from nssA11: %w", err)
 	}
 
-	finallyKey, err := nssA11PBE.Decrypt(metaItem1)
+	finallyKey, err := nssA11PBE.Decrypt(globalSalt)
 	if err != nil {
 		return nil, fmt.Errorf("error decrypting final key: %w", err)
 	}
@@ -167,25 +309,36 @@ func (f *Firefox) Name() string {
 	return f.name
 }
 
-func (f *Firefox) BrowsingData(isFullExport bool) (*browserdata.BrowserData, error) {
+func (f *Firefox) BrowsingData(isFullExport bool, workDir string) (*browserdata.BrowserData, error) {
 	dataTypes := f.items
 	if !isFullExport {
 		dataTypes = types.FilterSensitiveItems(f.items)
 	}
 
 	data := browserdata.New(dataTypes)
+	data.SetValueLimit(f.valueLimit)
 
-	if err := f.copyItemToLocal(); err != nil {
+	localPaths, err := f.copyItemToLocal(workDir)
+	if err != nil {
 		return nil, err
 	}
 
-	masterKey, err := f.GetMasterKey()
+	var masterKey []byte
+	if keyPath, ok := localPaths[types.FirefoxKey3]; ok {
+		masterKey, err = f.GetLegacyMasterKey(keyPath)
+	} else {
+		masterKey, err = f.GetMasterKey(localPaths[types.FirefoxKey4])
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	f.masterKey = masterKey
-	if err := data.Recovery(f.masterKey); err != nil {
+	// the credit cards are encrypted by the OS key store instead of NSS
+	if _, ok := localPaths[types.FirefoxCreditCard]; ok {
+		data.SetKeys(types.FirefoxCreditCard, crypto.MasterKeys{V10: f.getOSKeyStoreKey()})
+	}
+	if err := data.Recovery(crypto.MasterKeys{V10: f.masterKey}, localPaths); err != nil {
 		return nil, err
 	}
 	return data, nil
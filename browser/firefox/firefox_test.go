@@ -1,10 +1,19 @@
 package firefox
 
 import (
+	"bytes"
+	"encoding/asn1"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/types"
 )
 
 func TestQueryMetaData(t *testing.T) {
@@ -36,3 +45,166 @@ func TestQueryNssPrivate(t *testing.T) {
 	assert.Equal(t, []byte("nssA11"), nssA11)
 	assert.Equal(t, []byte("nssA102"), nssA102)
 }
+
+// testPBES2 has the same layout as the PBES2 entries of key4.db
+type testPBES2 struct {
+	AlgoAttr struct {
+		asn1.ObjectIdentifier
+		Data struct {
+			Data struct {
+				asn1.ObjectIdentifier
+				SaltAttr struct {
+					EntrySalt      []byte
+					IterationCount int
+					KeySize        int
+					Algorithm      struct {
+						asn1.ObjectIdentifier
+					}
+				}
+			}
+			IVData struct {
+				asn1.ObjectIdentifier
+				IV []byte
+			}
+		}
+	}
+	Encrypted []byte
+}
+
+// newTestPBES2 encrypts the plaintext like NSS does with the global salt and the primary password.
+func newTestPBES2(t *testing.T, globalSalt, password, plaintext []byte) []byte {
+	t.Helper()
+	var pbe testPBES2
+	pbe.AlgoAttr.ObjectIdentifier = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	pbe.AlgoAttr.Data.Data.ObjectIdentifier = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	pbe.AlgoAttr.Data.Data.SaltAttr.EntrySalt = bytes.Repeat([]byte("salt"), 8)
+	pbe.AlgoAttr.Data.Data.SaltAttr.IterationCount = 1
+	pbe.AlgoAttr.Data.Data.SaltAttr.KeySize = 32
+	pbe.AlgoAttr.Data.Data.SaltAttr.Algorithm.ObjectIdentifier = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	pbe.AlgoAttr.Data.IVData.ObjectIdentifier = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	pbe.AlgoAttr.Data.IVData.IV = []byte("moond4rkmoond4")
+	pbe.Encrypted = make([]byte, 16)
+
+	raw, err := asn1.Marshal(pbe)
+	require.NoError(t, err)
+	asn1PBE, err := crypto.NewASN1PBE(raw)
+	require.NoError(t, err)
+	pbe.Encrypted, err = asn1PBE.Encrypt(append(append([]byte{}, globalSalt...), password...), plaintext)
+	require.NoError(t, err)
+	raw, err = asn1.Marshal(pbe)
+	require.NoError(t, err)
+	return raw
+}
+
+func TestProcessMasterKey(t *testing.T) {
+	var (
+		globalSalt = []byte("globalSalt")
+		masterKey  = bytes.Repeat([]byte("moond4rk"), 4)
+		nssA102    = []byte{248, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	)
+	testCases := []struct {
+		name            string
+		profilePassword string
+		password        string
+		wantErr         error
+	}{
+		{name: "no primary password", profilePassword: "", password: ""},
+		{name: "correct primary password", profilePassword: "moond4rk", password: "moond4rk"},
+		{name: "primary password required", profilePassword: "moond4rk", password: "", wantErr: ErrPrimaryPasswordRequired},
+		{name: "incorrect primary password", profilePassword: "moond4rk", password: "wrong", wantErr: ErrPrimaryPasswordIncorrect},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			metaItem2 := newTestPBES2(t, globalSalt, []byte(tc.profilePassword), []byte("password-check"))
+			nssA11 := newTestPBES2(t, globalSalt, []byte(tc.profilePassword), masterKey)
+
+			key, err := processMasterKey(globalSalt, metaItem2, nssA11, nssA102, []byte(tc.password))
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, masterKey[:24], key)
+		})
+	}
+}
+
+func TestFirefoxWalkFunc_SessionStore(t *testing.T) {
+	profiles := t.TempDir()
+	writeFile := func(path string, modTime time.Time) string {
+		path = filepath.Join(profiles, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte("mozLz40\x00"), 0o600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+		return path
+	}
+	now := time.Now()
+	writeFile("running.default/key4.db", now)
+	running := writeFile("running.default/sessionstore-backups/recovery.jsonlz4", now)
+	writeFile("closed.default/key4.db", now)
+	closed := writeFile("closed.default/sessionstore.jsonlz4", now)
+	writeFile("closed.default/sessionstore-backups/recovery.jsonlz4", now.Add(-time.Hour))
+
+	items := []types.DataType{types.FirefoxKey4, types.FirefoxSession, types.FirefoxSessionStorage}
+	multiItemPaths := make(map[string]map[types.DataType]string)
+	require.NoError(t, filepath.WalkDir(profiles, firefoxWalkFunc(items, multiItemPaths)))
+
+	require.Len(t, multiItemPaths, 2)
+	assert.Equal(t, running, multiItemPaths["running.default"][types.FirefoxSession])
+	assert.Equal(t, running, multiItemPaths["running.default"][types.FirefoxSessionStorage])
+	assert.Equal(t, closed, multiItemPaths["closed.default"][types.FirefoxSession])
+}
+
+func TestFirefoxWalkFunc_FormHistory(t *testing.T) {
+	profiles := t.TempDir()
+	dir := filepath.Join(profiles, "xxxxxxxx.default-release")
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	for _, name := range []string{"key4.db", "places.sqlite", "formhistory.sqlite"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+	}
+
+	items := []types.DataType{types.FirefoxKey4, types.FirefoxHistory, types.FirefoxFormHistory}
+	multiItemPaths := make(map[string]map[types.DataType]string)
+	require.NoError(t, filepath.WalkDir(profiles, firefoxWalkFunc(items, multiItemPaths)))
+	assert.Equal(t, filepath.Join(dir, "formhistory.sqlite"), multiItemPaths["xxxxxxxx.default-release"][types.FirefoxFormHistory])
+	assert.Equal(t, filepath.Join(dir, "places.sqlite"), multiItemPaths["xxxxxxxx.default-release"][types.FirefoxHistory])
+}
+
+func TestFirefoxStorage_WalkAndCopy(t *testing.T) {
+	profiles := t.TempDir()
+	writeFile := func(path string) {
+		path = filepath.Join(profiles, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(path), 0o600))
+	}
+	writeFile("lsng.default/key4.db")
+	writeFile("lsng.default/storage/default/https+++github.com/ls/data.sqlite")
+	writeFile("lsng.default/storage/default/https+++github.com/idb/1.sqlite")
+	writeFile("lsng.default/storage/default/https+++github.com/idb/1.files/2")
+	writeFile("lsng.default/storage/default/https+++example.org/cache/morgue")
+	writeFile("lsng.default/webappsstore.sqlite")
+	writeFile("legacy.default/key4.db")
+	writeFile("legacy.default/webappsstore.sqlite")
+
+	items := []types.DataType{types.FirefoxKey4, types.FirefoxLocalStorage, types.FirefoxIndexedDB}
+	multiItemPaths := make(map[string]map[types.DataType]string)
+	require.NoError(t, filepath.WalkDir(profiles, firefoxWalkFunc(items, multiItemPaths)))
+	require.Contains(t, multiItemPaths["lsng.default"], types.FirefoxLocalStorage)
+	require.Contains(t, multiItemPaths["lsng.default"], types.FirefoxIndexedDB)
+	require.Contains(t, multiItemPaths["legacy.default"], types.FirefoxLocalStorage)
+	assert.NotContains(t, multiItemPaths["legacy.default"], types.FirefoxIndexedDB)
+
+	f := &Firefox{itemPaths: multiItemPaths["lsng.default"]}
+	localPaths, err := f.copyItemToLocal(t.TempDir())
+	require.NoError(t, err)
+	dst := localPaths[types.FirefoxLocalStorage]
+	assert.FileExists(t, filepath.Join(dst, "webappsstore.sqlite"))
+	assert.FileExists(t, filepath.Join(dst, "default", "https+++github.com", "ls", "data.sqlite"))
+	assert.NoDirExists(t, filepath.Join(dst, "default", "https+++github.com", "idb"))
+	assert.NoDirExists(t, filepath.Join(dst, "default", "https+++example.org"))
+
+	dst = localPaths[types.FirefoxIndexedDB]
+	assert.FileExists(t, filepath.Join(dst, "default", "https+++github.com", "idb", "1.sqlite"))
+	assert.NoDirExists(t, filepath.Join(dst, "default", "https+++github.com", "idb", "1.files"))
+	assert.NoDirExists(t, filepath.Join(dst, "default", "https+++github.com", "ls"))
+}
@@ -0,0 +1,190 @@
+package firefox
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+)
+
+// key3.db is the key database used by Firefox before 58 and other NSS based browsers,
+// eg: Pale Moon, SeaMonkey and Thunderbird. It's a Berkeley DB 1.85 hash file.
+const (
+	bdbHashMagic      = 0x061561
+	bdbHashVersion    = 2
+	bdbHashHeaderSize = 260
+	bdbLittleEndian   = 1234
+	bdbBigEndian      = 4321
+	// bdbRealKey is the smallest data offset of a regular pair, smaller
+	// offsets mark overflow pages and big pairs which key3.db doesn't use
+	bdbRealKey = 4
+)
+
+var errInvalidBDBHash = errors.New("invalid berkeley db hash file")
+
+// readBDBHash returns the key/data pairs of a Berkeley DB 1.85 hash file.
+// The header is always stored in big endian, the pages in the byte order of the header.
+func readBDBHash(b []byte) (map[string][]byte, error) {
+	if len(b) < bdbHashHeaderSize {
+		return nil, errInvalidBDBHash
+	}
+	be := binary.BigEndian
+	if be.Uint32(b[0:]) != bdbHashMagic || be.Uint32(b[4:]) != bdbHashVersion {
+		return nil, errInvalidBDBHash
+	}
+	var order binary.ByteOrder
+	switch be.Uint32(b[8:]) {
+	case bdbLittleEndian:
+		order = binary.LittleEndian
+	case bdbBigEndian:
+		order = binary.BigEndian
+	default:
+		return nil, errInvalidBDBHash
+	}
+	pageSize := int(be.Uint32(b[12:]))
+	headerPages := int(be.Uint32(b[60:]))
+	if pageSize < bdbHashHeaderSize || pageSize > 1<<16 || headerPages < 1 {
+		return nil, errInvalidBDBHash
+	}
+
+	pairs := make(map[string][]byte)
+	for off := headerPages * pageSize; off+pageSize <= len(b); off += pageSize {
+		readBDBHashPage(b[off:off+pageSize], order, pairs)
+	}
+	return pairs, nil
+}
+
+// readBDBHashPage reads the pairs of a bucket page, pages which are not
+// bucket pages (eg: bitmap pages) fail the offsets check and are skipped.
+//
+//	| n | key1 | data1 | ... | keyN | dataN | free space | offset | ... | dataN | keyN | ... | data1 | key1 |
+func readBDBHashPage(page []byte, order binary.ByteOrder, pairs map[string][]byte) {
+	n := int(order.Uint16(page))
+	if n == 0 || n%2 != 0 || (n+3)*2 > len(page) {
+		return
+	}
+	var (
+		keys  [][]byte
+		datas [][]byte
+		end   = len(page)
+	)
+	for i := 1; i < n; i += 2 {
+		keyOff := int(order.Uint16(page[i*2:]))
+		dataOff := int(order.Uint16(page[(i+1)*2:]))
+		if dataOff < bdbRealKey {
+			break
+		}
+		if dataOff < (n+3)*2 || dataOff > keyOff || keyOff > end {
+			return
+		}
+		keys = append(keys, page[keyOff:end])
+		datas = append(datas, page[dataOff:keyOff])
+		end = dataOff
+	}
+	for i, key := range keys {
+		pairs[string(key)] = datas[i]
+	}
+}
+
+// GetLegacyMasterKey returns master key of Firefox from the copied key3.db
+func (f *Firefox) GetLegacyMasterKey(keyPath string) ([]byte, error) {
+	b, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read key3.db error: %w", err)
+	}
+	pairs, err := readBDBHash(b)
+	if err != nil {
+		return nil, fmt.Errorf("read key3.db error: %w", err)
+	}
+	return processLegacyMasterKey(pairs, []byte(f.keyOptions.PrimaryPassword))
+}
+
+// processLegacyMasterKey checks the primary password with the password-check entry,
+// then decrypts the private key entry which holds the 3DES key of the logins.
+// Both entries are laid out as:
+//
+//	| version | salt length | nickname length | entry salt | nickname | encrypted data |
+func processLegacyMasterKey(pairs map[string][]byte, primaryPassword []byte) ([]byte, error) {
+	salt, ok := pairs["global-salt"]
+	if !ok {
+		return nil, errors.New("global-salt not found in key3.db")
+	}
+	globalSalt := make([]byte, 0, len(salt)+len(primaryPassword))
+	globalSalt = append(globalSalt, salt...)
+	globalSalt = append(globalSalt, primaryPassword...)
+
+	const passwordCheck = "password-check"
+	entrySalt, data, err := splitKey3Entry(pairs[passwordCheck])
+	if err != nil || len(data) < 16 {
+		return nil, errors.New("password-check not found in key3.db")
+	}
+	// the encrypted "password-check" is the last 16 bytes, after the algorithm id
+	flag, err := crypto.NewNSSPBE(entrySalt, data[len(data)-16:]).Decrypt(globalSalt)
+	if err != nil || !bytes.Contains(flag, []byte(passwordCheck)) {
+		if len(primaryPassword) == 0 {
+			return nil, ErrPrimaryPasswordRequired
+		}
+		return nil, ErrPrimaryPasswordIncorrect
+	}
+
+	entry, ok := pairs[string(keyLin)]
+	if !ok {
+		return nil, errors.New("private key not found in key3.db")
+	}
+	_, encrypted, err := splitKey3Entry(entry)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key error: %w", err)
+	}
+	keyPBE, err := crypto.NewASN1PBE(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("error creating ASN1PBE from private key: %w", err)
+	}
+	privateKey, err := keyPBE.Decrypt(globalSalt)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting private key: %w", err)
+	}
+	return parseLegacyPrivateKey(privateKey)
+}
+
+// splitKey3Entry returns the entry salt and the data after the nickname of a key3.db entry.
+func splitKey3Entry(entry []byte) ([]byte, []byte, error) {
+	if len(entry) < 3 {
+		return nil, nil, errInvalidBDBHash
+	}
+	saltLen, nameLen := int(entry[1]), int(entry[2])
+	if len(entry) <= 3+saltLen+nameLen {
+		return nil, nil, errInvalidBDBHash
+	}
+	// cap the salt, the zero padding of the key derivation appends to it
+	return entry[3 : 3+saltLen : 3+saltLen], entry[3+saltLen+nameLen:], nil
+}
+
+// parseLegacyPrivateKey returns the 3DES key from the decrypted PKCS#8 private key,
+// NSS stores it as the fourth integer of the private key sequence.
+func parseLegacyPrivateKey(b []byte) ([]byte, error) {
+	var keyInfo struct {
+		Version    int
+		Algorithm  asn1.RawValue
+		PrivateKey []byte
+	}
+	if _, err := asn1.Unmarshal(b, &keyInfo); err != nil {
+		return nil, fmt.Errorf("unmarshal private key info error: %w", err)
+	}
+	var values []asn1.RawValue
+	if _, err := asn1.Unmarshal(keyInfo.PrivateKey, &values); err != nil {
+		return nil, fmt.Errorf("unmarshal private key error: %w", err)
+	}
+	if len(values) < 4 || values[3].Tag != asn1.TagInteger {
+		return nil, errors.New("private key is not found in the private key sequence")
+	}
+	key := bytes.TrimLeft(values[3].Bytes, "\x00")
+	if len(key) > 24 {
+		return nil, errors.New("length of private key is more than 24 bytes")
+	}
+	// restore the leading zeros trimmed by the integer encoding
+	return append(make([]byte, 24-len(key), 24), key...), nil
+}
@@ -0,0 +1,193 @@
+package firefox
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/types"
+)
+
+// newTestBDBHash builds a Berkeley DB 1.85 hash file with the pairs in a single bucket page,
+// followed by a bitmap page which must be skipped.
+func newTestBDBHash(t *testing.T, order binary.ByteOrder, pairs [][2][]byte) []byte {
+	t.Helper()
+	const pageSize = 1024
+	header := make([]byte, pageSize)
+	be := binary.BigEndian
+	be.PutUint32(header[0:], bdbHashMagic)
+	be.PutUint32(header[4:], bdbHashVersion)
+	if order == binary.LittleEndian {
+		be.PutUint32(header[8:], bdbLittleEndian)
+	} else {
+		be.PutUint32(header[8:], bdbBigEndian)
+	}
+	be.PutUint32(header[12:], pageSize)
+	be.PutUint32(header[56:], uint32(len(pairs)))
+	be.PutUint32(header[60:], 1)
+
+	page := make([]byte, pageSize)
+	n, off := 0, pageSize
+	for _, pair := range pairs {
+		off -= len(pair[0])
+		copy(page[off:], pair[0])
+		n++
+		order.PutUint16(page[n*2:], uint16(off))
+		off -= len(pair[1])
+		copy(page[off:], pair[1])
+		n++
+		order.PutUint16(page[n*2:], uint16(off))
+	}
+	order.PutUint16(page, uint16(n))
+	order.PutUint16(page[(n+1)*2:], uint16(off-(n+3)*2))
+	order.PutUint16(page[(n+2)*2:], uint16(off))
+	require.Less(t, (n+3)*2, off)
+
+	bitmap := bytes.Repeat([]byte{0xff}, pageSize)
+	return append(append(header, page...), bitmap...)
+}
+
+// testNSSPBE has the same layout as the pbeWithSha1AndTripleDES-CBC entries of key3.db
+type testNSSPBE struct {
+	AlgoAttr struct {
+		asn1.ObjectIdentifier
+		SaltAttr struct {
+			EntrySalt []byte
+			Len       int
+		}
+	}
+	Encrypted []byte
+}
+
+// newTestKey3 returns the key3.db pairs protected by the primary password, which hold the 3DES key.
+func newTestKey3(t *testing.T, password, key []byte) [][2][]byte {
+	t.Helper()
+	salt := []byte("moond4rk-global-salt")
+	globalSalt := append(append([]byte{}, salt...), password...)
+	entrySalt := bytes.Repeat([]byte("s"), 16)
+
+	check, err := crypto.NewNSSPBE(entrySalt, nil).Encrypt(globalSalt, []byte("password-check"))
+	require.NoError(t, err)
+	// version, salt length, nickname length, entry salt, algorithm id, encrypted
+	checkEntry := append([]byte{3, byte(len(entrySalt)), 0}, entrySalt...)
+	checkEntry = append(checkEntry, 0x0b, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x0c, 0x05, 0x01, 0x03)
+	checkEntry = append(checkEntry, check...)
+
+	privateKey, err := asn1.Marshal(struct {
+		Version, ID, PublicExponent int
+		Key                         *big.Int
+	}{Version: 0, ID: 1, PublicExponent: 65537, Key: new(big.Int).SetBytes(key)})
+	require.NoError(t, err)
+	keyInfo, err := asn1.Marshal(struct {
+		Version    int
+		Algorithm  pkix.AlgorithmIdentifier
+		PrivateKey []byte
+	}{
+		Algorithm:  pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}},
+		PrivateKey: privateKey,
+	})
+	require.NoError(t, err)
+
+	var pbe testNSSPBE
+	pbe.AlgoAttr.ObjectIdentifier = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 5, 1, 3}
+	pbe.AlgoAttr.SaltAttr.EntrySalt = entrySalt
+	pbe.AlgoAttr.SaltAttr.Len = 1
+	pbe.Encrypted, err = crypto.NewNSSPBE(entrySalt, nil).Encrypt(globalSalt, keyInfo)
+	require.NoError(t, err)
+	encrypted, err := asn1.Marshal(pbe)
+	require.NoError(t, err)
+	nickname := []byte("Firefox")
+	keyEntry := append([]byte{3, byte(len(entrySalt)), byte(len(nickname))}, entrySalt...)
+	keyEntry = append(keyEntry, nickname...)
+	keyEntry = append(keyEntry, encrypted...)
+
+	return [][2][]byte{
+		{[]byte("Version"), {3}},
+		{[]byte("global-salt"), salt},
+		{[]byte("password-check"), checkEntry},
+		{keyLin, keyEntry},
+	}
+}
+
+func TestReadBDBHash(t *testing.T) {
+	pairs := [][2][]byte{
+		{[]byte("global-salt"), []byte("salt")},
+		{[]byte("Version"), {3}},
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			got, err := readBDBHash(newTestBDBHash(t, order, pairs))
+			require.NoError(t, err)
+			assert.Equal(t, map[string][]byte{
+				"global-salt": []byte("salt"),
+				"Version":     {3},
+			}, got)
+		})
+	}
+
+	_, err := readBDBHash([]byte("SQLite format 3"))
+	assert.ErrorIs(t, err, errInvalidBDBHash)
+}
+
+func TestGetLegacyMasterKey(t *testing.T) {
+	// the leading zero is trimmed by the integer encoding of the private key
+	key := append([]byte{0}, bytes.Repeat([]byte("k"), 23)...)
+	testCases := []struct {
+		name     string
+		password string
+		supplied string
+		wantErr  error
+	}{
+		{name: "no primary password"},
+		{name: "primary password", password: "moond4rk", supplied: "moond4rk"},
+		{name: "primary password required", password: "moond4rk", wantErr: ErrPrimaryPasswordRequired},
+		{name: "primary password incorrect", password: "moond4rk", supplied: "wrong", wantErr: ErrPrimaryPasswordIncorrect},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keyPath := filepath.Join(t.TempDir(), "key3.db")
+			db := newTestBDBHash(t, binary.LittleEndian, newTestKey3(t, []byte(tc.password), key))
+			require.NoError(t, os.WriteFile(keyPath, db, 0o600))
+
+			f := &Firefox{keyOptions: KeyOptions{PrimaryPassword: tc.supplied}}
+			got, err := f.GetLegacyMasterKey(keyPath)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, key, got)
+		})
+	}
+}
+
+func TestDropMigratedItems(t *testing.T) {
+	itemPaths := map[types.DataType]string{
+		types.FirefoxKey4:           "key4.db",
+		types.FirefoxKey3:           "key3.db",
+		types.FirefoxPassword:       "logins.json",
+		types.FirefoxLegacyPassword: "signons.sqlite",
+	}
+	dropMigratedItems(itemPaths)
+	assert.Equal(t, map[types.DataType]string{
+		types.FirefoxKey4:     "key4.db",
+		types.FirefoxPassword: "logins.json",
+	}, itemPaths)
+
+	// logins.json is used with key3.db between Firefox 32 and 58
+	itemPaths = map[types.DataType]string{
+		types.FirefoxKey3:     "key3.db",
+		types.FirefoxPassword: "logins.json",
+	}
+	dropMigratedItems(itemPaths)
+	assert.Len(t, itemPaths, 2)
+}
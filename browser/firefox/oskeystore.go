@@ -0,0 +1,52 @@
+package firefox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/moond4rk/hackbrowserdata/log"
+)
+
+// osKeyStoreLabel is the label of the key of Firefox in the OS key store, the credit card
+// numbers of autofill-profiles.json are encrypted by it since Firefox 86
+// @https://searchfox.org/mozilla-central/source/toolkit/modules/OSKeyStore.sys.mjs
+const osKeyStoreLabel = "Firefox Encrypted Storage"
+
+var (
+	errOSKeyStoreKeyNotFound = errors.New("os key store key not found")
+	errInvalidOSKeyStoreKey  = errors.New("os key store key is not a base64 encoded 32 bytes key")
+)
+
+// getOSKeyStoreKey returns the supplied key of the OS key store, or reads it from the OS key store,
+// it returns nil if the key is not found and the card numbers can't be decrypted.
+func (f *Firefox) getOSKeyStoreKey() []byte {
+	if len(f.keyOptions.OSKeyStoreKey) > 0 {
+		return f.keyOptions.OSKeyStoreKey
+	}
+	secret, err := readOSKeyStoreSecret(osKeyStoreLabel)
+	if err != nil {
+		log.Warnf("get %s of %s error: %v", osKeyStoreLabel, f.name, err)
+		return nil
+	}
+	key, err := decodeOSKeyStoreSecret(secret)
+	if err != nil {
+		log.Warnf("decode %s of %s error: %v", osKeyStoreLabel, f.name, err)
+		return nil
+	}
+	log.Debugf("get %s success, browser %s", osKeyStoreLabel, f.name)
+	return key
+}
+
+// decodeOSKeyStoreSecret decodes the secret of the OS key store, Firefox keeps the key base64 encoded.
+func decodeOSKeyStoreSecret(secret []byte) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(secret)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidOSKeyStoreKey, err)
+	}
+	if len(key) != 32 {
+		return nil, errInvalidOSKeyStoreKey
+	}
+	return key, nil
+}
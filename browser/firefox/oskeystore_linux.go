@@ -0,0 +1,28 @@
+//go:build linux
+
+package firefox
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/moond4rk/hackbrowserdata/internal/keystore"
+)
+
+// readOSKeyStoreSecret reads the secret of the label from the Secret Service, where Firefox
+// keeps it with libsecret, eg: GNOME Keyring and KWallet 5.97+
+func readOSKeyStoreSecret(label string) ([]byte, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("connect session bus: %w", err)
+	}
+	defer conn.Close()
+
+	secret, err := keystore.SecretServiceSecret(conn, label)
+	if errors.Is(err, keystore.ErrSecretNotFound) {
+		return nil, errOSKeyStoreKeyNotFound
+	}
+	return secret, err
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package firefox
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// readOSKeyStoreSecret isn't supported on the OS, the key is kept in the Keychain on macOS
+// and the Credential Manager on Windows, supply it with KeyOptions.OSKeyStoreKey instead.
+func readOSKeyStoreSecret(label string) ([]byte, error) {
+	return nil, fmt.Errorf("%w: reading %s is not supported on %s", errOSKeyStoreKeyNotFound, label, runtime.GOOS)
+}
@@ -0,0 +1,28 @@
+package firefox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeOSKeyStoreSecret(t *testing.T) {
+	key := bytes.Repeat([]byte{'k'}, 32)
+	decoded, err := decodeOSKeyStoreSecret([]byte(base64.StdEncoding.EncodeToString(key) + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, key, decoded)
+
+	_, err = decodeOSKeyStoreSecret([]byte(base64.StdEncoding.EncodeToString(key[:16])))
+	assert.ErrorIs(t, err, errInvalidOSKeyStoreKey)
+	_, err = decodeOSKeyStoreSecret([]byte("not base64!"))
+	assert.ErrorIs(t, err, errInvalidOSKeyStoreKey)
+}
+
+func TestGetOSKeyStoreKey_Supplied(t *testing.T) {
+	key := bytes.Repeat([]byte{'k'}, 32)
+	f := &Firefox{name: "firefox-test", keyOptions: KeyOptions{OSKeyStoreKey: key}}
+	assert.Equal(t, key, f.getOSKeyStoreKey())
+}
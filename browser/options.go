@@ -0,0 +1,215 @@
+package browser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/moond4rk/hackbrowserdata/browser/chromium"
+	"github.com/moond4rk/hackbrowserdata/browser/firefox"
+	"github.com/moond4rk/hackbrowserdata/crypto/dpapi"
+)
+
+// Options configures how browsers are picked and how their data is decrypted.
+type Options struct {
+	// Name is the browser name, "all" picks every browser.
+	Name string
+	// ProfilePath is a custom profile dir path, empty uses the default path of the browser.
+	ProfilePath string
+
+	// MasterKey is the hex or base64 encoded Chromium master key.
+	MasterKey string
+	// MasterKeyFile is a file containing the raw, hex or base64 encoded Chromium master key.
+	MasterKeyFile string
+	// SafeStoragePassword is the Chromium Safe Storage password the master key is derived from.
+	SafeStoragePassword string
+	// SafeStorageOS is the OS of the copied profile, linux or darwin, the Safe Storage password of it is
+	// derived with 1 and 1003 iterations. It's the OS of this host if empty.
+	SafeStorageOS string
+
+	// PasswordStore is the Linux key store holding the Chromium Safe Storage password,
+	// one of basic, gnome-libsecret, kwallet, kwallet5 and kwallet6, detected if empty.
+	PasswordStore string
+	// KeyringFile is a Linux GNOME keyring file to read the Safe Storage password from offline,
+	// KeyringPassword is the login password of the user which encrypts it.
+	KeyringFile     string
+	KeyringPassword string
+
+	// DPAPIMasterKeyDir is the Protect/<SID> dir of a Windows user holding the DPAPI master key files,
+	// the Chromium master key of a Windows profile is decrypted offline with one of DPAPIPassword,
+	// the logon password, DPAPINTHash, the hex encoded NTLM hash, and DPAPIBackupKeyFile, the domain backup key.
+	DPAPIMasterKeyDir  string
+	DPAPIPassword      string
+	DPAPINTHash        string
+	DPAPIBackupKeyFile string
+	// KeychainFile is a macOS login.keychain-db to read the Chromium Safe Storage password from offline,
+	// KeychainPassword is the login password of the user which unlocks it.
+	KeychainFile     string
+	KeychainPassword string
+
+	// FirefoxPassword is the Firefox primary (master) password protecting key4.db.
+	FirefoxPassword string
+	// FirefoxKeyStoreKey is the hex or base64 encoded key of the OS key store encrypting the Firefox credit cards.
+	FirefoxKeyStoreKey string
+
+	// StorageValueLimit is the max length in bytes of a localStorage value kept in the result, a longer value
+	// is replaced by a note and exported to StorageValueDir if it's set, 0 means no limit.
+	StorageValueLimit int
+	StorageValueDir   string
+}
+
+var (
+	errMultipleMasterKeys  = errors.New("only one of master key, master key file and safe storage password can be supplied")
+	errInvalidMasterKey    = errors.New("master key is neither hex nor base64 encoded")
+	errInvalidMasterKeyLen = errors.New("master key length must be 16 or 32 bytes")
+	errUnknownSafeStorage  = errors.New("safe storage os must be linux or darwin")
+	errInvalidNTHash       = errors.New("nt hash must be 16 bytes hex encoded")
+	errInvalidKeyStoreKey  = errors.New("firefox key store key must be 32 bytes hex or base64 encoded")
+)
+
+// chromiumKeyOptions returns how the Chromium master key is obtained.
+func (o Options) chromiumKeyOptions() (chromium.KeyOptions, error) {
+	masterKey, err := o.chromiumMasterKey()
+	if err != nil {
+		return chromium.KeyOptions{}, err
+	}
+	credential, err := o.dpapiCredential()
+	if err != nil {
+		return chromium.KeyOptions{}, err
+	}
+	return chromium.KeyOptions{
+		MasterKey:         masterKey,
+		SafeStorageOS:     o.SafeStorageOS,
+		PasswordStore:     o.PasswordStore,
+		KeyringFile:       o.KeyringFile,
+		KeyringPassword:   o.KeyringPassword,
+		DPAPIMasterKeyDir: o.DPAPIMasterKeyDir,
+		DPAPICredential:   credential,
+		KeychainFile:      o.KeychainFile,
+		KeychainPassword:  o.KeychainPassword,
+	}, nil
+}
+
+// firefoxKeyOptions returns how the keys of the Firefox profiles are obtained.
+func (o Options) firefoxKeyOptions() (firefox.KeyOptions, error) {
+	keyOptions := firefox.KeyOptions{PrimaryPassword: o.FirefoxPassword}
+	if o.FirefoxKeyStoreKey != "" {
+		key, err := decodeMasterKey([]byte(o.FirefoxKeyStoreKey))
+		if err != nil || len(key) != 32 {
+			return firefox.KeyOptions{}, errInvalidKeyStoreKey
+		}
+		keyOptions.OSKeyStoreKey = key
+	}
+	return keyOptions, nil
+}
+
+// dpapiCredential returns the credential decrypting the DPAPI master key files,
+// the SID is taken from the name of the master key dir.
+func (o Options) dpapiCredential() (dpapi.Credential, error) {
+	if o.DPAPIMasterKeyDir == "" {
+		return dpapi.Credential{}, nil
+	}
+	credential := dpapi.Credential{Password: o.DPAPIPassword}
+	if o.DPAPINTHash != "" {
+		nt, err := hex.DecodeString(o.DPAPINTHash)
+		if err != nil || len(nt) != 16 {
+			return dpapi.Credential{}, errInvalidNTHash
+		}
+		credential.NTHash = nt
+	}
+	if o.DPAPIBackupKeyFile != "" {
+		b, err := os.ReadFile(o.DPAPIBackupKeyFile)
+		if err != nil {
+			return dpapi.Credential{}, fmt.Errorf("read dpapi backup key file: %w", err)
+		}
+		if credential.BackupKey, err = dpapi.ParseBackupKey(b); err != nil {
+			return dpapi.Credential{}, err
+		}
+	}
+	if credential.Password == "" && credential.NTHash == nil && credential.BackupKey == nil {
+		return dpapi.Credential{}, dpapi.ErrCredentialRequired
+	}
+	return credential, nil
+}
+
+// chromiumMasterKey returns the Chromium master key supplied by the options,
+// it returns nil if no key is supplied and the key should be discovered from the OS.
+func (o Options) chromiumMasterKey() ([]byte, error) {
+	switch o.SafeStorageOS {
+	case "", chromium.SafeStorageOSLinux, chromium.SafeStorageOSDarwin:
+	default:
+		return nil, errUnknownSafeStorage
+	}
+	supplied := 0
+	for _, v := range []string{o.MasterKey, o.MasterKeyFile, o.SafeStoragePassword} {
+		if v != "" {
+			supplied++
+		}
+	}
+	switch {
+	case supplied == 0:
+		return nil, nil
+	case supplied > 1:
+		return nil, errMultipleMasterKeys
+	}
+
+	var (
+		key []byte
+		err error
+	)
+	switch {
+	case o.MasterKey != "":
+		key, err = decodeMasterKey([]byte(o.MasterKey))
+		if err != nil {
+			return nil, err
+		}
+	case o.MasterKeyFile != "":
+		key, err = readMasterKeyFile(o.MasterKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		key, err = chromium.DeriveSafeStorageKey([]byte(o.SafeStoragePassword), o.SafeStorageOS)
+		if err != nil {
+			return nil, fmt.Errorf("derive master key from safe storage password: %w", err)
+		}
+	}
+	if !isValidMasterKeyLen(key) {
+		return nil, errInvalidMasterKeyLen
+	}
+	return key, nil
+}
+
+// readMasterKeyFile reads a hex or base64 encoded master key from the file,
+// the file content is used as the raw key if it can't be decoded.
+func readMasterKeyFile(filename string) ([]byte, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read master key file: %w", err)
+	}
+	if key, err := decodeMasterKey(b); err == nil {
+		return key, nil
+	}
+	return b, nil
+}
+
+// decodeMasterKey decodes the hex or base64 encoded master key.
+func decodeMasterKey(s []byte) ([]byte, error) {
+	s = bytes.TrimSpace(s)
+	if key, err := hex.DecodeString(string(s)); err == nil && isValidMasterKeyLen(key) {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(string(s)); err == nil && isValidMasterKeyLen(key) {
+		return key, nil
+	}
+	return nil, errInvalidMasterKey
+}
+
+// isValidMasterKeyLen reports whether the key is an AES-128 key used on Linux and macOS,
+// or an AES-256 key used on Windows.
+func isValidMasterKeyLen(key []byte) bool {
+	return len(key) == 16 || len(key) == 32
+}
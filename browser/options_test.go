@@ -0,0 +1,128 @@
+package browser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto/dpapi"
+)
+
+var testMasterKey = bytes.Repeat([]byte("moond4rk"), 2)
+
+func TestOptions_ChromiumMasterKey(t *testing.T) {
+	dir := t.TempDir()
+	rawKeyFile := filepath.Join(dir, "raw.key")
+	require.NoError(t, os.WriteFile(rawKeyFile, testMasterKey, 0o600))
+	hexKeyFile := filepath.Join(dir, "hex.key")
+	require.NoError(t, os.WriteFile(hexKeyFile, []byte(hex.EncodeToString(testMasterKey)+"\n"), 0o600))
+
+	testCases := []struct {
+		name    string
+		opts    Options
+		want    []byte
+		wantErr error
+	}{
+		{name: "no key", opts: Options{}, want: nil},
+		{name: "hex", opts: Options{MasterKey: hex.EncodeToString(testMasterKey)}, want: testMasterKey},
+		{name: "base64", opts: Options{MasterKey: base64.StdEncoding.EncodeToString(testMasterKey)}, want: testMasterKey},
+		{name: "raw key file", opts: Options{MasterKeyFile: rawKeyFile}, want: testMasterKey},
+		{name: "hex key file", opts: Options{MasterKeyFile: hexKeyFile}, want: testMasterKey},
+		{name: "invalid key", opts: Options{MasterKey: "moond4rk"}, wantErr: errInvalidMasterKey},
+		{name: "invalid key length", opts: Options{MasterKey: hex.EncodeToString([]byte("moond4rk"))}, wantErr: errInvalidMasterKey},
+		{name: "unknown safe storage os", opts: Options{SafeStoragePassword: "peanuts", SafeStorageOS: "windows"}, wantErr: errUnknownSafeStorage},
+		{name: "multiple keys", opts: Options{MasterKey: hex.EncodeToString(testMasterKey), SafeStoragePassword: "peanuts"}, wantErr: errMultipleMasterKeys},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, err := tc.opts.chromiumMasterKey()
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, key)
+		})
+	}
+}
+
+func TestOptions_SafeStoragePassword(t *testing.T) {
+	key, err := Options{SafeStoragePassword: "peanuts"}.chromiumMasterKey()
+	if runtime.GOOS == "windows" {
+		assert.Error(t, err)
+		return
+	}
+	assert.NoError(t, err)
+	assert.Len(t, key, 16)
+	if runtime.GOOS == "linux" {
+		// the default key of chromium on linux, derived from "peanuts" with 1 iteration
+		assert.Equal(t, "fd621fe5a2b402539dfa147ca9272778", hex.EncodeToString(key))
+	}
+}
+
+func TestOptions_SafeStorageOS(t *testing.T) {
+	// a profile of linux is derived with 1 iteration on any os
+	key, err := Options{SafeStoragePassword: "peanuts", SafeStorageOS: "linux"}.chromiumMasterKey()
+	require.NoError(t, err)
+	assert.Equal(t, "fd621fe5a2b402539dfa147ca9272778", hex.EncodeToString(key))
+
+	// a profile of macOS is derived with 1003 iterations
+	key, err = Options{SafeStoragePassword: "peanuts", SafeStorageOS: "darwin"}.chromiumMasterKey()
+	require.NoError(t, err)
+	assert.Len(t, key, 16)
+	assert.NotEqual(t, "fd621fe5a2b402539dfa147ca9272778", hex.EncodeToString(key))
+
+	keyOptions, err := Options{MasterKey: hex.EncodeToString(testMasterKey), SafeStorageOS: "darwin"}.chromiumKeyOptions()
+	require.NoError(t, err)
+	assert.Equal(t, "darwin", keyOptions.SafeStorageOS)
+}
+
+func TestOptions_DPAPICredential(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    Options
+		wantErr error
+	}{
+		{name: "no master key dir", opts: Options{DPAPIPassword: "moond4rk"}},
+		{name: "password", opts: Options{DPAPIMasterKeyDir: "S-1-5-21-1-2-3-1001", DPAPIPassword: "moond4rk"}},
+		{name: "nt hash", opts: Options{DPAPIMasterKeyDir: "S-1-5-21-1-2-3-1001", DPAPINTHash: "8846f7eaee8fb117ad06bdd830b7586c"}},
+		{name: "invalid nt hash", opts: Options{DPAPIMasterKeyDir: "S-1-5-21-1-2-3-1001", DPAPINTHash: "8846f7ea"}, wantErr: errInvalidNTHash},
+		{name: "no credential", opts: Options{DPAPIMasterKeyDir: "S-1-5-21-1-2-3-1001"}, wantErr: dpapi.ErrCredentialRequired},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.opts.dpapiCredential()
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestOptions_FirefoxKeyOptions(t *testing.T) {
+	key := bytes.Repeat([]byte{'k'}, 32)
+	keyOptions, err := Options{FirefoxPassword: "moond4rk", FirefoxKeyStoreKey: base64.StdEncoding.EncodeToString(key)}.firefoxKeyOptions()
+	require.NoError(t, err)
+	assert.Equal(t, "moond4rk", keyOptions.PrimaryPassword)
+	assert.Equal(t, key, keyOptions.OSKeyStoreKey)
+
+	keyOptions, err = Options{FirefoxKeyStoreKey: hex.EncodeToString(key)}.firefoxKeyOptions()
+	require.NoError(t, err)
+	assert.Equal(t, key, keyOptions.OSKeyStoreKey)
+
+	keyOptions, err = Options{}.firefoxKeyOptions()
+	require.NoError(t, err)
+	assert.Nil(t, keyOptions.OSKeyStoreKey)
+
+	_, err = Options{FirefoxKeyStoreKey: hex.EncodeToString(testMasterKey)}.firefoxKeyOptions()
+	assert.ErrorIs(t, err, errInvalidKeyStoreKey)
+}
@@ -0,0 +1,377 @@
+package address
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+	// import sqlite3 driver
+	_ "modernc.org/sqlite"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/extractor"
+	"github.com/moond4rk/hackbrowserdata/log"
+	"github.com/moond4rk/hackbrowserdata/types"
+	"github.com/moond4rk/hackbrowserdata/utils/fileutil"
+	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
+)
+
+func init() {
+	extractor.RegisterExtractor(types.ChromiumAddress, func() extractor.Extractor {
+		return new(ChromiumAddress)
+	})
+	extractor.RegisterExtractor(types.FirefoxAddress, func() extractor.Extractor {
+		return new(FirefoxAddress)
+	})
+}
+
+// Address is a saved autofill address.
+type Address struct {
+	GUID          string
+	FullName      string
+	Organization  string
+	StreetAddress string
+	City          string
+	State         string
+	PostalCode    string
+	Country       string
+	Phone         string
+	Email         string
+	UseCount      int64
+	CreateDate    time.Time
+	LastUsedDate  time.Time
+}
+
+type FirefoxAddress []Address
+
+// Extract reads the addresses of autofill-profiles.json, the address fields are named
+// like the autocomplete attribute of the form, eg: address-level2 is the city.
+// @https://searchfox.org/mozilla-central/source/toolkit/components/formautofill/FormAutofillStorageBase.sys.mjs
+func (f *FirefoxAddress) Extract(_ crypto.MasterKeys, path string) error {
+	content, err := fileutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, v := range gjson.Get(content, "addresses").Array() {
+		// the deleted addresses are kept as tombstones for sync
+		if v.Get("deleted").Bool() {
+			continue
+		}
+		*f = append(*f, Address{
+			GUID:          v.Get("guid").String(),
+			FullName:      firefoxFullName(v),
+			Organization:  v.Get("organization").String(),
+			StreetAddress: v.Get("street-address").String(),
+			City:          v.Get("address-level2").String(),
+			State:         v.Get("address-level1").String(),
+			PostalCode:    v.Get("postal-code").String(),
+			Country:       v.Get("country").String(),
+			Phone:         v.Get("tel").String(),
+			Email:         v.Get("email").String(),
+			UseCount:      v.Get("timesUsed").Int(),
+			CreateDate:    typeutil.TimeStamp(v.Get("timeCreated").Int() / 1000),
+			LastUsedDate:  typeutil.TimeStamp(v.Get("timeLastUsed").Int() / 1000),
+		})
+	}
+	sort.Slice(*f, func(i, j int) bool {
+		return (*f)[i].LastUsedDate.After((*f)[j].LastUsedDate)
+	})
+	return nil
+}
+
+// firefoxFullName returns the name of the address, the older versions keep only the name parts.
+func firefoxFullName(v gjson.Result) string {
+	if name := v.Get("name").String(); name != "" {
+		return name
+	}
+	var parts []string
+	for _, field := range []string{"given-name", "additional-name", "family-name"} {
+		if part := v.Get(field).String(); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f *FirefoxAddress) Name() string {
+	return "address"
+}
+
+func (f *FirefoxAddress) Len() int {
+	return len(*f)
+}
+
+type ChromiumAddress []Address
+
+// the field types of the autofill profile of Chromium, which are the type of the type tokens,
+// the values are fixed since they are stored, each is named after its FieldType in the comment
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/autofill/core/browser/field_types.h
+const (
+	chromiumNameFirst        = 3  // NAME_FIRST
+	chromiumNameMiddle       = 4  // NAME_MIDDLE
+	chromiumNameLast         = 5  // NAME_LAST
+	chromiumNameFull         = 7  // NAME_FULL
+	chromiumEmailAddress     = 9  // EMAIL_ADDRESS
+	chromiumPhoneWholeNumber = 14 // PHONE_HOME_WHOLE_NUMBER
+	chromiumAddressLine1     = 30 // ADDRESS_HOME_LINE1
+	chromiumAddressLine2     = 31 // ADDRESS_HOME_LINE2
+	chromiumAddressCity      = 33 // ADDRESS_HOME_CITY
+	chromiumAddressState     = 34 // ADDRESS_HOME_STATE
+	chromiumAddressZip       = 35 // ADDRESS_HOME_ZIP
+	chromiumAddressCountry   = 36 // ADDRESS_HOME_COUNTRY
+	chromiumCompanyName      = 60 // COMPANY_NAME
+	chromiumAddressStreet    = 77 // ADDRESS_HOME_STREET_ADDRESS
+	chromiumAddressLine3     = 80 // ADDRESS_HOME_LINE3
+)
+
+const (
+	chromiumAddressTable       = "addresses"
+	chromiumLocalAddresses     = "local_addresses"
+	chromiumContactInfo        = "contact_info"
+	chromiumLegacyProfiles     = "autofill_profiles"
+	queryChromiumTables        = `SELECT name FROM sqlite_master WHERE type = 'table'`
+	queryChromiumAddress       = `SELECT guid, use_count, use_date FROM %s`
+	queryChromiumAddressTokens = `SELECT guid, type, value FROM %s`
+)
+
+// the tables of the addresses and their type tokens, the current Chromium keeps the local and the account
+// addresses together in addresses, they were kept apart in local_addresses and contact_info before
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/autofill/core/browser/webdata/addresses/address_autofill_table.cc
+var chromiumAddressTables = []struct {
+	table, tokens string
+}{
+	{chromiumAddressTable, "address_type_tokens"},
+	{chromiumLocalAddresses, "local_addresses_type_tokens"},
+	{chromiumContactInfo, "contact_info_type_tokens"},
+}
+
+// the tables of the autofill profiles before Chromium 118, each row is mapped to the field types
+var chromiumLegacyProfileTables = []struct {
+	query  string
+	fields []int
+}{
+	{
+		`SELECT guid, company_name, street_address, city, state, zipcode, country_code FROM autofill_profiles`,
+		[]int{chromiumCompanyName, chromiumAddressStreet, chromiumAddressCity, chromiumAddressState, chromiumAddressZip, chromiumAddressCountry},
+	},
+	{
+		`SELECT guid, full_name, first_name, middle_name, last_name FROM autofill_profile_names`,
+		[]int{chromiumNameFull, chromiumNameFirst, chromiumNameMiddle, chromiumNameLast},
+	},
+	{`SELECT guid, email FROM autofill_profile_emails`, []int{chromiumEmailAddress}},
+	{`SELECT guid, number FROM autofill_profile_phones`, []int{chromiumPhoneWholeNumber}},
+}
+
+// Extract reads the addresses of Web Data, the fields of an address are stored as type tokens in
+// addresses in the current Chromium, in local_addresses and contact_info (the addresses of the account)
+// since Chromium 118, and in autofill_profiles with the names, emails and phones tables before.
+func (c *ChromiumAddress) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tables, err := chromiumTables(db)
+	if err != nil {
+		return err
+	}
+	// an address is kept once if a migrated Web Data still has the old tables
+	seen := make(map[string]bool)
+	for _, t := range chromiumAddressTables {
+		if !tables[t.table] {
+			continue
+		}
+		addresses, err := readChromiumAddresses(db, t.table, t.tokens)
+		if err != nil {
+			log.Errorf("read chromium %s error: %v", t.table, err)
+			continue
+		}
+		for _, a := range addresses {
+			if !seen[a.GUID] {
+				seen[a.GUID] = true
+				*c = append(*c, a)
+			}
+		}
+	}
+	if len(*c) == 0 && tables[chromiumLegacyProfiles] {
+		addresses, err := readChromiumLegacyProfiles(db)
+		if err != nil {
+			return err
+		}
+		*c = append(*c, addresses...)
+	}
+	sort.SliceStable(*c, func(i, j int) bool {
+		return (*c)[i].LastUsedDate.After((*c)[j].LastUsedDate)
+	})
+	return nil
+}
+
+func (c *ChromiumAddress) Name() string {
+	return "address"
+}
+
+func (c *ChromiumAddress) Len() int {
+	return len(*c)
+}
+
+func chromiumTables(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query(queryChromiumTables)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables[name] = true
+	}
+	return tables, rows.Err()
+}
+
+// chromiumProfile is an address of Chromium being read, with the values of its field types.
+type chromiumProfile struct {
+	guid              string
+	useCount, useDate int64
+	fields            map[int]string
+}
+
+// readChromiumProfiles reads the guid, use count and use date of the addresses.
+func readChromiumProfiles(db *sql.DB, table string) ([]*chromiumProfile, map[string]*chromiumProfile, error) {
+	rows, err := db.Query(fmt.Sprintf(queryChromiumAddress, table))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var profiles []*chromiumProfile
+	byGUID := make(map[string]*chromiumProfile)
+	for rows.Next() {
+		p := &chromiumProfile{fields: make(map[int]string)}
+		if err := rows.Scan(&p.guid, &p.useCount, &p.useDate); err != nil {
+			log.Warnf("scan chromium %s error: %v", table, err)
+			continue
+		}
+		profiles = append(profiles, p)
+		byGUID[p.guid] = p
+	}
+	return profiles, byGUID, rows.Err()
+}
+
+// readChromiumAddresses reads the addresses of the table and the values of their type tokens.
+func readChromiumAddresses(db *sql.DB, table, tokens string) ([]Address, error) {
+	profiles, byGUID, err := readChromiumProfiles(db, table)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(fmt.Sprintf(queryChromiumAddressTokens, tokens))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			guid, value string
+			fieldType   int
+		)
+		if err := rows.Scan(&guid, &fieldType, &value); err != nil {
+			log.Warnf("scan chromium %s type token error: %v", table, err)
+			continue
+		}
+		if p, ok := byGUID[guid]; ok {
+			p.fields[fieldType] = value
+		}
+	}
+	return chromiumAddresses(profiles), rows.Err()
+}
+
+// readChromiumLegacyProfiles reads the addresses of autofill_profiles and the tables of their
+// names, emails and phones, the first value of a field type is used.
+func readChromiumLegacyProfiles(db *sql.DB) ([]Address, error) {
+	profiles, byGUID, err := readChromiumProfiles(db, chromiumLegacyProfiles)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range chromiumLegacyProfileTables {
+		if err := readChromiumLegacyFields(db, t.query, t.fields, byGUID); err != nil {
+			log.Debugf("read chromium legacy profile fields error: %v", err)
+		}
+	}
+	return chromiumAddresses(profiles), nil
+}
+
+func readChromiumLegacyFields(db *sql.DB, query string, fields []int, byGUID map[string]*chromiumProfile) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var guid string
+		values := make([]sql.NullString, len(fields))
+		dest := []interface{}{&guid}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			log.Warnf("scan chromium legacy profile error: %v", err)
+			continue
+		}
+		p, ok := byGUID[guid]
+		if !ok {
+			continue
+		}
+		for i, field := range fields {
+			if _, exist := p.fields[field]; !exist && values[i].String != "" {
+				p.fields[field] = values[i].String
+			}
+		}
+	}
+	return rows.Err()
+}
+
+// chromiumAddresses converts the profiles to addresses, the full name and street address are
+// joined from their parts if they are missing.
+func chromiumAddresses(profiles []*chromiumProfile) []Address {
+	addresses := make([]Address, 0, len(profiles))
+	for _, p := range profiles {
+		fullName := p.fields[chromiumNameFull]
+		if fullName == "" {
+			fullName = joinFields(p.fields, " ", chromiumNameFirst, chromiumNameMiddle, chromiumNameLast)
+		}
+		street := p.fields[chromiumAddressStreet]
+		if street == "" {
+			street = joinFields(p.fields, "\n", chromiumAddressLine1, chromiumAddressLine2, chromiumAddressLine3)
+		}
+		addresses = append(addresses, Address{
+			GUID:          p.guid,
+			FullName:      fullName,
+			Organization:  p.fields[chromiumCompanyName],
+			StreetAddress: street,
+			City:          p.fields[chromiumAddressCity],
+			State:         p.fields[chromiumAddressState],
+			PostalCode:    p.fields[chromiumAddressZip],
+			Country:       p.fields[chromiumAddressCountry],
+			Phone:         p.fields[chromiumPhoneWholeNumber],
+			Email:         p.fields[chromiumEmailAddress],
+			UseCount:      p.useCount,
+			LastUsedDate:  typeutil.TimeStamp(p.useDate),
+		})
+	}
+	return addresses
+}
+
+func joinFields(fields map[int]string, sep string, fieldTypes ...int) string {
+	var parts []string
+	for _, t := range fieldTypes {
+		if v := fields[t]; v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,162 @@
+package address
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/internal/testutil"
+)
+
+const testAutofillProfiles = `{"version":1,"creditCards":[],"addresses":[
+	{"guid":"a1","version":1,"given-name":"John","additional-name":"Q","family-name":"Doe","organization":"Mozilla",
+	 "street-address":"331 E Evelyn Ave","address-level2":"Mountain View","address-level1":"CA","postal-code":"94041",
+	 "country":"US","tel":"+16509030800","email":"john@example.com","timeCreated":1700000000000,"timeLastUsed":1700000100000,"timesUsed":3},
+	{"guid":"a2","version":1,"name":"Jane Roe","street-address":"1 Main St","timeCreated":1700000000000,"timeLastUsed":1700000200000,"timesUsed":1},
+	{"guid":"a3","deleted":true}
+]}`
+
+func TestFirefoxAddress_Extract(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autofill-profiles.json")
+	require.NoError(t, os.WriteFile(path, []byte(testAutofillProfiles), 0o600))
+
+	var addresses FirefoxAddress
+	require.NoError(t, addresses.Extract(crypto.MasterKeys{}, path))
+	require.Len(t, addresses, 2)
+
+	// sorted by the last used date
+	assert.Equal(t, "a2", addresses[0].GUID)
+	assert.Equal(t, "Jane Roe", addresses[0].FullName)
+
+	a := addresses[1]
+	assert.Equal(t, "John Q Doe", a.FullName)
+	assert.Equal(t, "Mozilla", a.Organization)
+	assert.Equal(t, "331 E Evelyn Ave", a.StreetAddress)
+	assert.Equal(t, "Mountain View", a.City)
+	assert.Equal(t, "CA", a.State)
+	assert.Equal(t, "94041", a.PostalCode)
+	assert.Equal(t, "US", a.Country)
+	assert.Equal(t, "+16509030800", a.Phone)
+	assert.Equal(t, "john@example.com", a.Email)
+	assert.Equal(t, int64(3), a.UseCount)
+	assert.Equal(t, int64(1700000000), a.CreateDate.Unix())
+	assert.Equal(t, int64(1700000100), a.LastUsedDate.Unix())
+}
+
+func newWebData(t *testing.T, stmts ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Web Data")
+	testutil.NewSQLite(t, path, stmts...)
+	return path
+}
+
+func TestChromiumAddress_Extract(t *testing.T) {
+	path := newWebData(t,
+		`CREATE TABLE local_addresses (guid VARCHAR PRIMARY KEY, use_count INTEGER NOT NULL DEFAULT 0,
+			use_date INTEGER NOT NULL DEFAULT 0, date_modified INTEGER NOT NULL DEFAULT 0, language_code VARCHAR, label VARCHAR)`,
+		`CREATE TABLE local_addresses_type_tokens (guid VARCHAR, type INTEGER, value VARCHAR, verification_status INTEGER DEFAULT 0,
+			observations BLOB, PRIMARY KEY (guid, type))`,
+		`CREATE TABLE contact_info (guid VARCHAR PRIMARY KEY, use_count INTEGER NOT NULL DEFAULT 0,
+			use_date INTEGER NOT NULL DEFAULT 0, date_modified INTEGER NOT NULL DEFAULT 0, language_code VARCHAR, label VARCHAR)`,
+		`CREATE TABLE contact_info_type_tokens (guid VARCHAR, type INTEGER, value VARCHAR, verification_status INTEGER DEFAULT 0,
+			observations BLOB, PRIMARY KEY (guid, type))`,
+		`INSERT INTO local_addresses (guid, use_count, use_date) VALUES ('l1', 4, 1700000100)`,
+		`INSERT INTO local_addresses_type_tokens (guid, type, value) VALUES ('l1', 7, 'John Doe'), ('l1', 60, 'Google'),
+			('l1', 77, '1600 Amphitheatre Pkwy'), ('l1', 33, 'Mountain View'), ('l1', 34, 'CA'), ('l1', 35, '94043'),
+			('l1', 36, 'US'), ('l1', 14, '+16502530000'), ('l1', 9, 'john@example.com')`,
+		`INSERT INTO contact_info (guid, use_count, use_date) VALUES ('c1', 1, 1700000200)`,
+		`INSERT INTO contact_info_type_tokens (guid, type, value) VALUES ('c1', 3, 'Jane'), ('c1', 5, 'Roe'),
+			('c1', 30, '1 Main St'), ('c1', 31, 'Apt 2')`,
+	)
+	var addresses ChromiumAddress
+	require.NoError(t, addresses.Extract(crypto.MasterKeys{}, path))
+	require.Len(t, addresses, 2)
+
+	// sorted by the last used date, the name and street are joined from their parts
+	assert.Equal(t, "c1", addresses[0].GUID)
+	assert.Equal(t, "Jane Roe", addresses[0].FullName)
+	assert.Equal(t, "1 Main St\nApt 2", addresses[0].StreetAddress)
+
+	a := addresses[1]
+	assert.Equal(t, "John Doe", a.FullName)
+	assert.Equal(t, "Google", a.Organization)
+	assert.Equal(t, "1600 Amphitheatre Pkwy", a.StreetAddress)
+	assert.Equal(t, "Mountain View", a.City)
+	assert.Equal(t, "CA", a.State)
+	assert.Equal(t, "94043", a.PostalCode)
+	assert.Equal(t, "US", a.Country)
+	assert.Equal(t, "+16502530000", a.Phone)
+	assert.Equal(t, "john@example.com", a.Email)
+	assert.Equal(t, int64(4), a.UseCount)
+	assert.Equal(t, int64(1700000100), a.LastUsedDate.Unix())
+}
+
+func TestChromiumAddress_ExtractAddresses(t *testing.T) {
+	// the current Web Data keeps the local and account addresses together, a migrated one may still
+	// have the old tables with the same addresses
+	path := newWebData(t,
+		`CREATE TABLE addresses (guid VARCHAR PRIMARY KEY, use_count INTEGER NOT NULL DEFAULT 0, use_date INTEGER NOT NULL DEFAULT 0,
+			date_modified INTEGER NOT NULL DEFAULT 0, language_code VARCHAR, label VARCHAR, initial_creator_id INTEGER DEFAULT 0,
+			last_modifier_id INTEGER DEFAULT 0, record_type INTEGER)`,
+		`CREATE TABLE address_type_tokens (guid VARCHAR, type INTEGER, value VARCHAR, verification_status INTEGER DEFAULT 0,
+			observations BLOB, PRIMARY KEY (guid, type))`,
+		`CREATE TABLE local_addresses (guid VARCHAR PRIMARY KEY, use_count INTEGER NOT NULL DEFAULT 0,
+			use_date INTEGER NOT NULL DEFAULT 0, date_modified INTEGER NOT NULL DEFAULT 0, language_code VARCHAR, label VARCHAR)`,
+		`CREATE TABLE local_addresses_type_tokens (guid VARCHAR, type INTEGER, value VARCHAR, verification_status INTEGER DEFAULT 0,
+			observations BLOB, PRIMARY KEY (guid, type))`,
+		`INSERT INTO addresses (guid, use_count, use_date, record_type) VALUES ('a1', 3, 1700000300, 0), ('a2', 1, 1700000000, 1)`,
+		`INSERT INTO address_type_tokens (guid, type, value) VALUES ('a1', 7, 'John Doe'), ('a1', 30, '1 Main St'),
+			('a1', 31, 'Building 4'), ('a1', 80, 'Apt 2'), ('a1', 33, 'Springfield'), ('a2', 7, 'Jane Roe')`,
+		`INSERT INTO local_addresses (guid, use_count, use_date) VALUES ('a1', 3, 1700000300)`,
+		`INSERT INTO local_addresses_type_tokens (guid, type, value) VALUES ('a1', 7, 'John Doe')`,
+	)
+	var addresses ChromiumAddress
+	require.NoError(t, addresses.Extract(crypto.MasterKeys{}, path))
+	require.Len(t, addresses, 2)
+	assert.Equal(t, "a1", addresses[0].GUID)
+	assert.Equal(t, "John Doe", addresses[0].FullName)
+	assert.Equal(t, "1 Main St\nBuilding 4\nApt 2", addresses[0].StreetAddress)
+	assert.Equal(t, "Springfield", addresses[0].City)
+	assert.Equal(t, int64(3), addresses[0].UseCount)
+	assert.Equal(t, "Jane Roe", addresses[1].FullName)
+}
+
+func TestChromiumAddress_ExtractLegacy(t *testing.T) {
+	path := newWebData(t,
+		`CREATE TABLE autofill_profiles (guid VARCHAR PRIMARY KEY, company_name VARCHAR, street_address VARCHAR,
+			dependent_locality VARCHAR, city VARCHAR, state VARCHAR, zipcode VARCHAR, sorting_code VARCHAR, country_code VARCHAR,
+			date_modified INTEGER NOT NULL DEFAULT 0, origin VARCHAR DEFAULT '', language_code VARCHAR,
+			use_count INTEGER NOT NULL DEFAULT 0, use_date INTEGER NOT NULL DEFAULT 0)`,
+		`CREATE TABLE autofill_profile_names (guid VARCHAR, first_name VARCHAR, middle_name VARCHAR, last_name VARCHAR,
+			full_name VARCHAR)`,
+		`CREATE TABLE autofill_profile_emails (guid VARCHAR, email VARCHAR)`,
+		`CREATE TABLE autofill_profile_phones (guid VARCHAR, number VARCHAR)`,
+		`INSERT INTO autofill_profiles (guid, company_name, street_address, city, state, zipcode, country_code, use_count, use_date)
+			VALUES ('p1', 'Acme', '1 Main St', 'Springfield', 'IL', '62701', 'US', 2, 1600000000)`,
+		`INSERT INTO autofill_profile_names VALUES ('p1', 'John', '', 'Doe', '')`,
+		`INSERT INTO autofill_profile_emails VALUES ('p1', 'john@example.com'), ('p1', 'doe@example.com')`,
+		`INSERT INTO autofill_profile_phones VALUES ('p1', '+12175550100')`,
+	)
+	var addresses ChromiumAddress
+	require.NoError(t, addresses.Extract(crypto.MasterKeys{}, path))
+	require.Len(t, addresses, 1)
+	assert.Equal(t, Address{
+		GUID:          "p1",
+		FullName:      "John Doe",
+		Organization:  "Acme",
+		StreetAddress: "1 Main St",
+		City:          "Springfield",
+		State:         "IL",
+		PostalCode:    "62701",
+		Country:       "US",
+		Phone:         "+12175550100",
+		Email:         "john@example.com",
+		UseCount:      2,
+		LastUsedDate:  addresses[0].LastUsedDate,
+	}, addresses[0])
+	assert.Equal(t, int64(1600000000), addresses[0].LastUsedDate.Unix())
+}
@@ -0,0 +1,139 @@
+package autofill
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	// import sqlite3 driver
+	_ "modernc.org/sqlite"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/extractor"
+	"github.com/moond4rk/hackbrowserdata/log"
+	"github.com/moond4rk/hackbrowserdata/types"
+	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
+)
+
+func init() {
+	extractor.RegisterExtractor(types.ChromiumAutofill, func() extractor.Extractor {
+		return new(ChromiumAutofill)
+	})
+	extractor.RegisterExtractor(types.FirefoxFormHistory, func() extractor.Extractor {
+		return new(FirefoxFormHistory)
+	})
+}
+
+// Entry is a value entered in a form field, Name is the name or id of the field, eg: q, email
+type Entry struct {
+	Name         string
+	Value        string
+	Count        int64
+	CreateDate   time.Time
+	LastUsedDate time.Time
+}
+
+type ChromiumAutofill []Entry
+
+const (
+	queryChromiumAutofill = `SELECT name, value, count, date_created, date_last_used FROM autofill`
+)
+
+func (c *ChromiumAutofill) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(queryChromiumAutofill)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			name, value       string
+			count             int64
+			created, lastUsed int64
+		)
+		if err := rows.Scan(&name, &value, &count, &created, &lastUsed); err != nil {
+			log.Warnf("scan chromium autofill error: %v", err)
+			continue
+		}
+		*c = append(*c, Entry{
+			Name:         name,
+			Value:        value,
+			Count:        count,
+			CreateDate:   typeutil.TimeStamp(created),
+			LastUsedDate: typeutil.TimeStamp(lastUsed),
+		})
+	}
+	sort.Slice(*c, func(i, j int) bool {
+		return (*c)[i].LastUsedDate.After((*c)[j].LastUsedDate)
+	})
+	return rows.Err()
+}
+
+func (c *ChromiumAutofill) Name() string {
+	return "autofill"
+}
+
+func (c *ChromiumAutofill) Len() int {
+	return len(*c)
+}
+
+type FirefoxFormHistory []Entry
+
+const (
+	queryFirefoxFormHistory = `SELECT fieldname, value, COALESCE(timesUsed, 0), COALESCE(firstUsed, 0), COALESCE(lastUsed, 0)
+		FROM moz_formhistory`
+	closeJournalMode = `PRAGMA journal_mode=off`
+)
+
+func (f *FirefoxFormHistory) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(closeJournalMode); err != nil {
+		return err
+	}
+	rows, err := db.Query(queryFirefoxFormHistory)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			name, value       string
+			count             int64
+			created, lastUsed int64
+		)
+		if err := rows.Scan(&name, &value, &count, &created, &lastUsed); err != nil {
+			log.Warnf("scan firefox form history error: %v", err)
+			continue
+		}
+		*f = append(*f, Entry{
+			Name:         name,
+			Value:        value,
+			Count:        count,
+			CreateDate:   typeutil.TimeStamp(created / 1000000),
+			LastUsedDate: typeutil.TimeStamp(lastUsed / 1000000),
+		})
+	}
+	sort.Slice(*f, func(i, j int) bool {
+		return (*f)[i].LastUsedDate.After((*f)[j].LastUsedDate)
+	})
+	return rows.Err()
+}
+
+func (f *FirefoxFormHistory) Name() string {
+	return "autofill"
+}
+
+func (f *FirefoxFormHistory) Len() int {
+	return len(*f)
+}
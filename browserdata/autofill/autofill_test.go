@@ -0,0 +1,59 @@
+package autofill
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/internal/testutil"
+	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
+)
+
+func TestChromiumAutofill_Extract(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Web Data")
+	testutil.NewSQLite(t, path,
+		`CREATE TABLE autofill (name VARCHAR, value VARCHAR, value_lower VARCHAR, date_created INTEGER DEFAULT 0,
+			date_last_used INTEGER DEFAULT 0, count INTEGER DEFAULT 1, PRIMARY KEY (name, value))`,
+		`INSERT INTO autofill VALUES ('email', 'john@example.com', 'john@example.com', 1700000000, 1700000300, 5)`,
+		`INSERT INTO autofill VALUES ('q', 'hack browser data', 'hack browser data', 1700000100, 1700000100, 1)`,
+	)
+
+	var c ChromiumAutofill
+	require.NoError(t, c.Extract(crypto.MasterKeys{}, path))
+	assert.Equal(t, ChromiumAutofill{
+		{
+			Name: "email", Value: "john@example.com", Count: 5,
+			CreateDate: typeutil.TimeStamp(1700000000), LastUsedDate: typeutil.TimeStamp(1700000300),
+		},
+		{
+			Name: "q", Value: "hack browser data", Count: 1,
+			CreateDate: typeutil.TimeStamp(1700000100), LastUsedDate: typeutil.TimeStamp(1700000100),
+		},
+	}, c)
+}
+
+func TestFirefoxFormHistory_Extract(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "formhistory.sqlite")
+	testutil.NewSQLite(t, path,
+		`CREATE TABLE moz_formhistory (id INTEGER PRIMARY KEY, fieldname TEXT NOT NULL, value TEXT NOT NULL,
+			timesUsed INTEGER, firstUsed INTEGER, lastUsed INTEGER, guid TEXT)`,
+		`INSERT INTO moz_formhistory VALUES (1, 'searchbar-history', 'hack browser data', 2, 1700000000000000, 1700000100000000, 'g1')`,
+		`INSERT INTO moz_formhistory VALUES (2, 'email', 'john@example.com', 7, 1600000000000000, 1700000200000000, 'g2')`,
+	)
+
+	var f FirefoxFormHistory
+	require.NoError(t, f.Extract(crypto.MasterKeys{}, path))
+	assert.Equal(t, FirefoxFormHistory{
+		{
+			Name: "email", Value: "john@example.com", Count: 7,
+			CreateDate: typeutil.TimeStamp(1600000000), LastUsedDate: typeutil.TimeStamp(1700000200),
+		},
+		{
+			Name: "searchbar-history", Value: "hack browser data", Count: 2,
+			CreateDate: typeutil.TimeStamp(1700000000), LastUsedDate: typeutil.TimeStamp(1700000100),
+		},
+	}, f)
+}
@@ -2,13 +2,13 @@ package bookmark
 
 import (
 	"database/sql"
-	"os"
 	"sort"
 	"time"
 
 	"github.com/tidwall/gjson"
 	_ "modernc.org/sqlite" // import sqlite3 driver
 
+	"github.com/moond4rk/hackbrowserdata/crypto"
 	"github.com/moond4rk/hackbrowserdata/extractor"
 	"github.com/moond4rk/hackbrowserdata/log"
 	"github.com/moond4rk/hackbrowserdata/types"
@@ -25,9 +25,10 @@ func init() {
 	})
 }
 
-type ChromiumBookmark []bookmark
+type ChromiumBookmark []Bookmark
 
-type bookmark struct {
+// Bookmark is a bookmark or a bookmark folder, Type is "url" or "folder".
+type Bookmark struct {
 	ID        int64
 	Name      string
 	Type      string
@@ -35,12 +36,11 @@ type bookmark struct {
 	DateAdded time.Time
 }
 
-func (c *ChromiumBookmark) Extract(_ []byte) error {
-	bookmarks, err := fileutil.ReadFile(types.ChromiumBookmark.TempFilename())
+func (c *ChromiumBookmark) Extract(_ crypto.MasterKeys, path string) error {
+	bookmarks, err := fileutil.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(types.ChromiumBookmark.TempFilename())
 	r := gjson.Parse(bookmarks)
 	if r.Exists() {
 		roots := r.Get("roots")
@@ -69,7 +69,7 @@ func getBookmarkChildren(value gjson.Result, w *ChromiumBookmark) (children gjso
 	nodeType := value.Get(bookmarkType)
 	children = value.Get(bookmarkChildren)
 
-	bm := bookmark{
+	bm := Bookmark{
 		ID:        value.Get(bookmarkID).Int(),
 		Name:      value.Get(bookmarkName).String(),
 		URL:       value.Get(bookmarkURL).String(),
@@ -95,19 +95,18 @@ func (c *ChromiumBookmark) Len() int {
 	return len(*c)
 }
 
-type FirefoxBookmark []bookmark
+type FirefoxBookmark []Bookmark
 
 const (
 	queryFirefoxBookMark = `SELECT id, url, type, dateAdded, title FROM (SELECT * FROM moz_bookmarks INNER JOIN moz_places ON moz_bookmarks.fk=moz_places.id)`
 	closeJournalMode     = `PRAGMA journal_mode=off`
 )
 
-func (f *FirefoxBookmark) Extract(_ []byte) error {
-	db, err := sql.Open("sqlite", types.FirefoxBookmark.TempFilename())
+func (f *FirefoxBookmark) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(types.FirefoxBookmark.TempFilename())
 	defer db.Close()
 	_, err = db.Exec(closeJournalMode)
 	if err != nil {
@@ -126,7 +125,7 @@ func (f *FirefoxBookmark) Extract(_ []byte) error {
 		if err = rows.Scan(&id, &url, &bt, &dateAdded, &title); err != nil {
 			log.Errorf("scan bookmark error: %v", err)
 		}
-		*f = append(*f, bookmark{
+		*f = append(*f, Bookmark{
 			ID:        id,
 			Name:      title,
 			Type:      linkType(bt),
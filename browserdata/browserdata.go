@@ -1,6 +1,7 @@
 package browserdata
 
 import (
+	"github.com/moond4rk/hackbrowserdata/crypto"
 	"github.com/moond4rk/hackbrowserdata/extractor"
 	"github.com/moond4rk/hackbrowserdata/log"
 	"github.com/moond4rk/hackbrowserdata/types"
@@ -9,26 +10,76 @@ import (
 
 type BrowserData struct {
 	extractors map[types.DataType]extractor.Extractor
+	keys       map[types.DataType]crypto.MasterKeys
+	valueLimit ValueLimit
+}
+
+// ValueLimit limits the length of the values kept in the result, eg: the localStorage values,
+// a longer value is replaced by a note and exported to Dir if it's set, a MaxLength of 0 means no limit.
+type ValueLimit struct {
+	MaxLength int
+	Dir       string
+}
+
+// valueLimiter is an extractor whose values are limited by the ValueLimit.
+type valueLimiter interface {
+	LimitValues(maxLength int, dir string)
 }
 
 func New(items []types.DataType) *BrowserData {
 	bd := &BrowserData{
 		extractors: make(map[types.DataType]extractor.Extractor),
+		keys:       make(map[types.DataType]crypto.MasterKeys),
 	}
 	bd.addExtractors(items)
 	return bd
 }
 
-func (d *BrowserData) Recovery(masterKey []byte) error {
-	for _, source := range d.extractors {
-		if err := source.Extract(masterKey); err != nil {
+// SetKeys replaces the master keys passed to the extractor of the data type by Recovery,
+// eg: the Firefox credit cards are encrypted by the key of the OS key store instead of NSS.
+func (d *BrowserData) SetKeys(dataType types.DataType, keys crypto.MasterKeys) {
+	d.keys[dataType] = keys
+}
+
+// SetValueLimit sets the limit of the values applied by Recovery to the extractors supporting it.
+func (d *BrowserData) SetValueLimit(limit ValueLimit) {
+	d.valueLimit = limit
+}
+
+// Recovery extracts every data type from its copied file in paths,
+// data types without a copied file are skipped.
+func (d *BrowserData) Recovery(masterKeys crypto.MasterKeys, paths map[types.DataType]string) error {
+	for dataType, source := range d.extractors {
+		path, ok := paths[dataType]
+		if !ok {
+			log.Debugf("skip %s, data file not found", dataType)
+			continue
+		}
+		keys := masterKeys
+		if k, ok := d.keys[dataType]; ok {
+			keys = k
+		}
+		if err := source.Extract(keys, path); err != nil {
 			log.Errorf("parse %s error: %v", source.Name(), err)
 			continue
 		}
+		if limiter, ok := source.(valueLimiter); ok && d.valueLimit.MaxLength > 0 {
+			limiter.LimitValues(d.valueLimit.MaxLength, d.valueLimit.Dir)
+		}
 	}
 	return nil
 }
 
+// Extractors returns the extractors of the browser data, call it after Recovery
+// to read the extracted records, each extractor is a slice of typed records.
+func (d *BrowserData) Extractors() []extractor.Extractor {
+	extractors := make([]extractor.Extractor, 0, len(d.extractors))
+	for _, source := range d.extractors {
+		extractors = append(extractors, source)
+	}
+	return extractors
+}
+
 func (d *BrowserData) Output(dir, browserName, flag string) {
 	output := newOutPutter(flag)
 
@@ -0,0 +1,50 @@
+package browserdata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/extractor"
+	"github.com/moond4rk/hackbrowserdata/types"
+)
+
+type testStorage []string
+
+func (s *testStorage) Extract(_ crypto.MasterKeys, _ string) error {
+	*s = append(*s, "short", "too long value")
+	return nil
+}
+
+func (s *testStorage) Name() string {
+	return "localStorage"
+}
+
+func (s *testStorage) Len() int {
+	return len(*s)
+}
+
+func (s *testStorage) LimitValues(maxLength int, _ string) {
+	for i, v := range *s {
+		if len(v) > maxLength {
+			(*s)[i] = "limited"
+		}
+	}
+}
+
+func TestBrowserData_RecoveryValueLimit(t *testing.T) {
+	t.Parallel()
+	paths := map[types.DataType]string{types.ChromiumLocalStorage: "Local Storage"}
+
+	storage := new(testStorage)
+	d := &BrowserData{extractors: map[types.DataType]extractor.Extractor{types.ChromiumLocalStorage: storage}}
+	assert.NoError(t, d.Recovery(crypto.MasterKeys{}, paths))
+	assert.Equal(t, testStorage{"short", "too long value"}, *storage)
+
+	storage = new(testStorage)
+	d = &BrowserData{extractors: map[types.DataType]extractor.Extractor{types.ChromiumLocalStorage: storage}}
+	d.SetValueLimit(ValueLimit{MaxLength: 8})
+	assert.NoError(t, d.Recovery(crypto.MasterKeys{}, paths))
+	assert.Equal(t, testStorage{"short", "limited"}, *storage)
+}
@@ -1,11 +1,13 @@
 package cookie
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"database/sql"
-	"os"
 	"sort"
 	"time"
 
+	"github.com/tidwall/gjson"
 	// import sqlite3 driver
 	_ "modernc.org/sqlite"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/moond4rk/hackbrowserdata/extractor"
 	"github.com/moond4rk/hackbrowserdata/log"
 	"github.com/moond4rk/hackbrowserdata/types"
+	"github.com/moond4rk/hackbrowserdata/utils/lz4util"
 	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
 )
 
@@ -23,11 +26,15 @@ func init() {
 	extractor.RegisterExtractor(types.FirefoxCookie, func() extractor.Extractor {
 		return new(FirefoxCookie)
 	})
+	extractor.RegisterExtractor(types.FirefoxSessionCookie, func() extractor.Extractor {
+		return new(FirefoxSessionCookie)
+	})
 }
 
-type ChromiumCookie []cookie
+type ChromiumCookie []Cookie
 
-type cookie struct {
+// Cookie is a browser cookie, Value holds the decrypted cookie value.
+type Cookie struct {
 	Host         string
 	Path         string
 	KeyName      string
@@ -39,19 +46,35 @@ type cookie struct {
 	IsPersistent bool
 	CreateDate   time.Time
 	ExpireDate   time.Time
+	// EncryptScheme is the scheme of the Chromium encrypted value, eg: v10, v11, dpapi
+	EncryptScheme string
+	// DecryptFailed is set when the value can't be decrypted
+	DecryptFailed bool
+	// IntegrityFailed is set when the host hash prefixed to the decrypted value doesn't match the host
+	IntegrityFailed bool
 }
 
 const (
-	queryChromiumCookie = `SELECT name, encrypted_value, host_key, path, creation_utc, expires_utc, is_secure, is_httponly, has_expires, is_persistent FROM cookies`
+	queryChromiumCookie        = `SELECT name, encrypted_value, host_key, path, creation_utc, expires_utc, is_secure, is_httponly, has_expires, is_persistent FROM cookies`
+	queryChromiumCookieVersion = `SELECT value FROM meta WHERE key = 'version'`
 )
 
-func (c *ChromiumCookie) Extract(masterKey []byte) error {
-	db, err := sql.Open("sqlite", types.ChromiumCookie.TempFilename())
+// hostHashVersion is the cookie database version since which Chromium prepends
+// the SHA256 of host_key to the plaintext of encrypted_value
+const hostHashVersion = 24
+
+func (c *ChromiumCookie) Extract(keys crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(types.ChromiumCookie.TempFilename())
 	defer db.Close()
+
+	var version int
+	if err := db.QueryRow(queryChromiumCookieVersion).Scan(&version); err != nil {
+		log.Debugf("query chromium cookie version error: %v", err)
+	}
+
 	rows, err := db.Query(queryChromiumCookie)
 	if err != nil {
 		return err
@@ -68,7 +91,7 @@ func (c *ChromiumCookie) Extract(masterKey []byte) error {
 			log.Errorf("scan chromium cookie error: %v", err)
 		}
 
-		cookie := cookie{
+		data := Cookie{
 			KeyName:      key,
 			Host:         host,
 			Path:         path,
@@ -81,17 +104,26 @@ func (c *ChromiumCookie) Extract(masterKey []byte) error {
 			ExpireDate:   typeutil.TimeEpoch(expireDate),
 		}
 		if len(encryptValue) > 0 {
-			if len(masterKey) == 0 {
+			data.EncryptScheme = crypto.EncryptScheme(encryptValue)
+			if keys.IsEmpty() {
 				value, err = crypto.DecryptWithDPAPI(encryptValue)
 			} else {
-				value, err = crypto.DecryptWithChromium(masterKey, encryptValue)
+				value, err = crypto.DecryptWithChromium(keys, encryptValue)
 			}
 			if err != nil {
-				log.Errorf("decrypt chromium cookie error: %v", err)
+				data.DecryptFailed = true
+				log.Errorf("decrypt chromium cookie %s of %s with %s error: %v", key, host, data.EncryptScheme, err)
+			}
+			if err == nil && version >= hostHashVersion {
+				var ok bool
+				if value, ok = trimHostHash(host, value); !ok {
+					data.IntegrityFailed = true
+					log.Warnf("chromium cookie %s of %s failed the host hash check", key, host)
+				}
 			}
 		}
-		cookie.Value = string(value)
-		*c = append(*c, cookie)
+		data.Value = string(value)
+		*c = append(*c, data)
 	}
 	sort.Slice(*c, func(i, j int) bool {
 		return (*c)[i].CreateDate.After((*c)[j].CreateDate)
@@ -99,6 +131,16 @@ func (c *ChromiumCookie) Extract(masterKey []byte) error {
 	return nil
 }
 
+// trimHostHash trims the SHA256 of the host prefixed to the decrypted value,
+// it reports false if the prefix doesn't match the hash of the host.
+func trimHostHash(host string, value []byte) ([]byte, bool) {
+	hash := sha256.Sum256([]byte(host))
+	if len(value) < len(hash) {
+		return value, false
+	}
+	return value[len(hash):], bytes.Equal(value[:len(hash)], hash[:])
+}
+
 func (c *ChromiumCookie) Name() string {
 	return "cookie"
 }
@@ -107,18 +149,17 @@ func (c *ChromiumCookie) Len() int {
 	return len(*c)
 }
 
-type FirefoxCookie []cookie
+type FirefoxCookie []Cookie
 
 const (
 	queryFirefoxCookie = `SELECT name, value, host, path, creationTime, expiry, isSecure, isHttpOnly FROM moz_cookies`
 )
 
-func (f *FirefoxCookie) Extract(_ []byte) error {
-	db, err := sql.Open("sqlite", types.FirefoxCookie.TempFilename())
+func (f *FirefoxCookie) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(types.FirefoxCookie.TempFilename())
 	defer db.Close()
 
 	rows, err := db.Query(queryFirefoxCookie)
@@ -135,7 +176,7 @@ func (f *FirefoxCookie) Extract(_ []byte) error {
 		if err = rows.Scan(&name, &value, &host, &path, &creationTime, &expiry, &isSecure, &isHTTPOnly); err != nil {
 			log.Errorf("scan firefox cookie error: %v", err)
 		}
-		*f = append(*f, cookie{
+		*f = append(*f, Cookie{
 			KeyName:    name,
 			Host:       host,
 			Path:       path,
@@ -160,3 +201,40 @@ func (f *FirefoxCookie) Name() string {
 func (f *FirefoxCookie) Len() int {
 	return len(*f)
 }
+
+type FirefoxSessionCookie []Cookie
+
+// Extract reads the session cookies of sessionstore.jsonlz4, which Firefox keeps in the session
+// instead of cookies.sqlite, the older versions keep them in each window.
+// eg: "cookies":[{"host":".github.com","value":"...","path":"/","name":"_gh_sess","secure":true,"httponly":true}]
+func (f *FirefoxSessionCookie) Extract(_ crypto.MasterKeys, path string) error {
+	b, err := lz4util.ReadMozLz4(path)
+	if err != nil {
+		return err
+	}
+	state := gjson.ParseBytes(b)
+	cookies := state.Get("cookies").Array()
+	for _, window := range state.Get("windows").Array() {
+		cookies = append(cookies, window.Get("cookies").Array()...)
+	}
+	for _, c := range cookies {
+		*f = append(*f, Cookie{
+			KeyName:    c.Get("name").String(),
+			Host:       c.Get("host").String(),
+			Path:       c.Get("path").String(),
+			Value:      c.Get("value").String(),
+			IsSecure:   c.Get("secure").Bool(),
+			IsHTTPOnly: c.Get("httponly").Bool(),
+		})
+	}
+	return nil
+}
+
+// Name is distinct from FirefoxCookie, so the session cookies are exported to their own file.
+func (f *FirefoxSessionCookie) Name() string {
+	return "sessionCookie"
+}
+
+func (f *FirefoxSessionCookie) Len() int {
+	return len(*f)
+}
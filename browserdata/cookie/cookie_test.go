@@ -0,0 +1,51 @@
+package cookie
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/utils/lz4util"
+)
+
+func TestTrimHostHash(t *testing.T) {
+	hash := sha256.Sum256([]byte(".github.com"))
+	testCases := []struct {
+		name  string
+		host  string
+		value []byte
+		want  []byte
+		ok    bool
+	}{
+		{name: "matched", host: ".github.com", value: append(hash[:], "moond4rk"...), want: []byte("moond4rk"), ok: true},
+		{name: "empty value", host: ".github.com", value: hash[:], want: []byte{}, ok: true},
+		{name: "mismatched host", host: "github.com", value: append(hash[:], "moond4rk"...), want: []byte("moond4rk"), ok: false},
+		{name: "too short", host: ".github.com", value: []byte("moond4rk"), want: []byte("moond4rk"), ok: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, ok := trimHostHash(tc.host, tc.value)
+			assert.Equal(t, tc.want, value)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+func TestFirefoxSessionCookie_Extract(t *testing.T) {
+	session := `{"windows":[{"tabs":[],"cookies":[{"host":"example.com","name":"old","value":"1","path":"/"}]}],
+	"cookies":[{"host":".github.com","value":"moond4rk","path":"/","name":"_gh_sess","secure":true,"httponly":true,"sameSite":1}]}`
+	path := filepath.Join(t.TempDir(), "sessionstore.jsonlz4")
+	require.NoError(t, os.WriteFile(path, lz4util.EncodeMozLz4([]byte(session)), 0o600))
+
+	var cookies FirefoxSessionCookie
+	require.NoError(t, cookies.Extract(crypto.MasterKeys{}, path))
+	require.Len(t, cookies, 2)
+	assert.Equal(t, Cookie{Host: ".github.com", Path: "/", KeyName: "_gh_sess", Value: "moond4rk", IsSecure: true, IsHTTPOnly: true}, cookies[0])
+	assert.Equal(t, "example.com", cookies[1].Host)
+	assert.False(t, cookies[1].IsPersistent)
+}
@@ -2,8 +2,8 @@ package creditcard
 
 import (
 	"database/sql"
-	"os"
 
+	"github.com/tidwall/gjson"
 	// import sqlite3 driver
 	_ "modernc.org/sqlite"
 
@@ -11,6 +11,7 @@ import (
 	"github.com/moond4rk/hackbrowserdata/extractor"
 	"github.com/moond4rk/hackbrowserdata/log"
 	"github.com/moond4rk/hackbrowserdata/types"
+	"github.com/moond4rk/hackbrowserdata/utils/fileutil"
 )
 
 func init() {
@@ -20,11 +21,15 @@ func init() {
 	extractor.RegisterExtractor(types.YandexCreditCard, func() extractor.Extractor {
 		return new(YandexCreditCard)
 	})
+	extractor.RegisterExtractor(types.FirefoxCreditCard, func() extractor.Extractor {
+		return new(FirefoxCreditCard)
+	})
 }
 
-type ChromiumCreditCard []card
+type ChromiumCreditCard []Card
 
-type card struct {
+// Card is a saved credit card, CardNumber holds the decrypted card number.
+type Card struct {
 	GUID            string
 	Name            string
 	ExpirationYear  string
@@ -32,18 +37,21 @@ type card struct {
 	CardNumber      string
 	Address         string
 	NickName        string
+	// EncryptScheme is the scheme of the encrypted card number, eg: v10, v11, dpapi, yandex, oskeystore
+	EncryptScheme string
+	// DecryptFailed is set when the card number can't be decrypted
+	DecryptFailed bool
 }
 
 const (
 	queryChromiumCredit = `SELECT guid, name_on_card, expiration_month, expiration_year, card_number_encrypted, billing_address_id, nickname FROM credit_cards`
 )
 
-func (c *ChromiumCreditCard) Extract(masterKey []byte) error {
-	db, err := sql.Open("sqlite", types.ChromiumCreditCard.TempFilename())
+func (c *ChromiumCreditCard) Extract(keys crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(types.ChromiumCreditCard.TempFilename())
 	defer db.Close()
 
 	rows, err := db.Query(queryChromiumCredit)
@@ -59,7 +67,7 @@ func (c *ChromiumCreditCard) Extract(masterKey []byte) error {
 		if err := rows.Scan(&guid, &name, &month, &year, &encryptValue, &address, &nickname); err != nil {
 			log.Errorf("scan chromium credit card error: %v", err)
 		}
-		ccInfo := card{
+		ccInfo := Card{
 			GUID:            guid,
 			Name:            name,
 			ExpirationMonth: month,
@@ -68,13 +76,15 @@ func (c *ChromiumCreditCard) Extract(masterKey []byte) error {
 			NickName:        nickname,
 		}
 		if len(encryptValue) > 0 {
-			if len(masterKey) == 0 {
+			ccInfo.EncryptScheme = crypto.EncryptScheme(encryptValue)
+			if keys.IsEmpty() {
 				value, err = crypto.DecryptWithDPAPI(encryptValue)
 			} else {
-				value, err = crypto.DecryptWithChromium(masterKey, encryptValue)
+				value, err = crypto.DecryptWithChromium(keys, encryptValue)
 			}
 			if err != nil {
-				log.Errorf("decrypt chromium credit card error: %v", err)
+				ccInfo.DecryptFailed = true
+				log.Errorf("decrypt chromium credit card %s with %s error: %v", guid, ccInfo.EncryptScheme, err)
 			}
 		}
 
@@ -92,47 +102,57 @@ func (c *ChromiumCreditCard) Len() int {
 	return len(*c)
 }
 
-type YandexCreditCard []card
+type YandexCreditCard []Card
+
+const (
+	queryYandexCredit = `SELECT guid, public_data, private_data FROM records`
+)
 
-func (c *YandexCreditCard) Extract(masterKey []byte) error {
-	db, err := sql.Open("sqlite", types.YandexCreditCard.TempFilename())
+// Extract decrypts the private data of the cards with the data key of the profile, which is sealed
+// by the master key in the meta table, the GUID of the card is the additional data of AES-GCM.
+// eg: public_data {"card_holder":"...","card_title":"...","expire_date_month":"12","expire_date_year":"2030"}
+// private_data {"full_card_number":"...","pin_code":"...","secret_comment":"..."}
+func (c *YandexCreditCard) Extract(keys crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(types.YandexCreditCard.TempFilename())
 	defer db.Close()
-	rows, err := db.Query(queryChromiumCredit)
+
+	dataKey, err := crypto.YandexDataKey(db, keys)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query(queryYandexCredit)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var (
-			name, month, year, guid, address, nickname string
-			value, encryptValue                        []byte
+			guid, publicData string
+			privateData      []byte
 		)
-		if err := rows.Scan(&guid, &name, &month, &year, &encryptValue, &address, &nickname); err != nil {
-			log.Errorf("scan chromium credit card error: %v", err)
+		if err := rows.Scan(&guid, &publicData, &privateData); err != nil {
+			log.Errorf("scan yandex credit card error: %v", err)
 		}
-		ccInfo := card{
+		ccInfo := Card{
 			GUID:            guid,
-			Name:            name,
-			ExpirationMonth: month,
-			ExpirationYear:  year,
-			Address:         address,
-			NickName:        nickname,
+			Name:            gjson.Get(publicData, "card_holder").String(),
+			NickName:        gjson.Get(publicData, "card_title").String(),
+			ExpirationMonth: gjson.Get(publicData, "expire_date_month").String(),
+			ExpirationYear:  gjson.Get(publicData, "expire_date_year").String(),
 		}
-		if len(encryptValue) > 0 {
-			if len(masterKey) == 0 {
-				value, err = crypto.DecryptWithDPAPI(encryptValue)
-			} else {
-				value, err = crypto.DecryptWithChromium(masterKey, encryptValue)
-			}
+		if len(privateData) > 0 {
+			ccInfo.EncryptScheme = crypto.SchemeYandex
+			value, err := crypto.DecryptWithYandex(dataKey, privateData, []byte(guid))
 			if err != nil {
-				log.Errorf("decrypt chromium credit card error: %v", err)
+				ccInfo.DecryptFailed = true
+				log.Errorf("decrypt yandex credit card %s error: %v", guid, err)
 			}
+			ccInfo.CardNumber = gjson.GetBytes(value, "full_card_number").String()
 		}
-		ccInfo.CardNumber = string(value)
 		*c = append(*c, ccInfo)
 	}
 	return nil
@@ -145,3 +165,53 @@ func (c *YandexCreditCard) Name() string {
 func (c *YandexCreditCard) Len() int {
 	return len(*c)
 }
+
+type FirefoxCreditCard []Card
+
+// Extract decrypts the cards of autofill-profiles.json with the key of the OS key store, which
+// Firefox keeps in the Secret Service on Linux, the Keychain on macOS and the Credential Manager on Windows,
+// it's the V10 of keys. eg: {"creditCards":[{"guid":"...","cc-name":"...","cc-number-encrypted":"...","cc-exp-month":12,"cc-exp-year":2030}]}
+func (c *FirefoxCreditCard) Extract(keys crypto.MasterKeys, path string) error {
+	osKeyStoreKey := keys.V10
+	content, err := fileutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(osKeyStoreKey) == 0 {
+		log.Warnf("os key store key not found, firefox credit card numbers are not decrypted")
+	}
+	for _, v := range gjson.Get(content, "creditCards").Array() {
+		// the deleted cards are kept as tombstones for sync
+		if v.Get("deleted").Bool() {
+			continue
+		}
+		guid := v.Get("guid").String()
+		ccInfo := Card{
+			GUID:            guid,
+			Name:            v.Get("cc-name").String(),
+			ExpirationMonth: v.Get("cc-exp-month").String(),
+			ExpirationYear:  v.Get("cc-exp-year").String(),
+		}
+		if encrypted := v.Get("cc-number-encrypted").String(); encrypted != "" {
+			ccInfo.EncryptScheme = crypto.SchemeOSKeyStore
+			if len(osKeyStoreKey) == 0 {
+				ccInfo.DecryptFailed = true
+			} else if value, err := crypto.DecryptWithOSKeyStore(osKeyStoreKey, encrypted); err != nil {
+				ccInfo.DecryptFailed = true
+				log.Errorf("decrypt firefox credit card %s error: %v", guid, err)
+			} else {
+				ccInfo.CardNumber = string(value)
+			}
+		}
+		*c = append(*c, ccInfo)
+	}
+	return nil
+}
+
+func (c *FirefoxCreditCard) Name() string {
+	return "creditcard"
+}
+
+func (c *FirefoxCreditCard) Len() int {
+	return len(*c)
+}
@@ -0,0 +1,66 @@
+package creditcard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+)
+
+// newFirefoxAutofillProfiles writes an autofill-profiles.json with a card encrypted by the key.
+func newFirefoxAutofillProfiles(t *testing.T, key []byte, number string) string {
+	t.Helper()
+	nonce := bytes.Repeat([]byte{'n'}, 12)
+	encrypted, err := crypto.AESGCMEncrypt(key, nonce, []byte(number))
+	require.NoError(t, err)
+	encoded := base64.StdEncoding.EncodeToString(append(nonce, encrypted...))
+	content := fmt.Sprintf(`{"version":1,"addresses":[],"creditCards":[
+		{"guid":"9f5a7b1c2d3e","version":3,"cc-name":"John Doe","cc-number":"************1111","cc-number-encrypted":%q,"cc-exp-month":12,"cc-exp-year":2030,"cc-type":"visa"},
+		{"guid":"deleted","deleted":true}
+	]}`, encoded)
+	path := filepath.Join(t.TempDir(), "autofill-profiles.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestFirefoxCreditCard_Extract(t *testing.T) {
+	key := bytes.Repeat([]byte{'k'}, 32)
+	path := newFirefoxAutofillProfiles(t, key, "4111111111111111")
+
+	var cards FirefoxCreditCard
+	require.NoError(t, cards.Extract(crypto.MasterKeys{V10: key}, path))
+	require.Len(t, cards, 1)
+	assert.Equal(t, Card{
+		GUID:            "9f5a7b1c2d3e",
+		Name:            "John Doe",
+		ExpirationMonth: "12",
+		ExpirationYear:  "2030",
+		CardNumber:      "4111111111111111",
+		EncryptScheme:   crypto.SchemeOSKeyStore,
+	}, cards[0])
+
+	testCases := []struct {
+		name string
+		key  []byte
+	}{
+		{name: "no key", key: nil},
+		{name: "wrong key", key: bytes.Repeat([]byte{'w'}, 32)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var cards FirefoxCreditCard
+			require.NoError(t, cards.Extract(crypto.MasterKeys{V10: tc.key}, path))
+			require.Len(t, cards, 1)
+			assert.True(t, cards[0].DecryptFailed)
+			assert.Empty(t, cards[0].CardNumber)
+			assert.Equal(t, "John Doe", cards[0].Name)
+		})
+	}
+}
@@ -2,7 +2,6 @@ package download
 
 import (
 	"database/sql"
-	"os"
 	"sort"
 	"strings"
 	"time"
@@ -10,6 +9,7 @@ import (
 	"github.com/tidwall/gjson"
 	_ "modernc.org/sqlite" // import sqlite3 driver
 
+	"github.com/moond4rk/hackbrowserdata/crypto"
 	"github.com/moond4rk/hackbrowserdata/extractor"
 	"github.com/moond4rk/hackbrowserdata/log"
 	"github.com/moond4rk/hackbrowserdata/types"
@@ -25,9 +25,10 @@ func init() {
 	})
 }
 
-type ChromiumDownload []download
+type ChromiumDownload []Download
 
-type download struct {
+// Download is a downloaded file and the URL it was downloaded from.
+type Download struct {
 	TargetPath string
 	URL        string
 	TotalBytes int64
@@ -40,12 +41,11 @@ const (
 	queryChromiumDownload = `SELECT target_path, tab_url, total_bytes, start_time, end_time, mime_type FROM downloads`
 )
 
-func (c *ChromiumDownload) Extract(_ []byte) error {
-	db, err := sql.Open("sqlite", types.ChromiumDownload.TempFilename())
+func (c *ChromiumDownload) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(types.ChromiumDownload.TempFilename())
 	defer db.Close()
 	rows, err := db.Query(queryChromiumDownload)
 	if err != nil {
@@ -60,7 +60,7 @@ func (c *ChromiumDownload) Extract(_ []byte) error {
 		if err := rows.Scan(&targetPath, &tabURL, &totalBytes, &startTime, &endTime, &mimeType); err != nil {
 			log.Warnf("scan chromium download error: %v", err)
 		}
-		data := download{
+		data := Download{
 			TargetPath: targetPath,
 			URL:        tabURL,
 			TotalBytes: totalBytes,
@@ -84,19 +84,18 @@ func (c *ChromiumDownload) Len() int {
 	return len(*c)
 }
 
-type FirefoxDownload []download
+type FirefoxDownload []Download
 
 const (
 	queryFirefoxDownload = `SELECT place_id, GROUP_CONCAT(content), url, dateAdded FROM (SELECT * FROM moz_annos INNER JOIN moz_places ON moz_annos.place_id=moz_places.id) t GROUP BY place_id`
 	closeJournalMode     = `PRAGMA journal_mode=off`
 )
 
-func (f *FirefoxDownload) Extract(_ []byte) error {
-	db, err := sql.Open("sqlite", types.FirefoxDownload.TempFilename())
+func (f *FirefoxDownload) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(types.FirefoxDownload.TempFilename())
 	defer db.Close()
 
 	_, err = db.Exec(closeJournalMode)
@@ -122,7 +121,7 @@ func (f *FirefoxDownload) Extract(_ []byte) error {
 			json := "{" + contentList[1]
 			endTime := gjson.Get(json, "endTime")
 			fileSize := gjson.Get(json, "fileSize")
-			*f = append(*f, download{
+			*f = append(*f, Download{
 				TargetPath: path,
 				URL:        url,
 				TotalBytes: fileSize.Int(),
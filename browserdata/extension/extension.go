@@ -2,12 +2,12 @@ package extension
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/tidwall/gjson"
 	"golang.org/x/text/language"
 
+	"github.com/moond4rk/hackbrowserdata/crypto"
 	"github.com/moond4rk/hackbrowserdata/extractor"
 	"github.com/moond4rk/hackbrowserdata/types"
 	"github.com/moond4rk/hackbrowserdata/utils/fileutil"
@@ -22,9 +22,10 @@ func init() {
 	})
 }
 
-type ChromiumExtension []*extension
+type ChromiumExtension []*Extension
 
-type extension struct {
+// Extension is an installed browser extension.
+type Extension struct {
 	ID          string
 	URL         string
 	Enabled     bool
@@ -34,12 +35,11 @@ type extension struct {
 	HomepageURL string
 }
 
-func (c *ChromiumExtension) Extract(_ []byte) error {
-	extensionFile, err := fileutil.ReadFile(types.ChromiumExtension.TempFilename())
+func (c *ChromiumExtension) Extract(_ crypto.MasterKeys, path string) error {
+	extensionFile, err := fileutil.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(types.ChromiumExtension.TempFilename())
 
 	result, err := parseChromiumExtensions(extensionFile)
 	if err != nil {
@@ -49,7 +49,7 @@ func (c *ChromiumExtension) Extract(_ []byte) error {
 	return nil
 }
 
-func parseChromiumExtensions(content string) ([]*extension, error) {
+func parseChromiumExtensions(content string) ([]*Extension, error) {
 	settingKeys := []string{
 		"settings.extensions",
 		"settings.settings",
@@ -65,7 +65,7 @@ func parseChromiumExtensions(content string) ([]*extension, error) {
 	if !settings.Exists() {
 		return nil, fmt.Errorf("cannot find extensions in settings")
 	}
-	var c []*extension
+	var c []*Extension
 
 	settings.ForEach(func(id, ext gjson.Result) bool {
 		location := ext.Get("location")
@@ -80,14 +80,14 @@ func parseChromiumExtensions(content string) ([]*extension, error) {
 		enabled := !ext.Get("disable_reasons").Exists()
 		b := ext.Get("manifest")
 		if !b.Exists() {
-			c = append(c, &extension{
+			c = append(c, &Extension{
 				ID:      id.String(),
 				Enabled: enabled,
 				Name:    ext.Get("path").String(),
 			})
 			return true
 		}
-		c = append(c, &extension{
+		c = append(c, &Extension{
 			ID:          id.String(),
 			URL:         getChromiumExtURL(id.String(), b.Get("update_url").String()),
 			Enabled:     enabled,
@@ -119,16 +119,15 @@ func (c *ChromiumExtension) Len() int {
 	return len(*c)
 }
 
-type FirefoxExtension []*extension
+type FirefoxExtension []*Extension
 
 var lang = language.Und
 
-func (f *FirefoxExtension) Extract(_ []byte) error {
-	s, err := fileutil.ReadFile(types.FirefoxExtension.TempFilename())
+func (f *FirefoxExtension) Extract(_ crypto.MasterKeys, path string) error {
+	s, err := fileutil.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	_ = os.Remove(types.FirefoxExtension.TempFilename())
 	j := gjson.Parse(s)
 	for _, v := range j.Get("addons").Array() {
 		// https://searchfox.org/mozilla-central/source/toolkit/mozapps/extensions/internal/XPIDatabase.jsm#157
@@ -138,7 +137,7 @@ func (f *FirefoxExtension) Extract(_ []byte) error {
 
 		if lang != language.Und {
 			locale := findFirefoxLocale(v.Get("locales").Array(), lang)
-			*f = append(*f, &extension{
+			*f = append(*f, &Extension{
 				ID:          v.Get("id").String(),
 				Enabled:     v.Get("active").Bool(),
 				Name:        locale.Get("name").String(),
@@ -149,7 +148,7 @@ func (f *FirefoxExtension) Extract(_ []byte) error {
 			continue
 		}
 
-		*f = append(*f, &extension{
+		*f = append(*f, &Extension{
 			ID:          v.Get("id").String(),
 			Enabled:     v.Get("active").Bool(),
 			Name:        v.Get("defaultLocale.name").String(),
@@ -2,13 +2,13 @@ package history
 
 import (
 	"database/sql"
-	"os"
 	"sort"
 	"time"
 
 	// import sqlite3 driver
 	_ "modernc.org/sqlite"
 
+	"github.com/moond4rk/hackbrowserdata/crypto"
 	"github.com/moond4rk/hackbrowserdata/extractor"
 	"github.com/moond4rk/hackbrowserdata/log"
 	"github.com/moond4rk/hackbrowserdata/types"
@@ -24,9 +24,10 @@ func init() {
 	})
 }
 
-type ChromiumHistory []history
+type ChromiumHistory []History
 
-type history struct {
+// History is a visited URL with its visit count and last visit time.
+type History struct {
 	Title         string
 	URL           string
 	VisitCount    int
@@ -37,12 +38,11 @@ const (
 	queryChromiumHistory = `SELECT url, title, visit_count, last_visit_time FROM urls`
 )
 
-func (c *ChromiumHistory) Extract(_ []byte) error {
-	db, err := sql.Open("sqlite", types.ChromiumHistory.TempFilename())
+func (c *ChromiumHistory) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(types.ChromiumHistory.TempFilename())
 	defer db.Close()
 
 	rows, err := db.Query(queryChromiumHistory)
@@ -59,7 +59,7 @@ func (c *ChromiumHistory) Extract(_ []byte) error {
 		if err := rows.Scan(&url, &title, &visitCount, &lastVisitTime); err != nil {
 			log.Warnf("scan chromium history error: %v", err)
 		}
-		data := history{
+		data := History{
 			URL:           url,
 			Title:         title,
 			VisitCount:    visitCount,
@@ -81,19 +81,18 @@ func (c *ChromiumHistory) Len() int {
 	return len(*c)
 }
 
-type FirefoxHistory []history
+type FirefoxHistory []History
 
 const (
 	queryFirefoxHistory = `SELECT id, url, COALESCE(last_visit_date, 0), COALESCE(title, ''), visit_count FROM moz_places`
 	closeJournalMode    = `PRAGMA journal_mode=off`
 )
 
-func (f *FirefoxHistory) Extract(_ []byte) error {
-	db, err := sql.Open("sqlite", types.FirefoxHistory.TempFilename())
+func (f *FirefoxHistory) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(types.FirefoxHistory.TempFilename())
 	defer db.Close()
 
 	_, err = db.Exec(closeJournalMode)
@@ -115,7 +114,7 @@ func (f *FirefoxHistory) Extract(_ []byte) error {
 		if err = rows.Scan(&id, &url, &visitDate, &title, &visitCount); err != nil {
 			log.Errorf("scan firefox history error: %v", err)
 		}
-		*f = append(*f, history{
+		*f = append(*f, History{
 			Title:         title,
 			URL:           url,
 			VisitCount:    visitCount,
@@ -0,0 +1,302 @@
+package history
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/extractor"
+	"github.com/moond4rk/hackbrowserdata/log"
+	"github.com/moond4rk/hackbrowserdata/types"
+	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
+)
+
+func init() {
+	extractor.RegisterExtractor(types.ChromiumVisit, func() extractor.Extractor {
+		return new(ChromiumVisit)
+	})
+	extractor.RegisterExtractor(types.FirefoxVisit, func() extractor.Extractor {
+		return new(FirefoxVisit)
+	})
+}
+
+// Visit is a single visit of an URL, FromVisit is the id of the visit which led to it, 0 if none,
+// and FromURL is the URL of that visit. Transition is the core type of the visit and Qualifiers
+// are the qualifiers of the transition joined by '|', eg: link, chain_start|server_redirect
+// Input is the text typed in the address bar of Firefox which selected the URL, joined by '|'
+type Visit struct {
+	ID         int64
+	URL        string
+	Title      string
+	VisitTime  time.Time
+	Transition string
+	Qualifiers string
+	FromVisit  int64
+	FromURL    string
+	Duration   time.Duration
+	Source     string
+	Input      string
+}
+
+type ChromiumVisit []Visit
+
+const (
+	queryChromiumVisit = `SELECT visits.id, urls.url, COALESCE(urls.title, ''), visits.visit_time, visits.from_visit,
+		visits.transition, visits.visit_duration, %s FROM visits JOIN urls ON visits.url = urls.id %s`
+	queryChromiumVisitSource     = `COALESCE(visit_source.source, 1)`
+	joinChromiumVisitSource      = `LEFT JOIN visit_source ON visits.id = visit_source.id`
+	queryChromiumVisitSourceInfo = `SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'visit_source'`
+)
+
+// the core types of the page transition of Chromium, in the low byte of the transition
+// @https://source.chromium.org/chromium/chromium/src/+/main:ui/base/page_transition_types.h
+var chromiumCoreTransitions = []string{
+	"link",
+	"typed",
+	"auto_bookmark",
+	"auto_subframe",
+	"manual_subframe",
+	"generated",
+	"auto_toplevel",
+	"form_submit",
+	"reload",
+	"keyword",
+	"keyword_generated",
+}
+
+// the qualifiers of the page transition of Chromium, in the high bits of the transition
+var chromiumTransitionQualifiers = []struct {
+	mask int64
+	name string
+}{
+	{0x00800000, "blocked"},
+	{0x01000000, "forward_back"},
+	{0x02000000, "from_address_bar"},
+	{0x04000000, "home_page"},
+	{0x08000000, "from_api"},
+	{0x10000000, "chain_start"},
+	{0x20000000, "chain_end"},
+	{0x40000000, "client_redirect"},
+	{0x80000000, "server_redirect"},
+}
+
+// the sources of the visits in visit_source, a visit without a source is browsed
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/history/core/browser/history_types.h
+var chromiumVisitSources = []string{
+	"synced",
+	"browsed",
+	"extension",
+	"firefox_imported",
+	"ie_imported",
+	"safari_imported",
+}
+
+func (c *ChromiumVisit) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// visit_source is missing in the History of old Chromium
+	source, join := "1", ""
+	var name string
+	if err := db.QueryRow(queryChromiumVisitSourceInfo).Scan(&name); err == nil {
+		source, join = queryChromiumVisitSource, joinChromiumVisitSource
+	}
+	rows, err := db.Query(fmt.Sprintf(queryChromiumVisit, source, join))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id, visitTime, fromVisit int64
+			transition, duration     int64
+			visitSource              int
+			url, title               string
+		)
+		if err := rows.Scan(&id, &url, &title, &visitTime, &fromVisit, &transition, &duration, &visitSource); err != nil {
+			log.Warnf("scan chromium visit error: %v", err)
+			continue
+		}
+		core, qualifiers := chromiumTransition(transition)
+		*c = append(*c, Visit{
+			ID:         id,
+			URL:        url,
+			Title:      title,
+			VisitTime:  typeutil.TimeEpoch(visitTime),
+			Transition: core,
+			Qualifiers: qualifiers,
+			FromVisit:  fromVisit,
+			Duration:   time.Duration(duration) * time.Microsecond,
+			Source:     enumName(chromiumVisitSources, visitSource),
+		})
+	}
+	resolveFromURL(*c)
+	return rows.Err()
+}
+
+func (c *ChromiumVisit) Name() string {
+	return "visit"
+}
+
+func (c *ChromiumVisit) Len() int {
+	return len(*c)
+}
+
+type FirefoxVisit []Visit
+
+const (
+	queryFirefoxVisit = `SELECT moz_historyvisits.id, moz_places.id, moz_places.url, COALESCE(moz_places.title, ''),
+		moz_historyvisits.visit_date, COALESCE(moz_historyvisits.from_visit, 0), COALESCE(moz_historyvisits.visit_type, 0), %s
+		FROM moz_historyvisits JOIN moz_places ON moz_historyvisits.place_id = moz_places.id
+		ORDER BY moz_historyvisits.visit_date`
+	queryFirefoxVisitSource     = `COALESCE(moz_historyvisits.source, 0)`
+	queryFirefoxVisitSourceInfo = `SELECT name FROM pragma_table_info('moz_historyvisits') WHERE name = 'source'`
+	queryFirefoxInputHistory    = `SELECT place_id, input FROM moz_inputhistory ORDER BY use_count DESC`
+)
+
+// the visit types of Firefox, which start from 1
+// @https://searchfox.org/mozilla-central/source/toolkit/components/places/nsINavHistoryService.idl
+var firefoxVisitTypes = []string{
+	"",
+	"link",
+	"typed",
+	"bookmark",
+	"embed",
+	"redirect_permanent",
+	"redirect_temporary",
+	"download",
+	"framed_link",
+	"reload",
+}
+
+// the sources of the visits of Firefox, the source column is added in Firefox 105
+// @https://searchfox.org/mozilla-central/source/toolkit/components/places/nsINavHistoryService.idl
+var firefoxVisitSources = []string{
+	"organic",
+	"sponsored",
+	"bookmarked",
+	"searched",
+}
+
+func (f *FirefoxVisit) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(closeJournalMode); err != nil {
+		return err
+	}
+	// the source of the visits is missing in the places.sqlite of old Firefox
+	source, hasSource := "-1", false
+	var name string
+	if err := db.QueryRow(queryFirefoxVisitSourceInfo).Scan(&name); err == nil {
+		source, hasSource = queryFirefoxVisitSource, true
+	}
+	inputs := firefoxInputHistory(db)
+	rows, err := db.Query(fmt.Sprintf(queryFirefoxVisit, source))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id, placeID, visitDate, fromVisit int64
+			visitType, visitSource            int
+			url, title                        string
+		)
+		if err := rows.Scan(&id, &placeID, &url, &title, &visitDate, &fromVisit, &visitType, &visitSource); err != nil {
+			log.Warnf("scan firefox visit error: %v", err)
+			continue
+		}
+		v := Visit{
+			ID:         id,
+			URL:        url,
+			Title:      title,
+			VisitTime:  typeutil.TimeStamp(visitDate / 1000000),
+			Transition: enumName(firefoxVisitTypes, visitType),
+			FromVisit:  fromVisit,
+			Input:      strings.Join(inputs[placeID], "|"),
+		}
+		if hasSource {
+			v.Source = enumName(firefoxVisitSources, visitSource)
+		}
+		*f = append(*f, v)
+	}
+	resolveFromURL(*f)
+	return rows.Err()
+}
+
+func (f *FirefoxVisit) Name() string {
+	return "visit"
+}
+
+func (f *FirefoxVisit) Len() int {
+	return len(*f)
+}
+
+// firefoxInputHistory returns the text typed in the address bar for each place, the most used first.
+func firefoxInputHistory(db *sql.DB) map[int64][]string {
+	inputs := make(map[int64][]string)
+	rows, err := db.Query(queryFirefoxInputHistory)
+	if err != nil {
+		log.Debugf("query firefox input history error: %v", err)
+		return inputs
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			placeID int64
+			input   string
+		)
+		if err := rows.Scan(&placeID, &input); err != nil {
+			log.Warnf("scan firefox input history error: %v", err)
+			continue
+		}
+		inputs[placeID] = append(inputs[placeID], input)
+	}
+	return inputs
+}
+
+// chromiumTransition decodes the core type and the qualifiers of the page transition.
+func chromiumTransition(transition int64) (string, string) {
+	core := enumName(chromiumCoreTransitions, int(transition&0xff))
+	var qualifiers []string
+	for _, q := range chromiumTransitionQualifiers {
+		if transition&q.mask != 0 {
+			qualifiers = append(qualifiers, q.name)
+		}
+	}
+	return core, strings.Join(qualifiers, "|")
+}
+
+// enumName returns the name of the value, or the number if it's unknown.
+func enumName(names []string, v int) string {
+	if v >= 0 && v < len(names) && names[v] != "" {
+		return names[v]
+	}
+	return fmt.Sprintf("unknown(%d)", v)
+}
+
+// resolveFromURL fills the URL of the referring visit of each visit, and sorts the visits by time.
+func resolveFromURL(visits []Visit) {
+	urls := make(map[int64]string, len(visits))
+	for _, v := range visits {
+		urls[v.ID] = v.URL
+	}
+	for i, v := range visits {
+		if v.FromVisit != 0 {
+			visits[i].FromURL = urls[v.FromVisit]
+		}
+	}
+	sort.SliceStable(visits, func(i, j int) bool {
+		return visits[i].VisitTime.Before(visits[j].VisitTime)
+	})
+}
@@ -0,0 +1,140 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/internal/testutil"
+	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
+)
+
+func newChromiumHistoryDB(t *testing.T, withSource bool) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "History")
+	stmts := []string{
+		`CREATE TABLE urls (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR, visit_count INTEGER, last_visit_time INTEGER)`,
+		`CREATE TABLE visits (id INTEGER PRIMARY KEY, url INTEGER NOT NULL, visit_time INTEGER NOT NULL, from_visit INTEGER,
+			transition INTEGER DEFAULT 0 NOT NULL, segment_id INTEGER, visit_duration INTEGER DEFAULT 0 NOT NULL)`,
+		`INSERT INTO urls VALUES (1, 'https://www.google.com/search?q=go', 'go - Google Search', 1, 13300000000000000)`,
+		`INSERT INTO urls VALUES (2, 'https://go.dev/', 'The Go Programming Language', 1, 13300000005000000)`,
+		`INSERT INTO urls VALUES (3, 'https://go.dev/doc/', NULL, 1, 13300000009000000)`,
+		// typed in the address bar, a link, then a server redirect which ends the chain
+		`INSERT INTO visits VALUES (10, 1, 13300000000000000, 0, 0x12000001, 0, 5000000)`,
+		`INSERT INTO visits VALUES (11, 2, 13300000005000000, 10, 0x10000000, 0, 4000000)`,
+		`INSERT INTO visits VALUES (12, 3, 13300000009000000, 11, -2147483648 | 0x20000000, 0, 0)`,
+	}
+	if withSource {
+		stmts = append(stmts,
+			`CREATE TABLE visit_source (id INTEGER PRIMARY KEY, source INTEGER NOT NULL)`,
+			`INSERT INTO visit_source VALUES (11, 0)`,
+		)
+	}
+	testutil.NewSQLite(t, path, stmts...)
+	return path
+}
+
+func TestChromiumVisit_Extract(t *testing.T) {
+	var c ChromiumVisit
+	require.NoError(t, c.Extract(crypto.MasterKeys{}, newChromiumHistoryDB(t, true)))
+	assert.Equal(t, ChromiumVisit{
+		{
+			ID: 10, URL: "https://www.google.com/search?q=go", Title: "go - Google Search",
+			VisitTime: typeutil.TimeEpoch(13300000000000000), Transition: "typed", Qualifiers: "from_address_bar|chain_start",
+			Duration: 5 * time.Second, Source: "browsed",
+		},
+		{
+			ID: 11, URL: "https://go.dev/", Title: "The Go Programming Language",
+			VisitTime: typeutil.TimeEpoch(13300000005000000), Transition: "link", Qualifiers: "chain_start",
+			FromVisit: 10, FromURL: "https://www.google.com/search?q=go", Duration: 4 * time.Second, Source: "synced",
+		},
+		{
+			ID: 12, URL: "https://go.dev/doc/",
+			VisitTime: typeutil.TimeEpoch(13300000009000000), Transition: "link", Qualifiers: "chain_end|server_redirect",
+			FromVisit: 11, FromURL: "https://go.dev/", Source: "browsed",
+		},
+	}, c)
+}
+
+func TestChromiumVisit_ExtractWithoutSource(t *testing.T) {
+	var c ChromiumVisit
+	require.NoError(t, c.Extract(crypto.MasterKeys{}, newChromiumHistoryDB(t, false)))
+	require.Len(t, c, 3)
+	for _, v := range c {
+		assert.Equal(t, "browsed", v.Source)
+	}
+}
+
+func TestChromiumTransition(t *testing.T) {
+	core, qualifiers := chromiumTransition(0x0b)
+	assert.Equal(t, "unknown(11)", core)
+	assert.Empty(t, qualifiers)
+
+	core, qualifiers = chromiumTransition(0x41000008)
+	assert.Equal(t, "reload", core)
+	assert.Equal(t, "forward_back|client_redirect", qualifiers)
+}
+
+func newFirefoxPlacesDB(t *testing.T, withSource bool) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "places.sqlite")
+	visits := `CREATE TABLE moz_historyvisits (id INTEGER PRIMARY KEY, from_visit INTEGER, place_id INTEGER,
+		visit_date INTEGER, visit_type INTEGER, session INTEGER)`
+	if withSource {
+		visits = `CREATE TABLE moz_historyvisits (id INTEGER PRIMARY KEY, from_visit INTEGER, place_id INTEGER,
+			visit_date INTEGER, visit_type INTEGER, session INTEGER, source INTEGER NOT NULL DEFAULT 0)`
+	}
+	stmts := []string{
+		`CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR, visit_count INTEGER DEFAULT 0,
+			last_visit_date INTEGER)`,
+		`CREATE TABLE moz_inputhistory (place_id INTEGER NOT NULL, input LONGVARCHAR NOT NULL, use_count INTEGER,
+			PRIMARY KEY (place_id, input))`,
+		visits,
+		`INSERT INTO moz_places VALUES (1, 'https://github.com/', 'GitHub', 2, 1700000009000000)`,
+		`INSERT INTO moz_places VALUES (2, 'https://github.com/moond4rk/hackbrowserdata', NULL, 1, 1700000005000000)`,
+		`INSERT INTO moz_inputhistory VALUES (1, 'git', 3)`,
+		`INSERT INTO moz_inputhistory VALUES (1, 'github', 5)`,
+		// typed in the address bar, a link, then a reload of the first page
+		`INSERT INTO moz_historyvisits (id, from_visit, place_id, visit_date, visit_type) VALUES (20, 0, 1, 1700000000000000, 2)`,
+		`INSERT INTO moz_historyvisits (id, from_visit, place_id, visit_date, visit_type) VALUES (21, 20, 2, 1700000005000000, 1)`,
+		`INSERT INTO moz_historyvisits (id, from_visit, place_id, visit_date, visit_type) VALUES (22, 0, 1, 1700000009000000, 9)`,
+	}
+	if withSource {
+		stmts = append(stmts, `UPDATE moz_historyvisits SET source = 2 WHERE id = 21`)
+	}
+	testutil.NewSQLite(t, path, stmts...)
+	return path
+}
+
+func TestFirefoxVisit_Extract(t *testing.T) {
+	var f FirefoxVisit
+	require.NoError(t, f.Extract(crypto.MasterKeys{}, newFirefoxPlacesDB(t, true)))
+	assert.Equal(t, FirefoxVisit{
+		{
+			ID: 20, URL: "https://github.com/", Title: "GitHub", VisitTime: typeutil.TimeStamp(1700000000),
+			Transition: "typed", Source: "organic", Input: "github|git",
+		},
+		{
+			ID: 21, URL: "https://github.com/moond4rk/hackbrowserdata", VisitTime: typeutil.TimeStamp(1700000005),
+			Transition: "link", FromVisit: 20, FromURL: "https://github.com/", Source: "bookmarked",
+		},
+		{
+			ID: 22, URL: "https://github.com/", Title: "GitHub", VisitTime: typeutil.TimeStamp(1700000009),
+			Transition: "reload", Source: "organic", Input: "github|git",
+		},
+	}, f)
+}
+
+func TestFirefoxVisit_ExtractWithoutSource(t *testing.T) {
+	var f FirefoxVisit
+	require.NoError(t, f.Extract(crypto.MasterKeys{}, newFirefoxPlacesDB(t, false)))
+	require.Len(t, f, 3)
+	for _, v := range f {
+		assert.Empty(t, v.Source)
+	}
+	assert.Equal(t, "unknown(0)", enumName(firefoxVisitTypes, 0))
+}
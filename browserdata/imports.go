@@ -8,13 +8,18 @@
 package browserdata
 
 import (
+	_ "github.com/moond4rk/hackbrowserdata/browserdata/address"
+	_ "github.com/moond4rk/hackbrowserdata/browserdata/autofill"
 	_ "github.com/moond4rk/hackbrowserdata/browserdata/bookmark"
 	_ "github.com/moond4rk/hackbrowserdata/browserdata/cookie"
 	_ "github.com/moond4rk/hackbrowserdata/browserdata/creditcard"
 	_ "github.com/moond4rk/hackbrowserdata/browserdata/download"
 	_ "github.com/moond4rk/hackbrowserdata/browserdata/extension"
 	_ "github.com/moond4rk/hackbrowserdata/browserdata/history"
+	_ "github.com/moond4rk/hackbrowserdata/browserdata/indexeddb"
 	_ "github.com/moond4rk/hackbrowserdata/browserdata/localstorage"
 	_ "github.com/moond4rk/hackbrowserdata/browserdata/password"
+	_ "github.com/moond4rk/hackbrowserdata/browserdata/search"
+	_ "github.com/moond4rk/hackbrowserdata/browserdata/session"
 	_ "github.com/moond4rk/hackbrowserdata/browserdata/sessionstorage"
 )
@@ -0,0 +1,316 @@
+package indexeddb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/golang/snappy"
+
+	"github.com/moond4rk/hackbrowserdata/log"
+)
+
+var (
+	errInvalidIDBKey   = errors.New("invalid indexeddb key")
+	errExternalIDBData = errors.New("indexeddb value is stored in a blob file")
+)
+
+// the suffix of the leveldb dir of an origin, eg: https_github.com_0.indexeddb.leveldb
+const chromiumIndexedDBSuffix = ".indexeddb.leveldb"
+
+// the types of the metadata keys of Chromium IndexedDB
+// @https://source.chromium.org/chromium/chromium/src/+/main:content/browser/indexed_db/indexed_db_leveldb_coding.h
+const (
+	databaseNameTypeByte        = 201
+	objectStoreMetaDataTypeByte = 50
+	objectStoreNameMetaData     = 0
+	objectStoreDataIndexID      = 1
+)
+
+// the type bytes of the encoded IDBKey
+const (
+	idbKeyNull   = 0
+	idbKeyString = 1
+	idbKeyDate   = 2
+	idbKeyNumber = 3
+	idbKeyArray  = 4
+	idbKeyMinKey = 5
+	idbKeyBinary = 6
+)
+
+// the header of the value wrapped by Blink, 0xff 0x11 is followed by the kind of the wrapping
+const (
+	blinkWrapVersion      = 0xff
+	blinkWrapPseudoVer    = 0x11
+	blinkReplaceWithBlob  = 0x01
+	blinkCompressedSnappy = 0x02
+)
+
+// keyPrefix is the prefix of all the keys but the global metadata, the first byte holds the
+// byte lengths of the ids, which are little endian.
+//
+//	| database id length - 1 (3 bits) | object store id length - 1 (3 bits) | index id length - 1 (2 bits) |
+type keyPrefix struct {
+	database    int64
+	objectStore int64
+	index       int64
+}
+
+func decodeKeyPrefix(b []byte) (keyPrefix, []byte, error) {
+	if len(b) == 0 {
+		return keyPrefix{}, nil, errInvalidIDBKey
+	}
+	dbLen, osLen, indexLen := int(b[0]>>5)+1, int(b[0]>>2&7)+1, int(b[0]&3)+1
+	if len(b) < 1+dbLen+osLen+indexLen {
+		return keyPrefix{}, nil, errInvalidIDBKey
+	}
+	b = b[1:]
+	p := keyPrefix{
+		database:    decodeInt(b[:dbLen]),
+		objectStore: decodeInt(b[dbLen : dbLen+osLen]),
+		index:       decodeInt(b[dbLen+osLen : dbLen+osLen+indexLen]),
+	}
+	return p, b[dbLen+osLen+indexLen:], nil
+}
+
+// decodeInt decodes the little endian int of variable length.
+func decodeInt(b []byte) int64 {
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return int64(v)
+}
+
+// idbReader reads the fields of the keys of Chromium IndexedDB.
+type idbReader struct {
+	b []byte
+}
+
+func (r *idbReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		return 0, errInvalidIDBKey
+	}
+	r.b = r.b[n:]
+	return v, nil
+}
+
+func (r *idbReader) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(r.b)) {
+		return nil, errInvalidIDBKey
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b, nil
+}
+
+// stringWithLength reads the UTF-16BE string prefixed by its length in code units.
+func (r *idbReader) stringWithLength() (string, error) {
+	n, err := r.varint()
+	if err != nil {
+		return "", err
+	}
+	if n > uint64(len(r.b))/2 {
+		return "", errInvalidIDBKey
+	}
+	b, err := r.bytes(2 * n)
+	if err != nil {
+		return "", err
+	}
+	return utf16BE(b), nil
+}
+
+func (r *idbReader) double() (float64, error) {
+	b, err := r.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+// key reads the encoded IDBKey.
+func (r *idbReader) key() (interface{}, error) {
+	t, err := r.bytes(1)
+	if err != nil {
+		return nil, err
+	}
+	switch t[0] {
+	case idbKeyNull:
+		return nil, nil
+	case idbKeyString:
+		return r.stringWithLength()
+	case idbKeyDate:
+		v, err := r.double()
+		return jsonDate(v), err
+	case idbKeyNumber:
+		v, err := r.double()
+		return jsonNumber(v), err
+	case idbKeyArray:
+		n, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(len(r.b)) {
+			return nil, errInvalidIDBKey
+		}
+		arr := make([]interface{}, n)
+		for i := range arr {
+			if arr[i], err = r.key(); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case idbKeyMinKey:
+		return "[MinKey]", nil
+	case idbKeyBinary:
+		n, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		return r.bytes(n)
+	}
+	return nil, fmt.Errorf("%w: key type %d", errInvalidIDBKey, t[0])
+}
+
+func utf16BE(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// decodeOriginIdentifier decodes the origin identifier of Chromium storage, the storage key
+// of a partitioned origin after '^' is kept.
+// eg: https_github.com_0 -> https://github.com, http_localhost_8080 -> http://localhost:8080
+func decodeOriginIdentifier(id string) string {
+	origin, suffix, hasSuffix := strings.Cut(id, "^")
+	if hasSuffix {
+		suffix = "^" + suffix
+	}
+	scheme, rest, ok := strings.Cut(origin, "_")
+	i := strings.LastIndex(rest, "_")
+	if !ok || i < 0 {
+		return id
+	}
+	host, port := rest[:i], rest[i+1:]
+	if port == "0" {
+		return scheme + "://" + host + suffix
+	}
+	return scheme + "://" + host + ":" + port + suffix
+}
+
+// readChromiumIndexedDB reads the records of the object stores of an origin's leveldb.
+func readChromiumIndexedDB(dir string) ([]Entry, error) {
+	records, err := readLevelDB(dir)
+	if err != nil {
+		return nil, err
+	}
+	type database struct{ origin, name string }
+	databases := make(map[int64]database)
+	objectStores := make(map[[2]int64]string)
+	keys := sortedKeys(records)
+	for _, k := range keys {
+		prefix, rest, err := decodeKeyPrefix([]byte(k))
+		if err != nil || prefix.objectStore != 0 || prefix.index != 0 || len(rest) == 0 {
+			continue
+		}
+		r := &idbReader{b: rest[1:]}
+		switch {
+		case prefix.database == 0 && rest[0] == databaseNameTypeByte:
+			// DatabaseNameKey: origin identifier and name, the value is the database id
+			origin, err := r.stringWithLength()
+			if err != nil {
+				continue
+			}
+			name, err := r.stringWithLength()
+			if err != nil {
+				continue
+			}
+			databases[decodeInt(records[k])] = database{origin: decodeOriginIdentifier(origin), name: name}
+		case prefix.database != 0 && rest[0] == objectStoreMetaDataTypeByte:
+			// ObjectStoreMetaDataKey: object store id and metadata type, the name is an UTF-16BE string
+			id, err := r.varint()
+			if err != nil || len(r.b) != 1 || r.b[0] != objectStoreNameMetaData {
+				continue
+			}
+			objectStores[[2]int64{prefix.database, int64(id)}] = utf16BE(records[k])
+		}
+	}
+
+	origin := decodeOriginIdentifier(strings.TrimSuffix(filepath.Base(dir), chromiumIndexedDBSuffix))
+	var entries []Entry
+	for _, k := range keys {
+		prefix, rest, err := decodeKeyPrefix([]byte(k))
+		if err != nil || prefix.database == 0 || prefix.objectStore == 0 || prefix.index != objectStoreDataIndexID {
+			continue
+		}
+		e := Entry{
+			Origin:      origin,
+			Database:    databases[prefix.database].name,
+			ObjectStore: objectStores[[2]int64{prefix.database, prefix.objectStore}],
+		}
+		if db, ok := databases[prefix.database]; ok && db.origin != "" {
+			e.Origin = db.origin
+		}
+		r := &idbReader{b: rest}
+		key, err := r.key()
+		if err != nil {
+			log.Debugf("decode indexeddb key of %s/%s error: %v", e.Database, e.ObjectStore, err)
+			continue
+		}
+		e.Key = marshalJSON(key)
+		value, err := decodeChromiumValue(records[k])
+		if err != nil {
+			e.Value = fmt.Sprintf("undecoded value: %v", err)
+		} else {
+			e.Value = marshalJSON(value)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// decodeChromiumValue decodes the value of the object store, which is the varint version of the
+// record followed by the value serialized by Blink, or wrapped by Blink in a blob or with snappy.
+func decodeChromiumValue(b []byte) (interface{}, error) {
+	_, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, errInvalidV8Value
+	}
+	b = b[n:]
+	if len(b) >= 3 && b[0] == blinkWrapVersion && b[1] == blinkWrapPseudoVer {
+		switch b[2] {
+		case blinkReplaceWithBlob:
+			return nil, errExternalIDBData
+		case blinkCompressedSnappy:
+			decoded, err := snappy.Decode(nil, b[3:])
+			if err != nil {
+				return nil, err
+			}
+			b = decoded
+		}
+	}
+	return decodeV8(b)
+}
+
+// chromiumOriginDirs returns the leveldb dirs of the origins in the IndexedDB dir.
+func chromiumOriginDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasSuffix(e.Name(), chromiumIndexedDBSuffix) {
+			dirs = append(dirs, filepath.Join(dir, e.Name()))
+		}
+	}
+	return dirs, nil
+}
@@ -0,0 +1,276 @@
+package indexeddb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/golang/snappy"
+
+	"github.com/moond4rk/hackbrowserdata/log"
+	"github.com/moond4rk/hackbrowserdata/utils/fileutil"
+)
+
+var (
+	errInvalidFirefoxKey   = errors.New("invalid firefox indexeddb key")
+	errExternalFirefoxData = errors.New("indexeddb value is stored in a file")
+)
+
+// the layout of the IndexedDB of Firefox, eg: storage/default/https+++github.com/idb/3870112724rsegmnoittet-es.sqlite
+const (
+	dirStorageDefault = "default"
+	dirIndexedDB      = "idb"
+	fileIndexedDBExt  = ".sqlite"
+)
+
+// the types of the encoded key of Firefox, an array adds firefoxKeyArray to the type of its first
+// element, up to firefoxMaxArrayCollapse arrays are collapsed into one type byte.
+// @https://searchfox.org/mozilla-central/source/dom/indexedDB/Key.cpp
+const (
+	firefoxKeyTerminator    = 0x00
+	firefoxKeyFloat         = 0x10
+	firefoxKeyDate          = 0x20
+	firefoxKeyString        = 0x30
+	firefoxKeyBinary        = 0x40
+	firefoxKeyArray         = 0x50
+	firefoxKeyMaxType       = firefoxKeyArray
+	firefoxMaxArrayCollapse = 3
+	firefoxMaxKeyDepth      = 64
+)
+
+// firefoxKeyReader decodes the key of Firefox IndexedDB.
+type firefoxKeyReader struct {
+	b   []byte
+	pos int
+}
+
+// decodeFirefoxKey decodes the key, eg: 30 6c 66 7a -> "key"
+func decodeFirefoxKey(b []byte) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, errInvalidFirefoxKey
+	}
+	r := &firefoxKeyReader{b: b}
+	return r.value(0, 0)
+}
+
+func (r *firefoxKeyReader) value(typeOffset byte, depth int) (interface{}, error) {
+	if depth > firefoxMaxKeyDepth || r.pos >= len(r.b) {
+		return nil, errInvalidFirefoxKey
+	}
+	t := int(r.b[r.pos]) - int(typeOffset)
+	switch {
+	case t >= firefoxKeyArray:
+		typeOffset += firefoxKeyMaxType
+		if typeOffset == firefoxKeyMaxType*firefoxMaxArrayCollapse {
+			r.pos++
+			typeOffset = 0
+		}
+		arr := []interface{}{}
+		for r.pos < len(r.b) && int(r.b[r.pos])-int(typeOffset) != firefoxKeyTerminator {
+			v, err := r.value(typeOffset, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			typeOffset = 0
+			arr = append(arr, v)
+		}
+		r.pos++
+		return arr, nil
+	case t == firefoxKeyString:
+		return string(utf16.Decode(r.units())), nil
+	case t == firefoxKeyDate:
+		return jsonDate(r.number()), nil
+	case t == firefoxKeyFloat:
+		return jsonNumber(r.number()), nil
+	case t == firefoxKeyBinary:
+		units := r.units()
+		b := make([]byte, len(units))
+		for i, u := range units {
+			b[i] = byte(u)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: type %#x", errInvalidFirefoxKey, r.b[r.pos])
+}
+
+// units decodes the code units after the type byte until the terminator, a unit is encoded in
+// one byte if it's below 0x7f, two bytes if it's below 0x3fff+0x7f, or three bytes.
+func (r *firefoxKeyReader) units() []uint16 {
+	const (
+		oneByteAdjust  = 1
+		twoByteAdjust  = -0x7f
+		threeByteShift = 6
+	)
+	r.pos++
+	var units []uint16
+	for r.pos < len(r.b) && r.b[r.pos] != firefoxKeyTerminator {
+		c := uint32(r.b[r.pos])
+		r.pos++
+		switch {
+		case c&0x80 == 0:
+			units = append(units, uint16(c-oneByteAdjust))
+		case c&0x40 == 0:
+			c <<= 8
+			if r.pos < len(r.b) {
+				c |= uint32(r.b[r.pos])
+				r.pos++
+			}
+			units = append(units, uint16(int32(c)-(twoByteAdjust+0x8000)))
+		default:
+			c <<= 16 - threeByteShift
+			if r.pos < len(r.b) {
+				c |= uint32(r.b[r.pos]) << (8 - threeByteShift)
+				r.pos++
+			}
+			if r.pos < len(r.b) {
+				c |= uint32(r.b[r.pos]) >> threeByteShift
+				r.pos++
+			}
+			units = append(units, uint16(c))
+		}
+	}
+	r.pos++
+	return units
+}
+
+// number decodes the big endian double after the type byte, the trailing zero bytes of the key
+// are trimmed, a positive number has the sign bit set and a negative one is negated.
+func (r *firefoxKeyReader) number() float64 {
+	const signBit = uint64(1) << 63
+	r.pos++
+	var bits uint64
+	for i := 0; i < 8; i++ {
+		bits <<= 8
+		if r.pos < len(r.b) {
+			bits |= uint64(r.b[r.pos])
+			r.pos++
+		}
+	}
+	if bits&signBit != 0 {
+		bits &^= signBit
+	} else {
+		bits = -bits
+	}
+	return math.Float64frombits(bits)
+}
+
+const (
+	queryIndexedDBDatabase     = `SELECT name, origin FROM database`
+	queryIndexedDBObjectStores = `SELECT id, name FROM object_store`
+	queryIndexedDBData         = `SELECT object_store_id, key, data, file_ids FROM object_data`
+	closeJournalMode           = `PRAGMA journal_mode=off`
+)
+
+// readFirefoxIndexedDB reads the records of the object stores of the idb sqlite of an origin.
+func readFirefoxIndexedDB(path, origin string) ([]Entry, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(closeJournalMode); err != nil {
+		log.Errorf("close journal mode error: %v", err)
+	}
+	var name, dbOrigin string
+	if err := db.QueryRow(queryIndexedDBDatabase).Scan(&name, &dbOrigin); err != nil {
+		return nil, err
+	}
+	if dbOrigin != "" {
+		origin = dbOrigin
+	}
+
+	objectStores := make(map[int64]string)
+	rows, err := db.Query(queryIndexedDBObjectStores)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var (
+			id    int64
+			store string
+		)
+		if err := rows.Scan(&id, &store); err != nil {
+			log.Errorf("scan firefox indexeddb object store error: %v", err)
+			continue
+		}
+		objectStores[id] = store
+	}
+	rows.Close()
+
+	rows, err = db.Query(queryIndexedDBData)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []Entry
+	for rows.Next() {
+		var (
+			storeID int64
+			key     []byte
+			data    interface{}
+			fileIDs sql.NullString
+		)
+		if err := rows.Scan(&storeID, &key, &data, &fileIDs); err != nil {
+			log.Errorf("scan firefox indexeddb data error: %v", err)
+			continue
+		}
+		e := Entry{Origin: origin, Database: name, ObjectStore: objectStores[storeID]}
+		k, err := decodeFirefoxKey(key)
+		if err != nil {
+			log.Debugf("decode firefox indexeddb key of %s/%s error: %v", name, e.ObjectStore, err)
+			continue
+		}
+		e.Key = marshalJSON(k)
+		value, err := decodeFirefoxValue(data)
+		if err != nil {
+			e.Value = fmt.Sprintf("undecoded value: %v", err)
+		} else {
+			e.Value = marshalJSON(value)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// decodeFirefoxValue decodes the data of the object store, which is the structured clone compressed
+// with snappy, a large value is stored in the .files dir and the data is the id of the file.
+func decodeFirefoxValue(data interface{}) (interface{}, error) {
+	b, ok := data.([]byte)
+	if !ok {
+		return nil, errExternalFirefoxData
+	}
+	decoded, err := snappy.Decode(nil, b)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStructuredClone(decoded)
+}
+
+// firefoxIndexedDBFiles returns the idb sqlite files with the origin decoded from the dir name
+// of each origin in storage/default.
+func firefoxIndexedDBFiles(storage string) (map[string]string, error) {
+	origins, err := os.ReadDir(filepath.Join(storage, dirStorageDefault))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	for _, origin := range origins {
+		dir := filepath.Join(storage, dirStorageDefault, origin.Name(), dirIndexedDB)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasSuffix(e.Name(), fileIndexedDBExt) {
+				files[filepath.Join(dir, e.Name())] = fileutil.DecodeOriginDir(origin.Name())
+			}
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,96 @@
+package indexeddb
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	_ "modernc.org/sqlite" // import sqlite3 driver
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/extractor"
+	"github.com/moond4rk/hackbrowserdata/log"
+	"github.com/moond4rk/hackbrowserdata/types"
+)
+
+func init() {
+	extractor.RegisterExtractor(types.ChromiumIndexedDB, func() extractor.Extractor {
+		return new(ChromiumIndexedDB)
+	})
+	extractor.RegisterExtractor(types.FirefoxIndexedDB, func() extractor.Extractor {
+		return new(FirefoxIndexedDB)
+	})
+}
+
+// Entry is a record of an object store, the key and the value are JSON.
+type Entry struct {
+	Origin      string
+	Database    string
+	ObjectStore string
+	Key         string
+	Value       string
+}
+
+// ChromiumIndexedDB reads the IndexedDB dir of Chromium, which holds a leveldb of each origin,
+// eg: IndexedDB/https_github.com_0.indexeddb.leveldb
+type ChromiumIndexedDB []Entry
+
+func (c *ChromiumIndexedDB) Extract(_ crypto.MasterKeys, path string) error {
+	dirs, err := chromiumOriginDirs(path)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		entries, err := readChromiumIndexedDB(dir)
+		if err != nil {
+			log.Errorf("read chromium indexeddb %s error: %v", dir, err)
+			continue
+		}
+		*c = append(*c, entries...)
+	}
+	return nil
+}
+
+func (c *ChromiumIndexedDB) Name() string {
+	return "indexedDB"
+}
+
+func (c *ChromiumIndexedDB) Len() int {
+	return len(*c)
+}
+
+// FirefoxIndexedDB reads a copy of the storage dir of Firefox, which holds the idb sqlite files
+// of each origin, eg: default/https+++github.com/idb/3870112724rsegmnoittet-es.sqlite
+type FirefoxIndexedDB []Entry
+
+func (f *FirefoxIndexedDB) Extract(_ crypto.MasterKeys, path string) error {
+	files, err := firefoxIndexedDBFiles(path)
+	if err != nil {
+		return err
+	}
+	for file, origin := range files {
+		entries, err := readFirefoxIndexedDB(file, origin)
+		if err != nil {
+			log.Errorf("read firefox indexeddb %s error: %v", filepath.Base(file), err)
+			continue
+		}
+		*f = append(*f, entries...)
+	}
+	return nil
+}
+
+func (f *FirefoxIndexedDB) Name() string {
+	return "indexedDB"
+}
+
+func (f *FirefoxIndexedDB) Len() int {
+	return len(*f)
+}
+
+// marshalJSON returns the JSON of the decoded key or value.
+func marshalJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
@@ -0,0 +1,209 @@
+package indexeddb
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/internal/testutil"
+)
+
+// idbKeyPrefix encodes the key prefix of one byte ids.
+func idbKeyPrefix(database, objectStore, index byte) []byte {
+	return []byte{0x00, database, objectStore, index}
+}
+
+func idbString(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 0, 2*len(u))
+	for _, v := range u {
+		b = binary.BigEndian.AppendUint16(b, v)
+	}
+	return b
+}
+
+func idbStringWithLength(s string) []byte {
+	return append(binary.AppendUvarint(nil, uint64(len(utf16.Encode([]rune(s))))), idbString(s)...)
+}
+
+func cat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func TestChromiumIndexedDB_Extract(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "https_github.com_0.indexeddb.leveldb")
+	// the metadata and the first records are compacted into a table on reopen, the others stay in the log
+	db, err := leveldb.OpenFile(dir, nil)
+	require.NoError(t, err)
+	databaseName := cat(idbKeyPrefix(0, 0, 0), []byte{databaseNameTypeByte}, idbStringWithLength("https_github.com_0"), idbStringWithLength("app"))
+	require.NoError(t, db.Put(databaseName, []byte{1}, nil))
+	storeName := cat(idbKeyPrefix(1, 0, 0), []byte{objectStoreMetaDataTypeByte, 1, objectStoreNameMetaData})
+	require.NoError(t, db.Put(storeName, idbString("notes"), nil))
+	stringKey := cat(idbKeyPrefix(1, 1, 1), []byte{idbKeyString}, idbStringWithLength("k1"))
+	require.NoError(t, db.Put(stringKey, cat([]byte{1}, v8Value(oneByteString("old")...)), nil))
+	deletedKey := cat(idbKeyPrefix(1, 1, 1), []byte{idbKeyString}, idbStringWithLength("gone"))
+	require.NoError(t, db.Put(deletedKey, cat([]byte{1}, v8Value(v8Null)), nil))
+	require.NoError(t, db.Close())
+
+	db, err = leveldb.OpenFile(dir, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Put(stringKey, cat([]byte{2}, v8Value(oneByteString("new")...)), nil))
+	require.NoError(t, db.Delete(deletedKey, nil))
+	arrayKey := cat(idbKeyPrefix(1, 1, 1), []byte{idbKeyArray, 2, idbKeyNumber}, binary.LittleEndian.AppendUint64(nil, math.Float64bits(7)),
+		[]byte{idbKeyBinary, 2, 0xca, 0xfe})
+	compressed := snappy.Encode(nil, v8Value(v8BeginJSObject, v8EndJSObject, 0))
+	require.NoError(t, db.Put(arrayKey, cat([]byte{1, blinkWrapVersion, blinkWrapPseudoVer, blinkCompressedSnappy}, compressed), nil))
+	blobKey := cat(idbKeyPrefix(1, 1, 1), []byte{idbKeyNumber}, binary.LittleEndian.AppendUint64(nil, math.Float64bits(1)))
+	require.NoError(t, db.Put(blobKey, []byte{1, blinkWrapVersion, blinkWrapPseudoVer, blinkReplaceWithBlob, 0x10}, nil))
+	require.NoError(t, db.Close())
+
+	var c ChromiumIndexedDB
+	require.NoError(t, c.Extract(crypto.MasterKeys{}, root))
+	assert.ElementsMatch(t, ChromiumIndexedDB{
+		{Origin: "https://github.com", Database: "app", ObjectStore: "notes", Key: `"k1"`, Value: `"new"`},
+		{Origin: "https://github.com", Database: "app", ObjectStore: "notes", Key: `[7,"yv4="]`, Value: `{}`},
+		{Origin: "https://github.com", Database: "app", ObjectStore: "notes", Key: `1`, Value: "undecoded value: " + errExternalIDBData.Error()},
+	}, c)
+}
+
+func TestDecodeOriginIdentifier(t *testing.T) {
+	testCases := map[string]string{
+		"https_github.com_0":                  "https://github.com",
+		"http_localhost_8080":                 "http://localhost:8080",
+		"https_a.com_0^0https_b.com":          "https://a.com^0https_b.com",
+		"chrome-extension_abcdefghijklmnop_0": "chrome-extension://abcdefghijklmnop",
+		"file__0":                             "file://",
+		"invalid":                             "invalid",
+	}
+	for in, want := range testCases {
+		assert.Equal(t, want, decodeOriginIdentifier(in), in)
+	}
+}
+
+func TestDecodeFirefoxKey(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   []byte
+		want interface{}
+	}{
+		{name: "string", in: []byte{0x30, 0x6c, 0x66, 0x7a}, want: "key"},
+		{name: "string with terminator", in: []byte{0x30, 0x62, 0x00}, want: "a"},
+		{name: "two and three byte chars", in: []byte{0x30, 0x80, 0x6a, 0xd3, 0x8b, 0x40}, want: "é中"},
+		{name: "number", in: []byte{0x10, 0xbf, 0xf0}, want: 1.0},
+		{name: "negative number", in: []byte{0x10, 0x40, 0x10}, want: -1.0},
+		{name: "binary", in: []byte{0x40, 0x01, 0x80, 0x80}, want: []byte{0x00, 0xff}},
+		{
+			name: "array",
+			in:   []byte{0x60, 0xbf, 0xf0, 0, 0, 0, 0, 0, 0, 0x30, 0x62},
+			want: []interface{}{1.0, "a"},
+		},
+		{name: "nested array", in: []byte{0xd0, 0x62}, want: []interface{}{[]interface{}{"a"}}},
+		{name: "empty array", in: []byte{0x50}, want: []interface{}{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := decodeFirefoxKey(tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, v)
+		})
+	}
+
+	_, err := decodeFirefoxKey([]byte{0x05})
+	assert.ErrorIs(t, err, errInvalidFirefoxKey)
+}
+
+// scWriter writes the pairs of the structured clone.
+type scWriter []byte
+
+func (w *scWriter) pair(tag, data uint32) *scWriter {
+	*w = binary.LittleEndian.AppendUint64(*w, uint64(tag)<<32|uint64(data))
+	return w
+}
+
+func (w *scWriter) latin1(s string) *scWriter {
+	w.pair(scTagString, uint32(len(s))|scStringLatin1Flag)
+	*w = append(*w, s...)
+	for len(*w)%8 != 0 {
+		*w = append(*w, 0)
+	}
+	return w
+}
+
+func (w *scWriter) double(f float64) *scWriter {
+	*w = binary.LittleEndian.AppendUint64(*w, math.Float64bits(f))
+	return w
+}
+
+func TestDecodeStructuredClone(t *testing.T) {
+	var w scWriter
+	w.pair(scTagHeader, 0).pair(scTagObjectObject, 0)
+	w.latin1("name").latin1("moond4rk")
+	w.latin1("score").double(9.5)
+	w.latin1("tags").pair(scTagArrayObject, 2).pair(scTagInt32, 0).pair(scTagInt32, 7).pair(scTagInt32, 1).latin1("x").pair(scTagEndOfKeys, 0)
+	w.latin1("seen").pair(scTagSetObject, 0).pair(scTagBoolean, 1).pair(scTagEndOfKeys, 0)
+	w.latin1("self").pair(scTagBackReferenceObject, 0)
+	w.latin1("again").pair(scTagBackReferenceObject, 1)
+	w.latin1("bytes").pair(scTagTypedArrayObject, 1).pair(0, 2)
+	w.pair(scTagArrayBufferObject, 0).pair(0, 3)
+	w = append(w, 1, 2, 3, 0, 0, 0, 0, 0)
+	w.pair(0, 1)
+	w.latin1("big").pair(scTagBigInt, 1|scBigIntNegativeFlag).pair(0, 5)
+	w.pair(scTagEndOfKeys, 0)
+
+	v, err := decodeStructuredClone(w)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"name":  "moond4rk",
+		"score": 9.5,
+		"tags":  []interface{}{int64(7), "x"},
+		"seen":  []interface{}{true},
+		"self":  "[Circular]",
+		"again": []interface{}{int64(7), "x"},
+		"bytes": []byte{2, 3},
+		"big":   "-5",
+	}, v)
+
+	_, err = decodeStructuredClone(w[:len(w)-8])
+	assert.ErrorIs(t, err, errInvalidStructuredClone)
+}
+
+func TestFirefoxIndexedDB_Extract(t *testing.T) {
+	storage := t.TempDir()
+	dir := filepath.Join(storage, "default", "https+++github.com", "idb")
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	path := filepath.Join(dir, "3870112724rsegmnoittet-es.sqlite")
+	testutil.NewSQLite(t, path,
+		`CREATE TABLE database (name TEXT PRIMARY KEY, origin TEXT NOT NULL, version INTEGER NOT NULL DEFAULT 0)`,
+		`CREATE TABLE object_store (id INTEGER PRIMARY KEY, auto_increment INTEGER NOT NULL DEFAULT 0, name TEXT NOT NULL, key_path TEXT)`,
+		`CREATE TABLE object_data (object_store_id INTEGER NOT NULL, key BLOB NOT NULL, index_data_values BLOB DEFAULT NULL, file_ids TEXT, data BLOB NOT NULL)`,
+		`INSERT INTO database (name, origin) VALUES ('app', 'https://github.com')`,
+		`INSERT INTO object_store (id, name) VALUES (1, 'notes')`,
+	)
+	var w scWriter
+	w.pair(scTagHeader, 0).latin1("hello")
+	testutil.ExecSQLite(t, path, `INSERT INTO object_data (object_store_id, key, data) VALUES (1, ?, ?)`,
+		[]byte{0x30, 0x6c, 0x66, 0x7a}, snappy.Encode(nil, w))
+	testutil.ExecSQLite(t, path, `INSERT INTO object_data (object_store_id, key, file_ids, data) VALUES (1, ?, '.1', 1)`,
+		[]byte{0x10, 0xbf, 0xf0})
+
+	var f FirefoxIndexedDB
+	require.NoError(t, f.Extract(crypto.MasterKeys{}, storage))
+	assert.ElementsMatch(t, FirefoxIndexedDB{
+		{Origin: "https://github.com", Database: "app", ObjectStore: "notes", Key: `"key"`, Value: `"hello"`},
+		{Origin: "https://github.com", Database: "app", ObjectStore: "notes", Key: `1`, Value: "undecoded value: " + errExternalFirefoxData.Error()},
+	}, f)
+}
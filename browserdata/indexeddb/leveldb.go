@@ -0,0 +1,181 @@
+package indexeddb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb/journal"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/table"
+
+	"github.com/moond4rk/hackbrowserdata/log"
+)
+
+var errInvalidBatch = errors.New("invalid leveldb write batch")
+
+// the value types of the leveldb internal key
+const (
+	keyTypeDeletion = 0
+	keyTypeValue    = 1
+)
+
+// ldbRecord is the latest version of a key in the leveldb.
+type ldbRecord struct {
+	seq     uint64
+	deleted bool
+	value   []byte
+}
+
+// readLevelDB reads the live records of the leveldb dir from its table and log files.
+// Chromium IndexedDB orders the keys with its own idb_cmp1 comparator, which goleveldb
+// refuses to open, so the files are read directly and the latest version of each key is kept.
+// @https://github.com/google/leveldb/blob/main/doc/impl.md
+func readLevelDB(dir string) (map[string][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[string]ldbRecord)
+	put := func(key []byte, seq uint64, kt byte, value []byte) {
+		if r, ok := records[string(key)]; ok && r.seq > seq {
+			return
+		}
+		records[string(key)] = ldbRecord{seq: seq, deleted: kt == keyTypeDeletion, value: bytes.Clone(value)}
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".ldb", ".sst":
+			err = readTable(path, put)
+		case ".log":
+			err = readJournal(path, put)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Debugf("read leveldb file %s error: %v", path, err)
+		}
+	}
+
+	values := make(map[string][]byte, len(records))
+	for k, r := range records {
+		if !r.deleted {
+			values[k] = r.value
+		}
+	}
+	return values, nil
+}
+
+// readTable reads the internal keys of the table, which are the user key followed by
+// 8 bytes of the sequence number and the value type.
+func readTable(path string, put func(key []byte, seq uint64, kt byte, value []byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	r, err := table.NewReader(f, info.Size(), storage.FileDesc{Type: storage.TypeTable}, nil, nil, &opt.Options{})
+	if err != nil {
+		return err
+	}
+	defer r.Release()
+	iter := r.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		ikey := iter.Key()
+		if len(ikey) < 8 {
+			continue
+		}
+		trailer := binary.LittleEndian.Uint64(ikey[len(ikey)-8:])
+		put(ikey[:len(ikey)-8], trailer>>8, byte(trailer), iter.Value())
+	}
+	return iter.Error()
+}
+
+// readJournal reads the write batches of the log file, a batch is
+//
+//	| sequence (8) | count (4) | type (1) | key length | key | value length | value | ...
+func readJournal(path string, put func(key []byte, seq uint64, kt byte, value []byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	jr := journal.NewReader(f, nil, false, true)
+	for {
+		r, err := jr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		batch, err := io.ReadAll(r)
+		if err != nil {
+			// the last batch may be half written
+			return nil
+		}
+		if err := readBatch(batch, put); err != nil {
+			return err
+		}
+	}
+}
+
+func readBatch(b []byte, put func(key []byte, seq uint64, kt byte, value []byte)) error {
+	if len(b) < 12 {
+		return errInvalidBatch
+	}
+	seq := binary.LittleEndian.Uint64(b)
+	count := binary.LittleEndian.Uint32(b[8:])
+	b = b[12:]
+	readSlice := func() ([]byte, bool) {
+		n, m := binary.Uvarint(b)
+		if m <= 0 || n > uint64(len(b)-m) {
+			return nil, false
+		}
+		s := b[m : m+int(n)]
+		b = b[m+int(n):]
+		return s, true
+	}
+	for i := uint32(0); i < count; i++ {
+		if len(b) == 0 {
+			return fmt.Errorf("%w: %d of %d records", errInvalidBatch, i, count)
+		}
+		kt := b[0]
+		b = b[1:]
+		key, ok := readSlice()
+		if !ok {
+			return errInvalidBatch
+		}
+		var value []byte
+		if kt == keyTypeValue {
+			if value, ok = readSlice(); !ok {
+				return errInvalidBatch
+			}
+		}
+		put(key, seq+uint64(i), kt, value)
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of the records in bytewise order.
+func sortedKeys(records map[string][]byte) []string {
+	keys := make([]string, 0, len(records))
+	for k := range records {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
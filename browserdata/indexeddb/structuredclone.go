@@ -0,0 +1,397 @@
+package indexeddb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"unicode/utf16"
+)
+
+var (
+	errInvalidStructuredClone     = errors.New("invalid structured clone data")
+	errUnsupportedStructuredClone = errors.New("unsupported structured clone data")
+)
+
+// the tags of the structured clone of SpiderMonkey, each pair is an uint64 of the tag
+// in the high 32 bits and the data in the low 32 bits, a tag below scTagFloatMax is a double.
+// @https://searchfox.org/mozilla-central/source/js/src/vm/StructuredClone.cpp
+const (
+	scTagFloatMax             = 0xfff00000
+	scTagHeader               = 0xfff10000
+	scTagNull                 = 0xffff0000
+	scTagUndefined            = 0xffff0001
+	scTagBoolean              = 0xffff0002
+	scTagInt32                = 0xffff0003
+	scTagString               = 0xffff0004
+	scTagDateObject           = 0xffff0005
+	scTagRegExpObject         = 0xffff0006
+	scTagArrayObject          = 0xffff0007
+	scTagObjectObject         = 0xffff0008
+	scTagArrayBufferObjectV2  = 0xffff0009
+	scTagBooleanObject        = 0xffff000a
+	scTagStringObject         = 0xffff000b
+	scTagNumberObject         = 0xffff000c
+	scTagBackReferenceObject  = 0xffff000d
+	scTagTypedArrayObjectV2   = 0xffff0010
+	scTagMapObject            = 0xffff0011
+	scTagSetObject            = 0xffff0012
+	scTagEndOfKeys            = 0xffff0013
+	scTagDataViewObjectV2     = 0xffff0015
+	scTagBigInt               = 0xffff001d
+	scTagBigIntObject         = 0xffff001e
+	scTagArrayBufferObject    = 0xffff001f
+	scTagTypedArrayObject     = 0xffff0020
+	scTagDataViewObject       = 0xffff0021
+	scTagTransferMapHeader    = 0xffff0200
+	scStringLatin1Flag        = 1 << 31
+	scBigIntNegativeFlag      = 1 << 31
+	scTransferMapDoneHeader   = 2
+	scMaxStructuredCloneDepth = 512
+)
+
+// scReader reads the structured clone buffer of SpiderMonkey into values which can be marshaled
+// to JSON, like v8Reader does.
+type scReader struct {
+	b   []byte
+	off int
+	// objects are the objects in the order of reading, for the back references
+	objects  []interface{}
+	building map[int]bool
+	depth    int
+}
+
+// decodeStructuredClone decodes the structured clone data, eg: 00 00 00 00 00 00 f1 ff 00 00 00 00 08 00 ff ff ...
+func decodeStructuredClone(b []byte) (interface{}, error) {
+	r := &scReader{b: b, building: make(map[int]bool)}
+	tag, data, err := r.peekPair()
+	if err != nil {
+		return nil, err
+	}
+	if tag == scTagHeader {
+		r.off += 8
+		tag, data, err = r.peekPair()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if tag == scTagTransferMapHeader {
+		if data != scTransferMapDoneHeader {
+			return nil, fmt.Errorf("%w: transfer map", errUnsupportedStructuredClone)
+		}
+		r.off += 8
+	}
+	return r.readValue()
+}
+
+func (r *scReader) peekPair() (uint32, uint32, error) {
+	if r.off+8 > len(r.b) {
+		return 0, 0, fmt.Errorf("%w: unexpected end at offset %d", errInvalidStructuredClone, r.off)
+	}
+	v := binary.LittleEndian.Uint64(r.b[r.off:])
+	return uint32(v >> 32), uint32(v), nil
+}
+
+func (r *scReader) pair() (uint32, uint32, error) {
+	tag, data, err := r.peekPair()
+	if err == nil {
+		r.off += 8
+	}
+	return tag, data, err
+}
+
+func (r *scReader) uint64() (uint64, error) {
+	if r.off+8 > len(r.b) {
+		return 0, fmt.Errorf("%w: unexpected end at offset %d", errInvalidStructuredClone, r.off)
+	}
+	v := binary.LittleEndian.Uint64(r.b[r.off:])
+	r.off += 8
+	return v, nil
+}
+
+// bytes reads n bytes, which are padded to 8 bytes.
+func (r *scReader) bytes(n uint64) ([]byte, error) {
+	padded := (n + 7) &^ 7
+	if padded < n || padded > uint64(len(r.b)-r.off) {
+		return nil, fmt.Errorf("%w: unexpected end at offset %d", errInvalidStructuredClone, r.off)
+	}
+	b := r.b[r.off : r.off+int(n)]
+	r.off += int(padded)
+	return b, nil
+}
+
+func (r *scReader) double() (float64, error) {
+	v, err := r.uint64()
+	return math.Float64frombits(v), err
+}
+
+func (r *scReader) begin() int {
+	r.objects = append(r.objects, nil)
+	id := len(r.objects) - 1
+	r.building[id] = true
+	return id
+}
+
+func (r *scReader) end(id int, v interface{}) interface{} {
+	delete(r.building, id)
+	r.objects[id] = v
+	return v
+}
+
+func (r *scReader) readValue() (interface{}, error) {
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > scMaxStructuredCloneDepth {
+		return nil, fmt.Errorf("%w: too deep", errInvalidStructuredClone)
+	}
+	off := r.off
+	tag, data, err := r.pair()
+	if err != nil {
+		return nil, err
+	}
+	if tag <= scTagFloatMax {
+		return jsonNumber(math.Float64frombits(binary.LittleEndian.Uint64(r.b[off:]))), nil
+	}
+	switch tag {
+	case scTagNull, scTagUndefined:
+		return nil, nil
+	case scTagBoolean:
+		return data != 0, nil
+	case scTagInt32:
+		return int64(int32(data)), nil
+	case scTagString:
+		return r.readString(data)
+	case scTagBigInt:
+		return r.readBigInt(data)
+	case scTagDateObject:
+		id := r.begin()
+		v, err := r.double()
+		return r.end(id, jsonDate(v)), err
+	case scTagRegExpObject:
+		id := r.begin()
+		t, d, err := r.pair()
+		if err != nil {
+			return nil, err
+		}
+		if t != scTagString {
+			return nil, fmt.Errorf("%w: regexp source tag %#x", errInvalidStructuredClone, t)
+		}
+		source, err := r.readString(d)
+		if err != nil {
+			return nil, err
+		}
+		return r.end(id, "/"+source+"/"+regExpFlags(uint64(data))), nil
+	case scTagBooleanObject:
+		return r.end(r.begin(), data != 0), nil
+	case scTagNumberObject:
+		id := r.begin()
+		v, err := r.double()
+		return r.end(id, jsonNumber(v)), err
+	case scTagStringObject, scTagBigIntObject:
+		id := r.begin()
+		t, d, err := r.pair()
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if t == scTagBigInt {
+			v, err = r.readBigInt(d)
+		} else {
+			v, err = r.readString(d)
+		}
+		return r.end(id, v), err
+	case scTagObjectObject:
+		return r.readObject()
+	case scTagArrayObject:
+		return r.readArray(data)
+	case scTagMapObject:
+		return r.readMap()
+	case scTagSetObject:
+		return r.readSet()
+	case scTagBackReferenceObject:
+		if int(data) >= len(r.objects) {
+			return nil, fmt.Errorf("%w: invalid back reference %d", errInvalidStructuredClone, data)
+		}
+		if r.building[int(data)] {
+			return "[Circular]", nil
+		}
+		return r.objects[data], nil
+	case scTagArrayBufferObjectV2:
+		id := r.begin()
+		b, err := r.bytes(uint64(data))
+		return r.end(id, b), err
+	case scTagArrayBufferObject:
+		id := r.begin()
+		n, err := r.uint64()
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.bytes(n)
+		return r.end(id, b), err
+	case scTagTypedArrayObject, scTagTypedArrayObjectV2, scTagDataViewObject, scTagDataViewObjectV2:
+		return r.readView(tag, data)
+	}
+	return nil, fmt.Errorf("%w: tag %#x at offset %d", errUnsupportedStructuredClone, tag, off)
+}
+
+// readString reads the string of the length in data, the chars are Latin-1 or UTF-16LE.
+func (r *scReader) readString(data uint32) (string, error) {
+	n := uint64(data &^ scStringLatin1Flag)
+	if data&scStringLatin1Flag != 0 {
+		b, err := r.bytes(n)
+		return latin1(b), err
+	}
+	b, err := r.bytes(2 * n)
+	if err != nil {
+		return "", err
+	}
+	u := make([]uint16, n)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u)), nil
+}
+
+// readBigInt reads the BigInt of the digits count and sign in data, the uint64 digits are little endian.
+func (r *scReader) readBigInt(data uint32) (string, error) {
+	n := uint64(data &^ scBigIntNegativeFlag)
+	if n > uint64(len(r.b)-r.off)/8 {
+		return "", fmt.Errorf("%w: bigint length %d", errInvalidStructuredClone, n)
+	}
+	v := new(big.Int)
+	for i := uint64(0); i < n; i++ {
+		digit, err := r.uint64()
+		if err != nil {
+			return "", err
+		}
+		v.Or(v, new(big.Int).Lsh(new(big.Int).SetUint64(digit), uint(64*i)))
+	}
+	if data&scBigIntNegativeFlag != 0 {
+		v.Neg(v)
+	}
+	return v.String(), nil
+}
+
+// readProperties reads the key value pairs until scTagEndOfKeys.
+func (r *scReader) readProperties(set func(key, value interface{})) error {
+	for {
+		tag, _, err := r.peekPair()
+		if err != nil {
+			return err
+		}
+		if tag == scTagEndOfKeys {
+			r.off += 8
+			return nil
+		}
+		key, err := r.readValue()
+		if err != nil {
+			return err
+		}
+		value, err := r.readValue()
+		if err != nil {
+			return err
+		}
+		set(key, value)
+	}
+}
+
+func (r *scReader) readObject() (interface{}, error) {
+	id := r.begin()
+	obj := make(map[string]interface{})
+	if err := r.readProperties(func(k, v interface{}) { obj[propertyKey(k)] = v }); err != nil {
+		return nil, err
+	}
+	return r.end(id, obj), nil
+}
+
+func (r *scReader) readArray(length uint32) (interface{}, error) {
+	id := r.begin()
+	props := make(map[int64]interface{})
+	if err := r.readProperties(func(k, v interface{}) {
+		if i, ok := k.(int64); ok {
+			props[i] = v
+		}
+	}); err != nil {
+		return nil, err
+	}
+	if length > maxSparseArrayLength {
+		obj := make(map[string]interface{}, len(props))
+		for k, v := range props {
+			obj[propertyKey(k)] = v
+		}
+		return r.end(id, obj), nil
+	}
+	arr := make([]interface{}, length)
+	for k, v := range props {
+		if k >= 0 && k < int64(length) {
+			arr[k] = v
+		}
+	}
+	return r.end(id, arr), nil
+}
+
+func (r *scReader) readMap() (interface{}, error) {
+	id := r.begin()
+	var entries [][2]interface{}
+	if err := r.readProperties(func(k, v interface{}) { entries = append(entries, [2]interface{}{k, v}) }); err != nil {
+		return nil, err
+	}
+	return r.end(id, entries), nil
+}
+
+func (r *scReader) readSet() (interface{}, error) {
+	id := r.begin()
+	var values []interface{}
+	for {
+		tag, _, err := r.peekPair()
+		if err != nil {
+			return nil, err
+		}
+		if tag == scTagEndOfKeys {
+			r.off += 8
+			return r.end(id, values), nil
+		}
+		v, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+}
+
+// scTypedArrayElementSize is the element size of the typed arrays by type, Int8Array to BigUint64Array.
+var scTypedArrayElementSize = []uint64{1, 1, 2, 2, 4, 4, 4, 8, 1, 8, 8}
+
+// readView reads the typed array of the type in data or the data view, which is followed by its
+// length, the buffer and the byte offset, it's decoded as the bytes it covers.
+func (r *scReader) readView(tag, data uint32) (interface{}, error) {
+	id := r.begin()
+	length, err := r.uint64()
+	if err != nil {
+		return nil, err
+	}
+	v, err := r.readValue()
+	if err != nil {
+		return nil, err
+	}
+	offset, err := r.uint64()
+	if err != nil {
+		return nil, err
+	}
+	buf, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: view of a non buffer", errInvalidStructuredClone)
+	}
+	if tag == scTagTypedArrayObject || tag == scTagTypedArrayObjectV2 {
+		// the length of a typed array is the count of its elements
+		if int(data) >= len(scTypedArrayElementSize) || length > uint64(len(buf)) {
+			return nil, fmt.Errorf("%w: typed array type %d", errUnsupportedStructuredClone, data)
+		}
+		length *= scTypedArrayElementSize[data]
+	}
+	if offset > uint64(len(buf)) || length > uint64(len(buf))-offset {
+		return nil, fmt.Errorf("%w: view %d+%d out of buffer %d", errInvalidStructuredClone, offset, length, len(buf))
+	}
+	return r.end(id, buf[offset:offset+length]), nil
+}
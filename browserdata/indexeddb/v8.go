@@ -0,0 +1,631 @@
+package indexeddb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+	"unicode/utf16"
+)
+
+var (
+	errInvalidV8Value     = errors.New("invalid v8 serialized value")
+	errUnsupportedV8Value = errors.New("unsupported v8 serialized value")
+)
+
+// the tags of the V8 ValueSerializer
+// @https://github.com/v8/v8/blob/main/src/objects/value-serializer.cc
+const (
+	v8Version             = 0xff
+	v8Padding             = 0x00
+	v8VerifyObjectCount   = '?'
+	v8TheHole             = '-'
+	v8Undefined           = '_'
+	v8Null                = '0'
+	v8True                = 'T'
+	v8False               = 'F'
+	v8Int32               = 'I'
+	v8Uint32              = 'U'
+	v8Double              = 'N'
+	v8BigInt              = 'Z'
+	v8Utf8String          = 'S'
+	v8OneByteString       = '"'
+	v8TwoByteString       = 'c'
+	v8ObjectReference     = '^'
+	v8BeginJSObject       = 'o'
+	v8EndJSObject         = '{'
+	v8BeginSparseJSArray  = 'a'
+	v8EndSparseJSArray    = '@'
+	v8BeginDenseJSArray   = 'A'
+	v8EndDenseJSArray     = '$'
+	v8Date                = 'D'
+	v8TrueObject          = 'y'
+	v8FalseObject         = 'x'
+	v8NumberObject        = 'n'
+	v8BigIntObject        = 'z'
+	v8StringObject        = 's'
+	v8RegExp              = 'R'
+	v8BeginJSMap          = ';'
+	v8EndJSMap            = ':'
+	v8BeginJSSet          = '\''
+	v8EndJSSet            = ','
+	v8ArrayBuffer         = 'B'
+	v8ResizableBuffer     = '~'
+	v8ArrayBufferView     = 'V'
+	v8Error               = 'r'
+	v8HostObject          = '\\'
+	blinkTrailerOffsetTag = 0xfe
+)
+
+// the sub tags of the Error of V8
+const (
+	v8ErrorEvalPrototype      = 'E'
+	v8ErrorRangePrototype     = 'R'
+	v8ErrorReferencePrototype = 'F'
+	v8ErrorSyntaxPrototype    = 'S'
+	v8ErrorTypePrototype      = 'T'
+	v8ErrorURIPrototype       = 'U'
+	v8ErrorMessage            = 'm'
+	v8ErrorCause              = 'c'
+	v8ErrorStack              = 's'
+	v8ErrorEnd                = '.'
+)
+
+// v8Reader deserializes the values written by the ValueSerializer of V8 into values which can be
+// marshaled to JSON. Maps are [[key, value], ...], sets are arrays and array buffers are bytes.
+type v8Reader struct {
+	b       []byte
+	off     int
+	version uint64
+	// objects are the deserialized objects by id, for the object references
+	objects map[uint32]interface{}
+	// building marks the objects which are being deserialized, a reference to them is circular
+	building map[uint32]bool
+	nextID   uint32
+}
+
+// decodeV8 decodes the V8 serialized value of Blink, which is prefixed by the Blink header,
+// eg: ff 14 ff 0f 6f 22 03 6b 65 79 ...
+func decodeV8(b []byte) (interface{}, error) {
+	r := &v8Reader{b: b, objects: make(map[uint32]interface{}), building: make(map[uint32]bool)}
+	if err := r.readHeader(); err != nil {
+		return nil, err
+	}
+	return r.readValue()
+}
+
+// readHeader reads the version of Blink, the trailer offset of Blink since version 21, and the
+// version of V8.
+func (r *v8Reader) readHeader() error {
+	for i := 0; i < 2 && r.peek() == v8Version; i++ {
+		r.off++
+		version, err := r.varint()
+		if err != nil {
+			return err
+		}
+		r.version = version
+		if r.peek() == blinkTrailerOffsetTag {
+			// offset (8) and size (4) of the trailer
+			if _, err := r.bytes(13); err != nil {
+				return err
+			}
+		}
+	}
+	if r.version == 0 {
+		return fmt.Errorf("%w: missing version", errInvalidV8Value)
+	}
+	return nil
+}
+
+func (r *v8Reader) peek() int {
+	if r.off >= len(r.b) {
+		return -1
+	}
+	return int(r.b[r.off])
+}
+
+func (r *v8Reader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.off+n > len(r.b) {
+		return nil, fmt.Errorf("%w: unexpected end at offset %d", errInvalidV8Value, r.off)
+	}
+	b := r.b[r.off : r.off+n]
+	r.off += n
+	return b, nil
+}
+
+func (r *v8Reader) byte() (byte, error) {
+	b, err := r.bytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *v8Reader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.b[r.off:])
+	if n <= 0 {
+		return 0, fmt.Errorf("%w: invalid varint at offset %d", errInvalidV8Value, r.off)
+	}
+	r.off += n
+	return v, nil
+}
+
+func (r *v8Reader) double() (float64, error) {
+	b, err := r.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+// tag reads the next tag, the padding and the object count for verification are skipped.
+func (r *v8Reader) tag() (byte, error) {
+	for {
+		t, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		switch t {
+		case v8Padding:
+			continue
+		case v8VerifyObjectCount:
+			if _, err := r.varint(); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		return t, nil
+	}
+}
+
+// peekTag returns the next tag without consuming it, -1 at the end.
+func (r *v8Reader) peekTag() int {
+	off := r.off
+	t, err := r.tag()
+	r.off = off
+	if err != nil {
+		return -1
+	}
+	return int(t)
+}
+
+// begin allocates the id of a new object.
+func (r *v8Reader) begin() uint32 {
+	id := r.nextID
+	r.nextID++
+	r.building[id] = true
+	return id
+}
+
+func (r *v8Reader) end(id uint32, v interface{}) interface{} {
+	delete(r.building, id)
+	r.objects[id] = v
+	return v
+}
+
+func (r *v8Reader) readValue() (interface{}, error) {
+	t, err := r.tag()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case v8Undefined, v8TheHole, v8Null:
+		return nil, nil
+	case v8True:
+		return true, nil
+	case v8False:
+		return false, nil
+	case v8Int32:
+		v, err := r.varint()
+		// zigzag encoded
+		return int64(int32(uint32(v>>1) ^ -uint32(v&1))), err
+	case v8Uint32:
+		v, err := r.varint()
+		return int64(uint32(v)), err
+	case v8Double:
+		v, err := r.double()
+		return jsonNumber(v), err
+	case v8BigInt:
+		return r.readBigInt()
+	case v8Utf8String, v8OneByteString, v8TwoByteString:
+		return r.readString(t)
+	case v8ObjectReference:
+		id, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		if r.building[uint32(id)] {
+			return "[Circular]", nil
+		}
+		v, ok := r.objects[uint32(id)]
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid object reference %d", errInvalidV8Value, id)
+		}
+		return v, nil
+	case v8BeginJSObject:
+		return r.readObject()
+	case v8BeginDenseJSArray:
+		return r.readDenseArray()
+	case v8BeginSparseJSArray:
+		return r.readSparseArray()
+	case v8Date:
+		id := r.begin()
+		v, err := r.double()
+		return r.end(id, jsonDate(v)), err
+	case v8TrueObject, v8FalseObject:
+		return r.end(r.begin(), t == v8TrueObject), nil
+	case v8NumberObject:
+		id := r.begin()
+		v, err := r.double()
+		return r.end(id, jsonNumber(v)), err
+	case v8BigIntObject:
+		id := r.begin()
+		v, err := r.readBigInt()
+		return r.end(id, v), err
+	case v8StringObject:
+		id := r.begin()
+		t, err := r.tag()
+		if err != nil {
+			return nil, err
+		}
+		s, err := r.readString(t)
+		return r.end(id, s), err
+	case v8RegExp:
+		id := r.begin()
+		return r.readRegExp(id)
+	case v8BeginJSMap:
+		return r.readMap()
+	case v8BeginJSSet:
+		return r.readSet()
+	case v8ArrayBuffer, v8ResizableBuffer:
+		return r.readArrayBuffer(t == v8ResizableBuffer)
+	case v8Error:
+		return r.readError()
+	case v8HostObject:
+		return nil, fmt.Errorf("%w: host object at offset %d", errUnsupportedV8Value, r.off-1)
+	}
+	return nil, fmt.Errorf("%w: tag %#x at offset %d", errUnsupportedV8Value, t, r.off-1)
+}
+
+func (r *v8Reader) readString(t byte) (string, error) {
+	n, err := r.varint()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.bytes(int(n))
+	if err != nil {
+		return "", err
+	}
+	switch t {
+	case v8Utf8String:
+		return string(b), nil
+	case v8OneByteString:
+		return latin1(b), nil
+	case v8TwoByteString:
+		if len(b)%2 != 0 {
+			return "", fmt.Errorf("%w: odd length %d of two byte string", errInvalidV8Value, len(b))
+		}
+		u := make([]uint16, len(b)/2)
+		for i := range u {
+			u[i] = binary.LittleEndian.Uint16(b[2*i:])
+		}
+		return string(utf16.Decode(u)), nil
+	}
+	return "", fmt.Errorf("%w: tag %#x is not a string", errInvalidV8Value, t)
+}
+
+// readBigInt reads the BigInt as a decimal string, the bitfield holds the sign and the byte length
+// of the little endian digits.
+func (r *v8Reader) readBigInt() (string, error) {
+	bitfield, err := r.varint()
+	if err != nil {
+		return "", err
+	}
+	le, err := r.bytes(int(bitfield >> 1))
+	if err != nil {
+		return "", err
+	}
+	be := make([]byte, len(le))
+	for i, v := range le {
+		be[len(le)-1-i] = v
+	}
+	n := new(big.Int).SetBytes(be)
+	if bitfield&1 == 1 {
+		n.Neg(n)
+	}
+	return n.String(), nil
+}
+
+// readProperties reads the key value pairs until the end tag, the number of properties follows the end tag.
+func (r *v8Reader) readProperties(endTag byte, set func(key string, value interface{})) error {
+	for {
+		if r.peekTag() == int(endTag) {
+			_, _ = r.tag()
+			return nil
+		}
+		key, err := r.readValue()
+		if err != nil {
+			return err
+		}
+		value, err := r.readValue()
+		if err != nil {
+			return err
+		}
+		set(propertyKey(key), value)
+	}
+}
+
+func (r *v8Reader) readObject() (interface{}, error) {
+	id := r.begin()
+	obj := make(map[string]interface{})
+	if err := r.readProperties(v8EndJSObject, func(k string, v interface{}) { obj[k] = v }); err != nil {
+		return nil, err
+	}
+	if _, err := r.varint(); err != nil {
+		return nil, err
+	}
+	return r.end(id, obj), nil
+}
+
+func (r *v8Reader) readDenseArray() (interface{}, error) {
+	id := r.begin()
+	length, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(len(r.b)-r.off) {
+		return nil, fmt.Errorf("%w: dense array length %d", errInvalidV8Value, length)
+	}
+	arr := make([]interface{}, length)
+	for i := range arr {
+		if arr[i], err = r.readValue(); err != nil {
+			return nil, err
+		}
+	}
+	// the named properties of the array are dropped
+	if err := r.readProperties(v8EndDenseJSArray, func(string, interface{}) {}); err != nil {
+		return nil, err
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := r.varint(); err != nil {
+			return nil, err
+		}
+	}
+	return r.end(id, arr), nil
+}
+
+// maxSparseArrayLength is the max length of a sparse array decoded as an array, a longer one
+// is decoded as an object of its indexes.
+const maxSparseArrayLength = 1 << 16
+
+func (r *v8Reader) readSparseArray() (interface{}, error) {
+	id := r.begin()
+	length, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string]interface{})
+	if err := r.readProperties(v8EndSparseJSArray, func(k string, v interface{}) { props[k] = v }); err != nil {
+		return nil, err
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := r.varint(); err != nil {
+			return nil, err
+		}
+	}
+	if length > maxSparseArrayLength {
+		return r.end(id, props), nil
+	}
+	arr := make([]interface{}, length)
+	for k, v := range props {
+		if i, err := strconv.Atoi(k); err == nil && i >= 0 && i < len(arr) {
+			arr[i] = v
+		}
+	}
+	return r.end(id, arr), nil
+}
+
+func (r *v8Reader) readRegExp(id uint32) (interface{}, error) {
+	t, err := r.tag()
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := r.readString(t)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	return r.end(id, "/"+pattern+"/"+regExpFlags(flags)), nil
+}
+
+// regExpFlags returns the flags of the RegExp in the order of the bits of V8.
+func regExpFlags(flags uint64) string {
+	const names = "gimyusdv"
+	var s []byte
+	for i := range names {
+		if flags&(1<<i) != 0 {
+			s = append(s, names[i])
+		}
+	}
+	return string(s)
+}
+
+func (r *v8Reader) readMap() (interface{}, error) {
+	id := r.begin()
+	var entries [][2]interface{}
+	for r.peekTag() != v8EndJSMap {
+		key, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, [2]interface{}{key, value})
+	}
+	_, _ = r.tag()
+	if _, err := r.varint(); err != nil {
+		return nil, err
+	}
+	return r.end(id, entries), nil
+}
+
+func (r *v8Reader) readSet() (interface{}, error) {
+	id := r.begin()
+	var values []interface{}
+	for r.peekTag() != v8EndJSSet {
+		v, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	_, _ = r.tag()
+	if _, err := r.varint(); err != nil {
+		return nil, err
+	}
+	return r.end(id, values), nil
+}
+
+// readArrayBuffer reads the bytes of the buffer, a view of the buffer may follow it,
+// which is decoded as the bytes it covers.
+func (r *v8Reader) readArrayBuffer(resizable bool) (interface{}, error) {
+	id := r.begin()
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if resizable {
+		// max byte length
+		if _, err := r.varint(); err != nil {
+			return nil, err
+		}
+	}
+	buf, err := r.bytes(int(n))
+	if err != nil {
+		return nil, err
+	}
+	r.end(id, buf)
+	if r.peekTag() != v8ArrayBufferView {
+		return buf, nil
+	}
+	_, _ = r.tag()
+	viewID := r.begin()
+	if _, err := r.byte(); err != nil {
+		return nil, err
+	}
+	offset, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	length, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if r.version >= 14 {
+		// flags of the view
+		if _, err := r.varint(); err != nil {
+			return nil, err
+		}
+	}
+	if offset > uint64(len(buf)) || length > uint64(len(buf))-offset {
+		return nil, fmt.Errorf("%w: view %d+%d out of buffer %d", errInvalidV8Value, offset, length, len(buf))
+	}
+	return r.end(viewID, buf[offset:offset+length]), nil
+}
+
+func (r *v8Reader) readError() (interface{}, error) {
+	id := r.begin()
+	e := map[string]interface{}{"name": "Error"}
+	for {
+		t, err := r.tag()
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case v8ErrorEvalPrototype:
+			e["name"] = "EvalError"
+		case v8ErrorRangePrototype:
+			e["name"] = "RangeError"
+		case v8ErrorReferencePrototype:
+			e["name"] = "ReferenceError"
+		case v8ErrorSyntaxPrototype:
+			e["name"] = "SyntaxError"
+		case v8ErrorTypePrototype:
+			e["name"] = "TypeError"
+		case v8ErrorURIPrototype:
+			e["name"] = "URIError"
+		case v8ErrorMessage, v8ErrorStack:
+			st, err := r.tag()
+			if err != nil {
+				return nil, err
+			}
+			s, err := r.readString(st)
+			if err != nil {
+				return nil, err
+			}
+			if t == v8ErrorMessage {
+				e["message"] = s
+			} else {
+				e["stack"] = s
+			}
+		case v8ErrorCause:
+			if e["cause"], err = r.readValue(); err != nil {
+				return nil, err
+			}
+		case v8ErrorEnd:
+			return r.end(id, e), nil
+		default:
+			return nil, fmt.Errorf("%w: error tag %#x", errUnsupportedV8Value, t)
+		}
+	}
+}
+
+// propertyKey returns the key of the property, which is a string or a number.
+func propertyKey(key interface{}) string {
+	switch k := key.(type) {
+	case string:
+		return k
+	case int64:
+		return strconv.FormatInt(k, 10)
+	case float64:
+		return strconv.FormatFloat(k, 'f', -1, 64)
+	}
+	return fmt.Sprint(key)
+}
+
+// jsonNumber returns the number, NaN and the infinities which JSON can't hold are strings.
+func jsonNumber(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+// jsonDate returns the date of the milliseconds since the Unix epoch.
+func jsonDate(ms float64) interface{} {
+	if math.IsNaN(ms) || math.IsInf(ms, 0) {
+		return "Invalid Date"
+	}
+	return time.UnixMilli(int64(ms)).UTC()
+}
+
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
@@ -0,0 +1,122 @@
+package indexeddb
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// v8Value prefixes the body with the headers of Blink version 20 and V8 version 15.
+func v8Value(body ...byte) []byte {
+	return append([]byte{0xff, 0x14, 0xff, 0x0f}, body...)
+}
+
+func oneByteString(s string) []byte {
+	return append([]byte{v8OneByteString, byte(len(s))}, s...)
+}
+
+func v8DoubleBytes(f float64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, math.Float64bits(f))
+}
+
+func TestDecodeV8(t *testing.T) {
+	date := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name string
+		in   []byte
+		want interface{}
+	}{
+		{name: "null", in: v8Value(v8Null), want: nil},
+		{name: "true", in: v8Value(v8True), want: true},
+		{name: "int32", in: v8Value(v8Int32, 0x53), want: int64(-42)},
+		{name: "double", in: v8Value(append([]byte{v8Double}, v8DoubleBytes(1.5)...)...), want: 1.5},
+		{name: "nan", in: v8Value(append([]byte{v8Double}, v8DoubleBytes(math.NaN())...)...), want: "NaN"},
+		{name: "bigint", in: v8Value(v8BigInt, 0x11, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80), want: "-9223372036854775808"},
+		{name: "utf8 string", in: v8Value(append([]byte{v8Utf8String, 5}, "café"...)...), want: "café"},
+		{name: "two byte string", in: v8Value(v8TwoByteString, 4, 0x2d, 0x4e, 0x87, 0x65), want: "中文"},
+		{name: "padding", in: v8Value(v8Padding, v8Padding, v8False), want: false},
+		{
+			name: "object",
+			in: v8Value(append(append(append(append([]byte{v8BeginJSObject}, oneByteString("title")...),
+				oneByteString("hi")...), v8Int32, 0x02, v8True), v8EndJSObject, 2)...),
+			want: map[string]interface{}{"title": "hi", "1": true},
+		},
+		{
+			name: "dense array with hole",
+			in:   v8Value(v8BeginDenseJSArray, 3, v8Int32, 0x02, v8TheHole, v8Undefined, v8EndDenseJSArray, 0, 3),
+			want: []interface{}{int64(1), nil, nil},
+		},
+		{
+			name: "sparse array",
+			in:   v8Value(v8BeginSparseJSArray, 3, v8Int32, 0x04, v8True, v8EndSparseJSArray, 1, 3),
+			want: []interface{}{nil, nil, true},
+		},
+		{
+			name: "date",
+			in:   v8Value(append([]byte{v8Date}, v8DoubleBytes(float64(date.UnixMilli()))...)...),
+			want: date,
+		},
+		{
+			name: "map and set",
+			in: v8Value(append(append([]byte{v8BeginJSMap}, oneByteString("k")...),
+				v8BeginJSSet, v8Int32, 0x02, v8EndJSSet, 1, v8EndJSMap, 2)...),
+			want: [][2]interface{}{{"k", []interface{}{int64(1)}}},
+		},
+		{
+			name: "typed array",
+			in:   v8Value(v8ArrayBuffer, 4, 1, 2, 3, 4, v8ArrayBufferView, 'B', 1, 2, 0),
+			want: []byte{2, 3},
+		},
+		{
+			name: "regexp",
+			in:   v8Value(append(append([]byte{v8RegExp}, oneByteString("a+")...), 0x03)...),
+			want: "/a+/gi",
+		},
+		{
+			name: "object reference",
+			in: v8Value(append(append([]byte{v8BeginDenseJSArray, 2, v8BeginJSObject}, oneByteString("a")...),
+				v8Null, v8EndJSObject, 1, v8ObjectReference, 1, v8EndDenseJSArray, 0, 2)...),
+			want: []interface{}{map[string]interface{}{"a": nil}, map[string]interface{}{"a": nil}},
+		},
+		{
+			name: "circular reference",
+			in:   v8Value(append(append([]byte{v8BeginJSObject}, oneByteString("self")...), v8ObjectReference, 0, v8EndJSObject, 1)...),
+			want: map[string]interface{}{"self": "[Circular]"},
+		},
+		{
+			name: "error",
+			in:   v8Value(append(append([]byte{v8Error, v8ErrorTypePrototype, v8ErrorMessage}, oneByteString("bad")...), v8ErrorEnd)...),
+			want: map[string]interface{}{"name": "TypeError", "message": "bad"},
+		},
+		{
+			name: "blink trailer offset",
+			in:   []byte{0xff, 0x15, 0xfe, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0x0f, v8True},
+			want: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := decodeV8(tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, v)
+		})
+	}
+}
+
+func TestDecodeV8_Invalid(t *testing.T) {
+	_, err := decodeV8([]byte{v8True})
+	assert.ErrorIs(t, err, errInvalidV8Value)
+
+	_, err = decodeV8(v8Value(v8BeginJSObject))
+	assert.ErrorIs(t, err, errInvalidV8Value)
+
+	_, err = decodeV8(v8Value(v8HostObject, 'b'))
+	assert.ErrorIs(t, err, errUnsupportedV8Value)
+
+	_, err = decodeV8(v8Value(v8ObjectReference, 5))
+	assert.ErrorIs(t, err, errInvalidV8Value)
+}
@@ -2,19 +2,28 @@ package localstorage
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/golang/snappy"
 	"github.com/syndtr/goleveldb/leveldb"
 	"golang.org/x/text/encoding/unicode"
 	"golang.org/x/text/transform"
+	_ "modernc.org/sqlite" // import sqlite3 driver
 
+	"github.com/moond4rk/hackbrowserdata/crypto"
 	"github.com/moond4rk/hackbrowserdata/extractor"
 	"github.com/moond4rk/hackbrowserdata/log"
 	"github.com/moond4rk/hackbrowserdata/types"
-	"github.com/moond4rk/hackbrowserdata/utils/byteutil"
+	"github.com/moond4rk/hackbrowserdata/utils/fileutil"
 	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
 )
 
@@ -27,39 +36,53 @@ func init() {
 	})
 }
 
-type ChromiumLocalStorage []storage
+type ChromiumLocalStorage []Storage
 
-type storage struct {
-	IsMeta bool
-	URL    string
-	Key    string
-	Value  string
+// Storage is a localStorage entry of an origin, IsMeta marks the VERSION, META and METAACCESS entries.
+// Usage is the quota usage of the origin in UTF-16 code units, it's only known for Firefox.
+// LastModified, LastAccessed and Size are decoded from the META and METAACCESS entries of Chromium.
+type Storage struct {
+	IsMeta       bool
+	URL          string
+	Key          string
+	Value        string
+	Usage        int64
+	LastModified time.Time
+	LastAccessed time.Time
+	Size         int64
 }
 
-const maxLocalStorageValueLength = 1024 * 2
+// DefaultMaxValueLength is the max length in bytes of a value kept in the result by default.
+const DefaultMaxValueLength = 1024 * 2
 
-func (c *ChromiumLocalStorage) Extract(_ []byte) error {
-	db, err := leveldb.OpenFile(types.ChromiumLocalStorage.TempFilename(), nil)
+// the keys of the localStorage leveldb of Chromium
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/services/storage/dom_storage/local_storage_impl.cc
+const (
+	keyVersion           = "VERSION"
+	keyMetaPrefix        = "META:"
+	keyMetaAccessPrefix  = "METAACCESS:"
+	keyDataPrefix        = "_"
+	metaKeyName          = "META"
+	metaAccessKeyName    = "METAACCESS"
+	stringEncodingUTF16  = 0x00
+	stringEncodingLatin1 = 0x01
+)
+
+var errUnknownStringEncoding = errors.New("unknown localStorage string encoding")
+
+func (c *ChromiumLocalStorage) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := leveldb.OpenFile(path, nil)
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(types.ChromiumLocalStorage.TempFilename())
 	defer db.Close()
 
 	iter := db.NewIterator(nil, nil)
 	for iter.Next() {
-		key := iter.Key()
-		value := iter.Value()
-		s := new(storage)
-		s.fillKey(key)
-		// don't all value upper than 2KB
-		if len(value) < maxLocalStorageValueLength {
-			s.fillValue(value)
-		} else {
-			s.Value = fmt.Sprintf("value is too long, length is %d, supported max length is %d", len(value), maxLocalStorageValueLength)
-		}
-		if s.IsMeta {
-			s.Value = fmt.Sprintf("meta data, value bytes is %v", value)
+		s, err := parseChromiumEntry(iter.Key(), iter.Value())
+		if err != nil {
+			log.Debugf("parse chromium local storage key %q error: %v", iter.Key(), err)
+			continue
 		}
 		*c = append(*c, *s)
 	}
@@ -76,24 +99,157 @@ func (c *ChromiumLocalStorage) Len() int {
 	return len(*c)
 }
 
-func (s *storage) fillKey(b []byte) {
-	keys := bytes.Split(b, []byte("\x00"))
-	if len(keys) == 1 && bytes.HasPrefix(keys[0], []byte("META:")) {
-		s.IsMeta = true
-		s.fillMetaHeader(keys[0])
+// LimitValues replaces the values longer than maxLength by a note, they are exported to dir if it's set.
+func (c *ChromiumLocalStorage) LimitValues(maxLength int, dir string) {
+	for i := range *c {
+		(*c)[i].limitValue(maxLength, dir)
 	}
-	if len(keys) == 2 && bytes.HasPrefix(keys[0], []byte("_")) {
-		s.fillHeader(keys[0], keys[1])
+}
+
+// parseChromiumEntry parses a record of the localStorage leveldb, eg:
+//
+//	VERSION                              -> 1
+//	META:https://github.com              -> LocalStorageOriginMetaData {last_modified, size_bytes}
+//	METAACCESS:https://github.com        -> LocalStorageAreaAccessMetaData {last_accessed}
+//	_https://github.com\x00<encoded key> -> <encoded value>
+func parseChromiumEntry(key, value []byte) (*Storage, error) {
+	switch {
+	case string(key) == keyVersion:
+		return &Storage{IsMeta: true, Key: keyVersion, Value: string(value)}, nil
+	case bytes.HasPrefix(key, []byte(keyMetaAccessPrefix)):
+		fields, err := readVarintFields(value)
+		if err != nil {
+			return nil, err
+		}
+		return &Storage{
+			IsMeta:       true,
+			URL:          string(key[len(keyMetaAccessPrefix):]),
+			Key:          metaAccessKeyName,
+			LastAccessed: typeutil.TimeEpoch(int64(fields[1])),
+		}, nil
+	case bytes.HasPrefix(key, []byte(keyMetaPrefix)):
+		fields, err := readVarintFields(value)
+		if err != nil {
+			return nil, err
+		}
+		return &Storage{
+			IsMeta:       true,
+			URL:          string(key[len(keyMetaPrefix):]),
+			Key:          metaKeyName,
+			LastModified: typeutil.TimeEpoch(int64(fields[1])),
+			Size:         int64(fields[2]),
+		}, nil
+	case bytes.HasPrefix(key, []byte(keyDataPrefix)):
+		// the origin never contains NUL, but the UTF-16 key does
+		origin, k, ok := bytes.Cut(key[len(keyDataPrefix):], []byte{0})
+		if !ok {
+			return nil, errors.New("missing key separator")
+		}
+		s := &Storage{URL: string(origin)}
+		var err error
+		if s.Key, err = decodeString(k); err != nil {
+			return nil, err
+		}
+		if s.Value, err = decodeString(value); err != nil {
+			return nil, err
+		}
+		return s, nil
 	}
+	return nil, errors.New("unknown key")
 }
 
-func (s *storage) fillMetaHeader(b []byte) {
-	s.URL = string(bytes.Trim(b, "META:"))
+// decodeString decodes the string of Chromium, which is prefixed by the encoding,
+// 0x00 is UTF-16LE and 0x01 is Latin-1.
+func decodeString(b []byte) (string, error) {
+	if len(b) == 0 {
+		return "", nil
+	}
+	switch b[0] {
+	case stringEncodingUTF16:
+		if len(b)%2 != 1 {
+			return "", fmt.Errorf("odd length %d of UTF-16 string", len(b)-1)
+		}
+		s, err := convertUTF16toUTF8(b[1:], unicode.LittleEndian)
+		return string(s), err
+	case stringEncodingLatin1:
+		r := make([]rune, len(b)-1)
+		for i, c := range b[1:] {
+			r[i] = rune(c)
+		}
+		return string(r), nil
+	}
+	return "", fmt.Errorf("%w: %#x", errUnknownStringEncoding, b[0])
+}
+
+// readVarintFields reads the varint fields of the protobuf message by field number,
+// the fields of other wire types are skipped.
+func readVarintFields(b []byte) (map[int]uint64, error) {
+	fields := make(map[int]uint64)
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errors.New("invalid protobuf tag")
+		}
+		b = b[n:]
+		var skip uint64
+		switch tag & 7 {
+		case 0:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errors.New("invalid protobuf varint")
+			}
+			fields[int(tag>>3)] = v
+			b = b[n:]
+			continue
+		case 1:
+			skip = 8
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errors.New("invalid protobuf length")
+			}
+			b, skip = b[n:], l
+		case 5:
+			skip = 4
+		default:
+			return nil, fmt.Errorf("unsupported protobuf wire type %d", tag&7)
+		}
+		if skip > uint64(len(b)) {
+			return nil, fmt.Errorf("truncated protobuf field %d", tag>>3)
+		}
+		b = b[skip:]
+	}
+	return fields, nil
+}
+
+// limitValue replaces a value longer than maxLength by a note, and exports it to dir if it's set,
+// a maxLength of 0 means no limit.
+func (s *Storage) limitValue(maxLength int, dir string) {
+	if maxLength <= 0 || len(s.Value) <= maxLength {
+		return
+	}
+	value := s.Value
+	s.Value = fmt.Sprintf("value is too long, length is %d, supported max length is %d", len(value), maxLength)
+	if dir == "" {
+		return
+	}
+	filename, err := exportValue(dir, value)
+	if err != nil {
+		log.Errorf("export local storage value of %s error: %v", s.URL, err)
+		return
+	}
+	s.Value = fmt.Sprintf("value is too long, length is %d, exported to %s", len(value), filename)
 }
 
-func (s *storage) fillHeader(url, key []byte) {
-	s.URL = string(bytes.Trim(url, "_"))
-	s.Key = string(bytes.Trim(key, "\x01"))
+// exportValue writes the value to dir, the file is named by the SHA-256 of the value,
+// so the same value of different browsers is written once.
+func exportValue(dir, value string) (string, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(value))
+	filename := filepath.Join(dir, hex.EncodeToString(sum[:16])+".txt")
+	return filename, os.WriteFile(filename, []byte(value), 0o600)
 }
 
 func convertUTF16toUTF8(source []byte, endian unicode.Endianness) ([]byte, error) {
@@ -101,26 +257,83 @@ func convertUTF16toUTF8(source []byte, endian unicode.Endianness) ([]byte, error
 	return r, err
 }
 
-// fillValue fills value of the storage
-// TODO: support unicode charter
-func (s *storage) fillValue(b []byte) {
-	value := bytes.Map(byteutil.OnSplitUTF8Func, b)
-	s.Value = string(value)
-}
-
-type FirefoxLocalStorage []storage
+// FirefoxLocalStorage reads the localStorage of Firefox, path is either the legacy webappsstore.sqlite
+// or a copy of the profile's storage, which holds default/<origin>/ls/data.sqlite of each origin
+// and the webappsstore.sqlite.
+type FirefoxLocalStorage []Storage
 
 const (
 	queryLocalStorage = `SELECT originKey, key, value FROM webappsstore2`
 	closeJournalMode  = `PRAGMA journal_mode=off`
 )
 
-func (f *FirefoxLocalStorage) Extract(_ []byte) error {
-	db, err := sql.Open("sqlite", types.FirefoxLocalStorage.TempFilename())
+// the layout of the local storage of Firefox 68+ (LSNG), eg: storage/default/https+++github.com/ls/data.sqlite
+const (
+	dirStorageDefault = "default"
+	dirLocalStorage   = "ls"
+	fileLocalStorage  = "data.sqlite"
+)
+
+func (f *FirefoxLocalStorage) Extract(_ crypto.MasterKeys, path string) error {
+	info, err := os.Stat(path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(types.FirefoxLocalStorage.TempFilename())
+	if !info.IsDir() {
+		legacy, err := readWebappsStore(path)
+		if err != nil {
+			return err
+		}
+		*f = legacy
+		return nil
+	}
+
+	// the data of LSNG is newer, Firefox migrates webappsstore.sqlite into it on first access of an origin
+	seen := make(map[[2]string]bool)
+	origins, err := os.ReadDir(filepath.Join(path, dirStorageDefault))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, origin := range origins {
+		dbPath := filepath.Join(path, dirStorageDefault, origin.Name(), dirLocalStorage, fileLocalStorage)
+		if !origin.IsDir() || !fileutil.IsFileExists(dbPath) {
+			continue
+		}
+		entries, err := readLocalStorageDB(dbPath, fileutil.DecodeOriginDir(origin.Name()))
+		if err != nil {
+			log.Errorf("read firefox local storage %s error: %v", dbPath, err)
+			continue
+		}
+		for _, e := range entries {
+			seen[[2]string{e.URL, e.Key}] = true
+		}
+		*f = append(*f, entries...)
+	}
+
+	legacyPath := filepath.Join(path, types.FirefoxLocalStorage.Filename())
+	if !fileutil.IsFileExists(legacyPath) {
+		return nil
+	}
+	legacy, err := readWebappsStore(legacyPath)
+	if err != nil {
+		log.Errorf("read firefox webappsstore error: %v", err)
+		return nil
+	}
+	for _, e := range legacy {
+		if !seen[[2]string{e.URL, e.Key}] {
+			*f = append(*f, e)
+		}
+	}
+	return nil
+}
+
+// readWebappsStore reads the legacy webappsstore.sqlite, the usage of an origin is summed
+// from the UTF-16 length of its keys and values like LSNG does.
+func readWebappsStore(path string) ([]Storage, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
 	defer db.Close()
 
 	_, err = db.Exec(closeJournalMode)
@@ -129,22 +342,127 @@ func (f *FirefoxLocalStorage) Extract(_ []byte) error {
 	}
 	rows, err := db.Query(queryLocalStorage)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer rows.Close()
+	var storages []Storage
+	usage := make(map[string]int64)
 	for rows.Next() {
 		var originKey, key, value string
 		if err = rows.Scan(&originKey, &key, &value); err != nil {
 			log.Errorf("scan firefox local storage error: %v", err)
 		}
-		s := new(storage)
+		s := new(Storage)
 		s.fillFirefox(originKey, key, value)
-		*f = append(*f, *s)
+		usage[s.URL] += utf16Length(key) + utf16Length(value)
+		storages = append(storages, *s)
 	}
-	return nil
+	for i := range storages {
+		storages[i].Usage = usage[storages[i].URL]
+	}
+	return storages, rows.Err()
+}
+
+const (
+	queryLocalStorageOrigin = `SELECT origin, usage FROM database`
+	queryLocalStorageData   = `SELECT key, value, %s FROM data`
+	queryLocalStorageColumn = `SELECT name FROM pragma_table_info('data')`
+)
+
+// readLocalStorageDB reads the ls/data.sqlite of an origin, the origin of the database table is
+// preferred to the one decoded from the directory name.
+func readLocalStorageDB(path, origin string) ([]Storage, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(closeJournalMode)
+	if err != nil {
+		log.Errorf("close journal mode error: %v", err)
+	}
+	var (
+		dbOrigin string
+		usage    int64
+	)
+	if err := db.QueryRow(queryLocalStorageOrigin).Scan(&dbOrigin, &usage); err != nil {
+		log.Debugf("query firefox local storage origin of %s error: %v", path, err)
+	}
+	if dbOrigin != "" {
+		origin = dbOrigin
+	}
+	compression, err := compressionColumn(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(fmt.Sprintf(queryLocalStorageData, compression))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var storages []Storage
+	for rows.Next() {
+		var (
+			key        string
+			value      []byte
+			compressed int64
+		)
+		if err = rows.Scan(&key, &value, &compressed); err != nil {
+			log.Errorf("scan firefox local storage error: %v", err)
+			continue
+		}
+		// compression type 1 is snappy, the value is stored as UTF-8
+		if compressed != 0 {
+			if value, err = snappy.Decode(nil, value); err != nil {
+				log.Errorf("decompress firefox local storage %s of %s error: %v", key, origin, err)
+				continue
+			}
+		}
+		storages = append(storages, Storage{URL: origin, Key: key, Value: string(value), Usage: usage})
+	}
+	return storages, rows.Err()
 }
 
-func (s *storage) fillFirefox(originKey, key, value string) {
+// compressionColumn returns the column of the compression flag in the data table,
+// it's compression_type since Firefox 79, and compressed before.
+func compressionColumn(db *sql.DB) (string, error) {
+	rows, err := db.Query(queryLocalStorageColumn)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return "", err
+		}
+		if name == "compression_type" || name == "compressed" {
+			return name, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return "0", nil
+}
+
+// utf16Length returns the length of the string in UTF-16 code units.
+func utf16Length(s string) int64 {
+	var n int64
+	for _, r := range s {
+		if r >= 0x10000 {
+			n++
+		}
+		n++
+	}
+	return n
+}
+
+// fillFirefox fills the storage from the webappsstore2 row, the default port of the scheme
+// is dropped to match the origin of LSNG.
+func (s *Storage) fillFirefox(originKey, key, value string) {
 	// originKey = moc.buhtig.:https:443
 	p := strings.Split(originKey, ":")
 	h := typeutil.Reverse([]byte(p[0]))
@@ -152,7 +470,10 @@ func (s *storage) fillFirefox(originKey, key, value string) {
 		h = h[1:]
 	}
 	if len(p) == 3 {
-		s.URL = fmt.Sprintf("%s://%s:%s", p[1], string(h), p[2])
+		s.URL = fmt.Sprintf("%s://%s", p[1], string(h))
+		if !(p[1] == "https" && p[2] == "443") && !(p[1] == "http" && p[2] == "80") {
+			s.URL += ":" + p[2]
+		}
 	}
 	s.Key = key
 	s.Value = value
@@ -165,3 +486,10 @@ func (f *FirefoxLocalStorage) Name() string {
 func (f *FirefoxLocalStorage) Len() int {
 	return len(*f)
 }
+
+// LimitValues replaces the values longer than maxLength by a note, they are exported to dir if it's set.
+func (f *FirefoxLocalStorage) LimitValues(maxLength int, dir string) {
+	for i := range *f {
+		(*f)[i].limitValue(maxLength, dir)
+	}
+}
@@ -1,10 +1,23 @@
 package localstorage
 
 import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"unicode/utf16"
 
+	"github.com/golang/snappy"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/syndtr/goleveldb/leveldb"
 	"golang.org/x/text/encoding/unicode"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/internal/testutil"
+	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
 )
 
 var testCases = []struct {
@@ -31,3 +44,138 @@ func TestLocalStorageKeyToUTF8(t *testing.T) {
 		assert.Equal(t, tc.actual, actual, "chinese characters can't actual convert")
 	}
 }
+
+// newLSNGDB writes the ls/data.sqlite of the origin dir under the default dir of storage,
+// the values are compressed with snappy if compressed is set.
+func newLSNGDB(t *testing.T, storage, originDir, origin, compressionColumn string, values map[string]string, compressed bool) {
+	t.Helper()
+	dir := filepath.Join(storage, dirStorageDefault, originDir, dirLocalStorage)
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	path := filepath.Join(dir, fileLocalStorage)
+	testutil.NewSQLite(t, path,
+		`CREATE TABLE database (origin TEXT NOT NULL, usage INTEGER NOT NULL DEFAULT 0)`,
+		fmt.Sprintf(`CREATE TABLE data (key TEXT PRIMARY KEY, utf16_length INTEGER, %s INTEGER, value BLOB)`, compressionColumn),
+	)
+	if origin != "" {
+		testutil.ExecSQLite(t, path, `INSERT INTO database VALUES (?, ?)`, origin, 42)
+	}
+	for k, v := range values {
+		value, flag := []byte(v), 0
+		if compressed {
+			value, flag = snappy.Encode(nil, value), 1
+		}
+		testutil.ExecSQLite(t, path, fmt.Sprintf(`INSERT INTO data (key, utf16_length, %s, value) VALUES (?, ?, ?, ?)`, compressionColumn),
+			k, len(v), flag, value)
+	}
+}
+
+func newWebappsStore(t *testing.T, path string, rows [][3]string) {
+	t.Helper()
+	testutil.NewSQLite(t, path, `CREATE TABLE webappsstore2 (originAttributes TEXT, originKey TEXT, scope TEXT, key TEXT, value TEXT)`)
+	for _, r := range rows {
+		testutil.ExecSQLite(t, path, `INSERT INTO webappsstore2 (originKey, key, value) VALUES (?, ?, ?)`, r[0], r[1], r[2])
+	}
+}
+
+func TestFirefoxLocalStorage_Extract(t *testing.T) {
+	storage := t.TempDir()
+	newLSNGDB(t, storage, "https+++github.com", "https://github.com", "compression_type",
+		map[string]string{"theme": strings.Repeat("dark", 32)}, true)
+	newLSNGDB(t, storage, "http+++localhost+8080", "", "compressed",
+		map[string]string{"token": "abc"}, false)
+	newWebappsStore(t, filepath.Join(storage, "webappsstore.sqlite"), [][3]string{
+		{"moc.buhtig.:https:443", "theme", "light"},
+		{"moc.buhtig.:https:443", "lang", "en"},
+	})
+
+	var f FirefoxLocalStorage
+	require.NoError(t, f.Extract(crypto.MasterKeys{}, storage))
+	assert.ElementsMatch(t, FirefoxLocalStorage{
+		{URL: "http://localhost:8080", Key: "token", Value: "abc"},
+		{URL: "https://github.com", Key: "theme", Value: strings.Repeat("dark", 32), Usage: 42},
+		{URL: "https://github.com", Key: "lang", Value: "en", Usage: 16},
+	}, f)
+}
+
+func TestFirefoxLocalStorage_ExtractLegacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webappsstore.sqlite")
+	newWebappsStore(t, path, [][3]string{{"gro.elpmaxe.:http:8080", "k", "v"}})
+
+	var f FirefoxLocalStorage
+	require.NoError(t, f.Extract(crypto.MasterKeys{}, path))
+	assert.Equal(t, FirefoxLocalStorage{{URL: "http://example.org:8080", Key: "k", Value: "v", Usage: 2}}, f)
+}
+
+func utf16leString(s string) []byte {
+	b := []byte{stringEncodingUTF16}
+	for _, r := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, r)
+	}
+	return b
+}
+
+func TestChromiumLocalStorage_Extract(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leveldb")
+	db, err := leveldb.OpenFile(path, nil)
+	require.NoError(t, err)
+	// last_modified = 13300000000000000, size_bytes = 120
+	meta := append(binary.AppendUvarint([]byte{0x08}, 13300000000000000), 0x10, 120)
+	access := binary.AppendUvarint([]byte{0x08}, 13300000001000000)
+	for k, v := range map[string][]byte{
+		"VERSION":                          []byte("1"),
+		"META:https://github.com":          meta,
+		"METAACCESS:https://github.com":    access,
+		"_https://github.com\x00\x01theme": append([]byte{stringEncodingLatin1}, "caf\xe9"...),
+		"_https://github.com\x00" + string(utf16leString("名字")): utf16leString("飞连"),
+		"_https://github.com\x00\x01bad":                        {0x05, 'x'},
+	} {
+		require.NoError(t, db.Put([]byte(k), v, nil))
+	}
+	require.NoError(t, db.Close())
+
+	var c ChromiumLocalStorage
+	require.NoError(t, c.Extract(crypto.MasterKeys{}, path))
+	assert.ElementsMatch(t, ChromiumLocalStorage{
+		{IsMeta: true, Key: "VERSION", Value: "1"},
+		{IsMeta: true, URL: "https://github.com", Key: "META", LastModified: typeutil.TimeEpoch(13300000000000000), Size: 120},
+		{IsMeta: true, URL: "https://github.com", Key: "METAACCESS", LastAccessed: typeutil.TimeEpoch(13300000001000000)},
+		{URL: "https://github.com", Key: "theme", Value: "café"},
+		{URL: "https://github.com", Key: "名字", Value: "飞连"},
+	}, c)
+}
+
+func TestDecodeString(t *testing.T) {
+	s, err := decodeString(nil)
+	require.NoError(t, err)
+	assert.Empty(t, s)
+
+	_, err = decodeString([]byte{stringEncodingUTF16, 'a'})
+	assert.Error(t, err)
+
+	_, err = decodeString([]byte{0x02, 'a'})
+	assert.ErrorIs(t, err, errUnknownStringEncoding)
+}
+
+func TestChromiumLocalStorage_LimitValues(t *testing.T) {
+	value := strings.Repeat("x", 16)
+	c := ChromiumLocalStorage{{Key: "short", Value: "x"}, {Key: "long", Value: value}}
+
+	c.LimitValues(0, "")
+	assert.Equal(t, value, c[1].Value)
+
+	c.LimitValues(8, "")
+	assert.Equal(t, "x", c[0].Value)
+	assert.Equal(t, "value is too long, length is 16, supported max length is 8", c[1].Value)
+
+	dir := t.TempDir()
+	f := FirefoxLocalStorage{{Key: "long", Value: value}}
+	f.LimitValues(8, dir)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	filename := filepath.Join(dir, entries[0].Name())
+	assert.Equal(t, "value is too long, length is 16, exported to "+filename, f[0].Value)
+	b, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, value, string(b))
+}
@@ -1,10 +1,14 @@
 package password
 
 import (
+	"crypto/sha1"
 	"database/sql"
 	"encoding/base64"
+	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/tidwall/gjson"
@@ -14,6 +18,7 @@ import (
 	"github.com/moond4rk/hackbrowserdata/extractor"
 	"github.com/moond4rk/hackbrowserdata/log"
 	"github.com/moond4rk/hackbrowserdata/types"
+	"github.com/moond4rk/hackbrowserdata/utils/fileutil"
 	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
 )
 
@@ -27,59 +32,155 @@ func init() {
 	extractor.RegisterExtractor(types.FirefoxPassword, func() extractor.Extractor {
 		return new(FirefoxPassword)
 	})
+	extractor.RegisterExtractor(types.FirefoxLegacyPassword, func() extractor.Extractor {
+		return new(FirefoxLegacyPassword)
+	})
 }
 
-type ChromiumPassword []loginData
+type ChromiumPassword []LoginData
 
-type loginData struct {
+// LoginData is a saved login, with the username and password already decrypted.
+type LoginData struct {
 	UserName    string
 	encryptPass []byte
 	encryptUser []byte
 	Password    string
 	LoginURL    string
 	CreateDate  time.Time
+	// EncryptScheme is the scheme of the Chromium encrypted password, eg: v10, v11, dpapi
+	EncryptScheme string
+	// DecryptFailed is set when the password can't be decrypted
+	DecryptFailed bool
+	// the fields below are read from the Login Data of Chromium, ActionURL is the url the form is
+	// submitted to, SignonRealm is the scheme, host and port of the login, eg: https://github.com/
+	ActionURL            string
+	SignonRealm          string
+	TimesUsed            int64
+	LastUsedDate         time.Time
+	PasswordModifiedDate time.Time
+	// Blacklisted is set for the sites the user chose to never save the password for
+	Blacklisted   bool
+	FederationURL string
+	Note          string
+	// Insecure is the insecurity types of the password joined by '|', eg: leaked|reused
+	Insecure string
+	// Store is the file of the credential store the login is read from, eg: Login Data For Account, logins.json
+	Store string
 }
 
 const (
-	queryChromiumLogin = `SELECT origin_url, username_value, password_value, date_created FROM logins`
+	queryChromiumLogin               = `SELECT rowid, origin_url, COALESCE(username_value, ''), password_value, date_created, %s FROM logins`
+	queryChromiumLoginColumns        = `SELECT name FROM pragma_table_info('logins')`
+	queryChromiumPasswordNotes       = `SELECT parent_id, value FROM password_notes`
+	queryChromiumInsecureCredentials = `SELECT parent_id, insecurity_type FROM insecure_credentials ORDER BY insecurity_type`
 )
 
-func (c *ChromiumPassword) Extract(masterKey []byte) error {
-	db, err := sql.Open("sqlite", types.ChromiumPassword.TempFilename())
+// the columns of logins added in the newer Login Data, the default value is selected if the column is missing
+var chromiumLoginColumns = []struct {
+	name, fallback string
+}{
+	{"action_url", "''"},
+	{"signon_realm", "''"},
+	{"times_used", "0"},
+	{"date_last_used", "0"},
+	{"date_password_modified", "0"},
+	{"blacklisted_by_user", "0"},
+	{"federation_url", "''"},
+}
+
+// the insecurity types of insecure_credentials
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/password_manager/core/browser/password_form.h
+var chromiumInsecurityTypes = []string{
+	"leaked",
+	"phished",
+	"weak",
+	"reused",
+}
+
+// Extract reads the logins of the credential stores copied into the dir of path,
+// eg: Login Data, Login Data For Account.
+func (c *ChromiumPassword) Extract(keys crypto.MasterKeys, path string) error {
+	var lastErr error
+	for _, store := range types.ChromiumPassword.Filenames() {
+		storePath := filepath.Join(path, store)
+		if !fileutil.IsFileExists(storePath) {
+			continue
+		}
+		logins, err := readChromiumLogins(keys, storePath, store)
+		if err != nil {
+			log.Errorf("read chromium %s error: %v", store, err)
+			lastErr = err
+			continue
+		}
+		*c = append(*c, logins...)
+	}
+	if len(*c) == 0 && lastErr != nil {
+		return lastErr
+	}
+	// sort with create date
+	sort.SliceStable(*c, func(i, j int) bool {
+		return (*c)[i].CreateDate.After((*c)[j].CreateDate)
+	})
+	return nil
+}
+
+// readChromiumLogins reads the logins of a store with the columns found in its schema version, the notes
+// of password_notes and the insecurity types of insecure_credentials are linked by the id of the login.
+func readChromiumLogins(keys crypto.MasterKeys, path, store string) ([]LoginData, error) {
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer os.Remove(types.ChromiumPassword.TempFilename())
 	defer db.Close()
 
-	rows, err := db.Query(queryChromiumLogin)
+	query, err := chromiumLoginQuery(db)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	notes := chromiumPasswordNotes(db, keys)
+	insecure := chromiumInsecureCredentials(db)
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
+	var logins []LoginData
 	for rows.Next() {
 		var (
-			url, username string
-			pwd, password []byte
-			create        int64
+			id                              int64
+			url, username                   string
+			pwd, password                   []byte
+			create, lastUsed, modified      int64
+			actionURL, realm, federationURL string
+			timesUsed                       int64
+			blacklisted                     int
 		)
-		if err := rows.Scan(&url, &username, &pwd, &create); err != nil {
+		if err := rows.Scan(&id, &url, &username, &pwd, &create,
+			&actionURL, &realm, &timesUsed, &lastUsed, &modified, &blacklisted, &federationURL); err != nil {
 			log.Errorf("scan chromium password error: %v", err)
 		}
-		login := loginData{
-			UserName:    username,
-			encryptPass: pwd,
-			LoginURL:    url,
+		login := LoginData{
+			UserName:             username,
+			encryptPass:          pwd,
+			LoginURL:             url,
+			ActionURL:            actionURL,
+			SignonRealm:          realm,
+			TimesUsed:            timesUsed,
+			LastUsedDate:         chromiumTime(lastUsed),
+			PasswordModifiedDate: chromiumTime(modified),
+			Blacklisted:          blacklisted != 0,
+			FederationURL:        federationURL,
+			Note:                 notes[id],
+			Insecure:             strings.Join(insecure[id], "|"),
+			Store:                store,
 		}
 		if len(pwd) > 0 {
-			if len(masterKey) == 0 {
-				password, err = crypto.DecryptWithDPAPI(pwd)
-			} else {
-				password, err = crypto.DecryptWithChromium(masterKey, pwd)
-			}
+			login.EncryptScheme = crypto.EncryptScheme(pwd)
+			password, err = decryptChromium(keys, pwd)
 			if err != nil {
-				log.Errorf("decrypt chromium password error: %v", err)
+				login.DecryptFailed = true
+				log.Errorf("decrypt chromium password of %s with %s error: %v", url, login.EncryptScheme, err)
 			}
 		}
 		if create > time.Now().Unix() {
@@ -88,13 +189,107 @@ func (c *ChromiumPassword) Extract(masterKey []byte) error {
 			login.CreateDate = typeutil.TimeStamp(create)
 		}
 		login.Password = string(password)
-		*c = append(*c, login)
+		logins = append(logins, login)
 	}
-	// sort with create date
-	sort.Slice(*c, func(i, j int) bool {
-		return (*c)[i].CreateDate.After((*c)[j].CreateDate)
-	})
-	return nil
+	return logins, rows.Err()
+}
+
+// chromiumLoginQuery returns the query of the logins, with the default value of the missing columns.
+func chromiumLoginQuery(db *sql.DB) (string, error) {
+	rows, err := db.Query(queryChromiumLoginColumns)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return "", err
+		}
+		columns[name] = true
+	}
+	selects := make([]string, 0, len(chromiumLoginColumns))
+	for _, col := range chromiumLoginColumns {
+		if columns[col.name] {
+			selects = append(selects, fmt.Sprintf("COALESCE(%s, %s)", col.name, col.fallback))
+		} else {
+			selects = append(selects, col.fallback)
+		}
+	}
+	return fmt.Sprintf(queryChromiumLogin, strings.Join(selects, ", ")), rows.Err()
+}
+
+// chromiumPasswordNotes returns the decrypted notes of the logins by the id of the login,
+// password_notes is missing in the Login Data of old Chromium.
+func chromiumPasswordNotes(db *sql.DB, keys crypto.MasterKeys) map[int64]string {
+	notes := make(map[int64]string)
+	rows, err := db.Query(queryChromiumPasswordNotes)
+	if err != nil {
+		log.Debugf("query chromium password notes error: %v", err)
+		return notes
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id    int64
+			value []byte
+		)
+		if err := rows.Scan(&id, &value); err != nil {
+			log.Errorf("scan chromium password note error: %v", err)
+			continue
+		}
+		if len(value) == 0 {
+			continue
+		}
+		note, err := decryptChromium(keys, value)
+		if err != nil {
+			log.Errorf("decrypt chromium password note error: %v", err)
+			continue
+		}
+		notes[id] = string(note)
+	}
+	return notes
+}
+
+// chromiumInsecureCredentials returns the insecurity types of the logins by the id of the login.
+func chromiumInsecureCredentials(db *sql.DB) map[int64][]string {
+	insecure := make(map[int64][]string)
+	rows, err := db.Query(queryChromiumInsecureCredentials)
+	if err != nil {
+		log.Debugf("query chromium insecure credentials error: %v", err)
+		return insecure
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, insecurityType int64
+		if err := rows.Scan(&id, &insecurityType); err != nil {
+			log.Errorf("scan chromium insecure credential error: %v", err)
+			continue
+		}
+		name := fmt.Sprintf("unknown(%d)", insecurityType)
+		if insecurityType >= 0 && insecurityType < int64(len(chromiumInsecurityTypes)) {
+			name = chromiumInsecurityTypes[insecurityType]
+		}
+		insecure[id] = append(insecure[id], name)
+	}
+	return insecure
+}
+
+// decryptChromium decrypts the value with the master keys, or with DPAPI if no key is set.
+func decryptChromium(keys crypto.MasterKeys, value []byte) ([]byte, error) {
+	if keys.IsEmpty() {
+		return crypto.DecryptWithDPAPI(value)
+	}
+	return crypto.DecryptWithChromium(keys, value)
+}
+
+// chromiumTime converts the time of Login Data, which is zero if it's never set.
+func chromiumTime(t int64) time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+	return typeutil.TimeEpoch(t)
 }
 
 func (c *ChromiumPassword) Name() string {
@@ -105,20 +300,26 @@ func (c *ChromiumPassword) Len() int {
 	return len(*c)
 }
 
-type YandexPassword []loginData
+type YandexPassword []LoginData
 
 const (
-	queryYandexLogin = `SELECT action_url, username_value, password_value, date_created FROM logins`
+	queryYandexLogin = `SELECT origin_url, username_element, username_value, password_element, password_value, signon_realm, date_created FROM logins`
 )
 
-func (c *YandexPassword) Extract(masterKey []byte) error {
-	db, err := sql.Open("sqlite", types.YandexPassword.TempFilename())
+// Extract decrypts the passwords with the data key of the profile, which is sealed by the master key
+// in the meta table, each password is bound to its login by the additional data of AES-GCM.
+func (c *YandexPassword) Extract(keys crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(types.YandexPassword.TempFilename())
 	defer db.Close()
 
+	dataKey, err := crypto.YandexDataKey(db, keys)
+	if err != nil {
+		return err
+	}
+
 	rows, err := db.Query(queryYandexLogin)
 	if err != nil {
 		return err
@@ -127,27 +328,28 @@ func (c *YandexPassword) Extract(masterKey []byte) error {
 
 	for rows.Next() {
 		var (
-			url, username string
-			pwd, password []byte
-			create        int64
+			url, usernameElement, username string
+			passwordElement, signonRealm   string
+			pwd, password                  []byte
+			create                         int64
 		)
-		if err := rows.Scan(&url, &username, &pwd, &create); err != nil {
+		if err := rows.Scan(&url, &usernameElement, &username, &passwordElement, &pwd, &signonRealm, &create); err != nil {
 			log.Errorf("scan yandex password error: %v", err)
 		}
-		login := loginData{
+		login := LoginData{
 			UserName:    username,
 			encryptPass: pwd,
 			LoginURL:    url,
+			Store:       types.YandexPassword.Filename(),
 		}
 
 		if len(pwd) > 0 {
-			if len(masterKey) == 0 {
-				password, err = crypto.DecryptWithDPAPI(pwd)
-			} else {
-				password, err = crypto.DecryptWithChromium(masterKey, pwd)
-			}
+			login.EncryptScheme = crypto.SchemeYandex
+			aad := yandexPasswordAAD(url, usernameElement, username, passwordElement, signonRealm)
+			password, err = crypto.DecryptWithYandex(dataKey, pwd, aad)
 			if err != nil {
-				log.Errorf("decrypt yandex password error: %v", err)
+				login.DecryptFailed = true
+				log.Errorf("decrypt yandex password of %s error: %v", url, err)
 			}
 		}
 		if create > time.Now().Unix() {
@@ -165,6 +367,12 @@ func (c *YandexPassword) Extract(masterKey []byte) error {
 	return nil
 }
 
+// yandexPasswordAAD returns the additional data of the password, the SHA1 of the login fields joined by NUL.
+func yandexPasswordAAD(originURL, usernameElement, usernameValue, passwordElement, signonRealm string) []byte {
+	sum := sha1.Sum([]byte(strings.Join([]string{originURL, usernameElement, usernameValue, passwordElement, signonRealm}, "\x00")))
+	return sum[:]
+}
+
 func (c *YandexPassword) Name() string {
 	return "password"
 }
@@ -173,57 +381,65 @@ func (c *YandexPassword) Len() int {
 	return len(*c)
 }
 
-type FirefoxPassword []loginData
+type FirefoxPassword []LoginData
 
-func (f *FirefoxPassword) Extract(globalSalt []byte) error {
-	logins, err := getFirefoxLoginData()
+// Extract decrypts the logins with the NSS key of key4.db, it's the V10 of keys.
+func (f *FirefoxPassword) Extract(keys crypto.MasterKeys, path string) error {
+	logins, err := getFirefoxLoginData(path)
 	if err != nil {
 		return err
 	}
 
+	*f, err = decryptFirefoxLogins(keys.V10, logins)
+	return err
+}
+
+// decryptFirefoxLogins decrypts the username and password of the logins, sorted by create date
+func decryptFirefoxLogins(globalSalt []byte, logins []LoginData) ([]LoginData, error) {
+	decrypted := make([]LoginData, 0, len(logins))
 	for _, v := range logins {
 		userPBE, err := crypto.NewASN1PBE(v.encryptUser)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		pwdPBE, err := crypto.NewASN1PBE(v.encryptPass)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		user, err := userPBE.Decrypt(globalSalt)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		pwd, err := pwdPBE.Decrypt(globalSalt)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		*f = append(*f, loginData{
+		decrypted = append(decrypted, LoginData{
 			LoginURL:   v.LoginURL,
 			UserName:   string(user),
 			Password:   string(pwd),
 			CreateDate: v.CreateDate,
+			Store:      v.Store,
 		})
 	}
 
-	sort.Slice(*f, func(i, j int) bool {
-		return (*f)[i].CreateDate.After((*f)[j].CreateDate)
+	sort.Slice(decrypted, func(i, j int) bool {
+		return decrypted[i].CreateDate.After(decrypted[j].CreateDate)
 	})
-	return nil
+	return decrypted, nil
 }
 
-func getFirefoxLoginData() ([]loginData, error) {
-	s, err := os.ReadFile(types.FirefoxPassword.TempFilename())
+func getFirefoxLoginData(path string) ([]LoginData, error) {
+	s, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer os.Remove(types.FirefoxPassword.TempFilename())
 	loginsJSON := gjson.GetBytes(s, "logins")
-	var logins []loginData
+	var logins []LoginData
 	if loginsJSON.Exists() {
 		for _, v := range loginsJSON.Array() {
 			var (
-				m    loginData
+				m    LoginData
 				user []byte
 				pass []byte
 			)
@@ -239,6 +455,7 @@ func getFirefoxLoginData() ([]loginData, error) {
 			m.encryptUser = user
 			m.encryptPass = pass
 			m.CreateDate = typeutil.TimeStamp(v.Get("timeCreated").Int() / 1000)
+			m.Store = types.FirefoxPassword.Filename()
 			logins = append(logins, m)
 		}
 	}
@@ -252,3 +469,66 @@ func (f *FirefoxPassword) Name() string {
 func (f *FirefoxPassword) Len() int {
 	return len(*f)
 }
+
+// FirefoxLegacyPassword is the logins of signons.sqlite, used before Firefox 32
+// and still by other NSS based browsers, eg: SeaMonkey and Thunderbird
+type FirefoxLegacyPassword []LoginData
+
+const (
+	queryFirefoxLegacyLogin = `SELECT COALESCE(NULLIF(formSubmitURL, ''), hostname), encryptedUsername, encryptedPassword, timeCreated FROM moz_logins`
+)
+
+// Extract decrypts the logins with the NSS key of key3.db, it's the V10 of keys.
+func (f *FirefoxLegacyPassword) Extract(keys crypto.MasterKeys, path string) error {
+	logins, err := getFirefoxLegacyLoginData(path)
+	if err != nil {
+		return err
+	}
+
+	*f, err = decryptFirefoxLogins(keys.V10, logins)
+	return err
+}
+
+func getFirefoxLegacyLoginData(path string) ([]LoginData, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(queryFirefoxLegacyLogin)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logins []LoginData
+	for rows.Next() {
+		var (
+			url, user, pass string
+			create          int64
+		)
+		if err := rows.Scan(&url, &user, &pass, &create); err != nil {
+			log.Errorf("scan firefox legacy password error: %v", err)
+			continue
+		}
+		m := LoginData{LoginURL: url, Store: types.FirefoxLegacyPassword.Filename()}
+		if m.encryptUser, err = base64.StdEncoding.DecodeString(user); err != nil {
+			return nil, err
+		}
+		if m.encryptPass, err = base64.StdEncoding.DecodeString(pass); err != nil {
+			return nil, err
+		}
+		m.CreateDate = typeutil.TimeStamp(create / 1000)
+		logins = append(logins, m)
+	}
+	return logins, rows.Err()
+}
+
+func (f *FirefoxLegacyPassword) Name() string {
+	return "password"
+}
+
+func (f *FirefoxLegacyPassword) Len() int {
+	return len(*f)
+}
@@ -0,0 +1,183 @@
+package password
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/internal/testutil"
+	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
+)
+
+func sealAESGCM(t *testing.T, key, plaintext, additionalData []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := bytes.Repeat([]byte{'n'}, gcm.NonceSize())
+	return gcm.Seal(nonce, nonce, plaintext, additionalData)
+}
+
+// newYandexLoginDB creates Ya Passman Data with the data key sealed by the 32 bytes master key of Windows.
+func newYandexLoginDB(t *testing.T, masterKey, dataKey []byte, sealedKey string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Ya Passman Data")
+	sealed := sealAESGCM(t, masterKey, append([]byte{0x08, 0x01, 0x12, 0x20}, append(dataKey, make([]byte, 32)...)...), nil)
+	localEncryptorData := append([]byte("\x0a\x63"+crypto.SchemeV10), sealed...)
+	password := sealAESGCM(t, dataKey, []byte("moond4rk"),
+		yandexPasswordAAD("https://passport.yandex.ru/", "login", "user", "passwd", "https://passport.yandex.ru/"))
+	testutil.NewSQLite(t, path,
+		`CREATE TABLE meta (key TEXT PRIMARY KEY, value BLOB)`,
+		`CREATE TABLE active_keys (key_id TEXT, sealed_key TEXT)`,
+		`CREATE TABLE logins (origin_url TEXT, username_element TEXT, username_value TEXT, password_element TEXT,
+			password_value BLOB, signon_realm TEXT, date_created INTEGER)`,
+	)
+	testutil.ExecSQLite(t, path, `INSERT INTO meta VALUES ('local_encryptor_data', ?)`, localEncryptorData)
+	if sealedKey != "" {
+		testutil.ExecSQLite(t, path, `INSERT INTO active_keys VALUES ('key', ?)`, sealedKey)
+	}
+	testutil.ExecSQLite(t, path, `INSERT INTO logins VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		"https://passport.yandex.ru/", "login", "user", "passwd", password, "https://passport.yandex.ru/", 13350000000000000)
+	return path
+}
+
+func TestYandexPassword_Extract(t *testing.T) {
+	masterKey := bytes.Repeat([]byte{'m'}, 32)
+	dataKey := bytes.Repeat([]byte{'d'}, 32)
+
+	var passwords YandexPassword
+	require.NoError(t, passwords.Extract(crypto.MasterKeys{V10: masterKey}, newYandexLoginDB(t, masterKey, dataKey, "")))
+	require.Len(t, passwords, 1)
+	assert.Equal(t, "user", passwords[0].UserName)
+	assert.Equal(t, "moond4rk", passwords[0].Password)
+	assert.Equal(t, crypto.SchemeYandex, passwords[0].EncryptScheme)
+	assert.False(t, passwords[0].DecryptFailed)
+
+	passwords = nil
+	err := passwords.Extract(crypto.MasterKeys{V10: masterKey}, newYandexLoginDB(t, masterKey, dataKey, `{"encrypted_private_key":"..."}`))
+	assert.ErrorIs(t, err, crypto.ErrYandexMasterPassword)
+
+	passwords = nil
+	err = passwords.Extract(crypto.MasterKeys{V10: bytes.Repeat([]byte{'w'}, 32)}, newYandexLoginDB(t, masterKey, dataKey, ""))
+	assert.ErrorIs(t, err, crypto.ErrYandexDataKeyInvalid)
+}
+
+// newChromiumLoginDB creates Login Data of the schema with a login and its note, the values are
+// encrypted with the 32 bytes master key of Windows.
+func newChromiumLoginDB(t *testing.T, masterKey []byte, stmts ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Login Data")
+	testutil.NewSQLite(t, path, stmts...)
+	encrypt := func(plaintext string) []byte {
+		return append([]byte(crypto.SchemeV10), sealAESGCM(t, masterKey, []byte(plaintext), nil)...)
+	}
+	testutil.ExecSQLite(t, path, `INSERT INTO logins (origin_url, username_value, password_value, date_created) VALUES (?, ?, ?, ?)`,
+		"https://github.com/login", "user", encrypt("moond4rk"), 13350000000000000)
+	if strings.Contains(strings.Join(stmts, "\n"), "CREATE TABLE password_notes") {
+		testutil.ExecSQLite(t, path, `INSERT INTO password_notes (parent_id, key, value, date_created) VALUES (1, '', ?, 0)`,
+			encrypt("recovery codes in the safe"))
+	}
+	return path
+}
+
+func TestChromiumPassword_Extract(t *testing.T) {
+	masterKey := bytes.Repeat([]byte{'m'}, 32)
+	path := newChromiumLoginDB(t, masterKey,
+		`CREATE TABLE logins (origin_url VARCHAR NOT NULL, action_url VARCHAR, username_element VARCHAR, username_value VARCHAR,
+			password_element VARCHAR, password_value BLOB, submit_element VARCHAR, signon_realm VARCHAR NOT NULL DEFAULT '',
+			date_created INTEGER, blacklisted_by_user INTEGER NOT NULL DEFAULT 0, scheme INTEGER, password_type INTEGER,
+			times_used INTEGER DEFAULT 0, form_data BLOB, display_name VARCHAR, icon_url VARCHAR, federation_url VARCHAR,
+			skip_zero_click INTEGER, generation_upload_status INTEGER, possible_username_pairs BLOB,
+			id INTEGER PRIMARY KEY AUTOINCREMENT, date_last_used INTEGER NOT NULL DEFAULT 0, moving_blocked_for BLOB,
+			date_password_modified INTEGER NOT NULL DEFAULT 0)`,
+		`CREATE TABLE password_notes (id INTEGER PRIMARY KEY AUTOINCREMENT, parent_id INTEGER NOT NULL REFERENCES logins ON UPDATE CASCADE
+			ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED, key VARCHAR NOT NULL, value BLOB, date_created INTEGER NOT NULL,
+			confidential INTEGER, UNIQUE (parent_id, key))`,
+		`CREATE TABLE insecure_credentials (parent_id INTEGER REFERENCES logins ON UPDATE CASCADE ON DELETE CASCADE
+			DEFERRABLE INITIALLY DEFERRED, insecurity_type INTEGER NOT NULL, create_time INTEGER NOT NULL,
+			is_muted INTEGER NOT NULL DEFAULT 0, trigger_notification_from_backend INTEGER NOT NULL DEFAULT 0,
+			UNIQUE (parent_id, insecurity_type))`,
+	)
+	testutil.NewSQLite(t, path,
+		`UPDATE logins SET action_url = 'https://github.com/session', signon_realm = 'https://github.com/',
+			times_used = 7, date_last_used = 13350000100000000, date_password_modified = 13350000050000000 WHERE id = 1`,
+		`INSERT INTO insecure_credentials VALUES (1, 3, 13350000000000000, 0, 0), (1, 0, 13350000000000000, 0, 0)`,
+		`INSERT INTO logins (origin_url, username_value, password_value, signon_realm, date_created, blacklisted_by_user, federation_url)
+			VALUES ('https://example.org/', '', X'', 'https://example.org/', 13340000000000000, 1, 'https://accounts.google.com')`,
+	)
+
+	var passwords ChromiumPassword
+	require.NoError(t, passwords.Extract(crypto.MasterKeys{V10: masterKey}, filepath.Dir(path)))
+	require.Len(t, passwords, 2)
+
+	login := passwords[0]
+	assert.Equal(t, "user", login.UserName)
+	assert.Equal(t, "moond4rk", login.Password)
+	assert.Equal(t, "https://github.com/session", login.ActionURL)
+	assert.Equal(t, "https://github.com/", login.SignonRealm)
+	assert.Equal(t, int64(7), login.TimesUsed)
+	assert.Equal(t, typeutil.TimeEpoch(13350000100000000), login.LastUsedDate)
+	assert.Equal(t, typeutil.TimeEpoch(13350000050000000), login.PasswordModifiedDate)
+	assert.Equal(t, "recovery codes in the safe", login.Note)
+	assert.Equal(t, "leaked|reused", login.Insecure)
+	assert.False(t, login.Blacklisted)
+
+	blocked := passwords[1]
+	assert.True(t, blocked.Blacklisted)
+	assert.Empty(t, blocked.Password)
+	assert.Equal(t, "https://accounts.google.com", blocked.FederationURL)
+	assert.True(t, blocked.LastUsedDate.IsZero())
+}
+
+func TestChromiumPassword_ExtractOldSchema(t *testing.T) {
+	// the Login Data of old Chromium has no id, dates of use, notes or insecure credentials
+	masterKey := bytes.Repeat([]byte{'m'}, 32)
+	path := newChromiumLoginDB(t, masterKey,
+		`CREATE TABLE logins (origin_url VARCHAR NOT NULL, action_url VARCHAR, username_element VARCHAR, username_value VARCHAR,
+			password_element VARCHAR, password_value BLOB, submit_element VARCHAR, signon_realm VARCHAR NOT NULL DEFAULT '',
+			ssl_valid INTEGER, preferred INTEGER, date_created INTEGER, blacklisted_by_user INTEGER NOT NULL DEFAULT 0,
+			scheme INTEGER, UNIQUE (origin_url, username_element, username_value, password_element, submit_element, signon_realm))`,
+	)
+	var passwords ChromiumPassword
+	require.NoError(t, passwords.Extract(crypto.MasterKeys{V10: masterKey}, filepath.Dir(path)))
+	require.Len(t, passwords, 1)
+	assert.Equal(t, "moond4rk", passwords[0].Password)
+	assert.Zero(t, passwords[0].TimesUsed)
+	assert.Empty(t, passwords[0].Note)
+	assert.Empty(t, passwords[0].Insecure)
+}
+
+func TestChromiumPassword_ExtractStores(t *testing.T) {
+	masterKey := bytes.Repeat([]byte{'m'}, 32)
+	const schema = `CREATE TABLE logins (origin_url VARCHAR NOT NULL, username_value VARCHAR, password_value BLOB,
+		date_created INTEGER, id INTEGER PRIMARY KEY AUTOINCREMENT)`
+	dir := t.TempDir()
+	for _, store := range []string{"Login Data", "Login Data For Account"} {
+		data, err := os.ReadFile(newChromiumLoginDB(t, masterKey, schema))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, store), data, 0o600))
+	}
+
+	var passwords ChromiumPassword
+	require.NoError(t, passwords.Extract(crypto.MasterKeys{V10: masterKey}, dir))
+	require.Len(t, passwords, 2)
+	assert.Equal(t, "Login Data", passwords[0].Store)
+	assert.Equal(t, "Login Data For Account", passwords[1].Store)
+	for _, login := range passwords {
+		assert.Equal(t, "moond4rk", login.Password)
+	}
+
+	// the dir without any store keeps no logins
+	passwords = nil
+	require.NoError(t, passwords.Extract(crypto.MasterKeys{V10: masterKey}, t.TempDir()))
+	assert.Empty(t, passwords)
+}
@@ -0,0 +1,283 @@
+package search
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+	// import sqlite3 driver
+	_ "modernc.org/sqlite"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/extractor"
+	"github.com/moond4rk/hackbrowserdata/log"
+	"github.com/moond4rk/hackbrowserdata/types"
+	"github.com/moond4rk/hackbrowserdata/utils/lz4util"
+	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
+)
+
+func init() {
+	extractor.RegisterExtractor(types.ChromiumSearchEngine, func() extractor.Extractor {
+		return new(ChromiumSearchEngine)
+	})
+	extractor.RegisterExtractor(types.ChromiumSearchTerm, func() extractor.Extractor {
+		return new(ChromiumSearchTerm)
+	})
+	extractor.RegisterExtractor(types.FirefoxSearchEngine, func() extractor.Extractor {
+		return new(FirefoxSearchEngine)
+	})
+}
+
+// Engine is a configured search engine, URL and SuggestURL are the templates of the search and
+// suggestion requests, eg: https://www.google.com/search?q={searchTerms}
+type Engine struct {
+	GUID         string
+	Name         string
+	Keyword      string
+	URL          string
+	SuggestURL   string
+	IsDefault    bool
+	UsageCount   int64
+	CreateDate   time.Time
+	LastUsedDate time.Time
+}
+
+// Term is a term searched with a search engine, EngineID is the id of the engine in the keywords
+// table of Web Data, and URL is the page of the search results. LastVisitTime is the last visit of
+// the results page, the time the term was first searched isn't kept by Chromium.
+type Term struct {
+	Term          string
+	URL           string
+	Title         string
+	EngineID      int64
+	LastVisitTime time.Time
+}
+
+type ChromiumSearchEngine []Engine
+
+const (
+	queryChromiumEngine = `SELECT id, COALESCE(sync_guid, ''), short_name, keyword, url, COALESCE(suggest_url, ''),
+		usage_count, date_created, %s FROM keywords`
+	queryChromiumEngineLastVisited = `SELECT name FROM pragma_table_info('keywords') WHERE name = 'last_visited'`
+	queryChromiumDefaultEngineID   = `SELECT value FROM meta WHERE key = 'Default Search Provider ID'`
+)
+
+// Extract reads the search engines of the keywords table, the default engine is the guid of
+// default_search_provider in Preferences, or the id kept in the meta table of old Chromium.
+func (c *ChromiumSearchEngine) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// last_visited is missing in the keywords of old Chromium
+	lastVisited := "0"
+	var column string
+	if err := db.QueryRow(queryChromiumEngineLastVisited).Scan(&column); err == nil {
+		lastVisited = "COALESCE(last_visited, 0)"
+	}
+	rows, err := db.Query(fmt.Sprintf(queryChromiumEngine, lastVisited))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	defaultGUID := chromiumDefaultEngineGUID(filepath.Join(filepath.Dir(path), types.FileChromiumPreferences))
+	var defaultID string
+	if defaultGUID == "" {
+		_ = db.QueryRow(queryChromiumDefaultEngineID).Scan(&defaultID)
+	}
+	for rows.Next() {
+		var (
+			id, usageCount, created, lastUsed int64
+			guid, name, keyword, url, suggest string
+		)
+		if err := rows.Scan(&id, &guid, &name, &keyword, &url, &suggest, &usageCount, &created, &lastUsed); err != nil {
+			log.Warnf("scan chromium search engine error: %v", err)
+			continue
+		}
+		e := Engine{
+			GUID:       guid,
+			Name:       name,
+			Keyword:    keyword,
+			URL:        url,
+			SuggestURL: suggest,
+			IsDefault:  (defaultGUID != "" && guid == defaultGUID) || (defaultID != "" && strconv.FormatInt(id, 10) == defaultID),
+			UsageCount: usageCount,
+		}
+		// date_created is the seconds since 1970, and last_visited is the microseconds since 1601
+		if created != 0 {
+			e.CreateDate = typeutil.TimeStamp(created)
+		}
+		if lastUsed != 0 {
+			e.LastUsedDate = typeutil.TimeEpoch(lastUsed)
+		}
+		*c = append(*c, e)
+	}
+	sortEngines(*c)
+	return rows.Err()
+}
+
+func (c *ChromiumSearchEngine) Name() string {
+	return "searchEngine"
+}
+
+func (c *ChromiumSearchEngine) Len() int {
+	return len(*c)
+}
+
+// chromiumDefaultEngineGUID returns the guid of the default search engine in Preferences, the engine
+// set by a policy or an extension is kept in default_search_provider_data.
+func chromiumDefaultEngineGUID(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		log.Debugf("read chromium preferences error: %v", err)
+		return ""
+	}
+	if guid := gjson.GetBytes(b, "default_search_provider.guid").String(); guid != "" {
+		return guid
+	}
+	return gjson.GetBytes(b, "default_search_provider_data.template_url_data.synced_guid").String()
+}
+
+type ChromiumSearchTerm []Term
+
+const (
+	queryChromiumSearchTerm = `SELECT keyword_search_terms.term, urls.url, COALESCE(urls.title, ''),
+		keyword_search_terms.keyword_id, urls.last_visit_time
+		FROM keyword_search_terms JOIN urls ON keyword_search_terms.url_id = urls.id`
+)
+
+func (c *ChromiumSearchTerm) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(queryChromiumSearchTerm)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			term, url, title        string
+			engineID, lastVisitTime int64
+		)
+		if err := rows.Scan(&term, &url, &title, &engineID, &lastVisitTime); err != nil {
+			log.Warnf("scan chromium search term error: %v", err)
+			continue
+		}
+		*c = append(*c, Term{
+			Term:          term,
+			URL:           url,
+			Title:         title,
+			EngineID:      engineID,
+			LastVisitTime: typeutil.TimeEpoch(lastVisitTime),
+		})
+	}
+	sort.SliceStable(*c, func(i, j int) bool {
+		return (*c)[i].LastVisitTime.After((*c)[j].LastVisitTime)
+	})
+	return rows.Err()
+}
+
+func (c *ChromiumSearchTerm) Name() string {
+	return "searchTerm"
+}
+
+func (c *ChromiumSearchTerm) Len() int {
+	return len(*c)
+}
+
+type FirefoxSearchEngine []Engine
+
+// the types of the urls of the Firefox search engine
+const (
+	firefoxSearchURLType  = "text/html"
+	firefoxSuggestURLType = "application/x-suggestions+json"
+)
+
+// Extract reads the engines of search.json.mozlz4, the default engine is the defaultEngineId of
+// the metadata, or the name in current of old Firefox, the engines removed by the user are hidden
+// and skipped.
+// @https://searchfox.org/mozilla-central/source/toolkit/components/search/SearchSettings.sys.mjs
+func (f *FirefoxSearchEngine) Extract(_ crypto.MasterKeys, path string) error {
+	b, err := lz4util.ReadMozLz4(path)
+	if err != nil {
+		return err
+	}
+	settings := gjson.ParseBytes(b)
+	defaultID := settings.Get("metaData.defaultEngineId").String()
+	defaultName := settings.Get("metaData.current").String()
+	for _, v := range settings.Get("engines").Array() {
+		if v.Get("_metaData.hidden").Bool() {
+			continue
+		}
+		id, name := v.Get("id").String(), v.Get("_name").String()
+		keyword := v.Get("_metaData.alias").String()
+		if keyword == "" {
+			keyword = v.Get("_definedAliases.0").String()
+		}
+		*f = append(*f, Engine{
+			GUID:       id,
+			Name:       name,
+			Keyword:    keyword,
+			URL:        firefoxEngineURL(v.Get("_urls").Array(), firefoxSearchURLType),
+			SuggestURL: firefoxEngineURL(v.Get("_urls").Array(), firefoxSuggestURLType),
+			IsDefault:  (defaultID != "" && id == defaultID) || (defaultID == "" && defaultName != "" && name == defaultName),
+		})
+	}
+	sortEngines(*f)
+	return nil
+}
+
+func (f *FirefoxSearchEngine) Name() string {
+	return "searchEngine"
+}
+
+func (f *FirefoxSearchEngine) Len() int {
+	return len(*f)
+}
+
+// firefoxEngineURL returns the template of the url of the type with its params as the query,
+// the url without a type is the search url, the built-in engines of new Firefox may keep no urls.
+func firefoxEngineURL(urls []gjson.Result, urlType string) string {
+	for _, u := range urls {
+		t := u.Get("type").String()
+		if t == "" {
+			t = firefoxSearchURLType
+		}
+		if t != urlType {
+			continue
+		}
+		var params []string
+		for _, p := range u.Get("params").Array() {
+			params = append(params, p.Get("name").String()+"="+p.Get("value").String())
+		}
+		template := u.Get("template").String()
+		if len(params) == 0 {
+			return template
+		}
+		sep := "?"
+		if strings.Contains(template, "?") {
+			sep = "&"
+		}
+		return template + sep + strings.Join(params, "&")
+	}
+	return ""
+}
+
+// sortEngines puts the default engine first and keeps the order of the others.
+func sortEngines(engines []Engine) {
+	sort.SliceStable(engines, func(i, j int) bool {
+		return engines[i].IsDefault && !engines[j].IsDefault
+	})
+}
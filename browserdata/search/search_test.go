@@ -0,0 +1,125 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/internal/testutil"
+	"github.com/moond4rk/hackbrowserdata/types"
+	"github.com/moond4rk/hackbrowserdata/utils/lz4util"
+	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
+)
+
+func TestChromiumSearchEngine_Extract(t *testing.T) {
+	dir := t.TempDir()
+	webData := filepath.Join(dir, types.FileChromiumWebData)
+	testutil.NewSQLite(t, webData,
+		`CREATE TABLE keywords (id INTEGER PRIMARY KEY, short_name VARCHAR NOT NULL, keyword VARCHAR NOT NULL,
+			favicon_url VARCHAR NOT NULL, url VARCHAR NOT NULL, date_created INTEGER DEFAULT 0, usage_count INTEGER DEFAULT 0,
+			suggest_url VARCHAR, sync_guid VARCHAR, last_visited INTEGER DEFAULT 0)`,
+		`INSERT INTO keywords VALUES (2, 'Google', 'google.com', '', '{google:baseURL}search?q={searchTerms}',
+			1700000000, 0, '{google:baseSuggestURL}search?q={searchTerms}', 'guid-google', 0)`,
+		`INSERT INTO keywords VALUES (3, 'DuckDuckGo', 'duckduckgo.com', '', 'https://duckduckgo.com/?q={searchTerms}',
+			1700000000, 4, NULL, 'guid-ddg', 13300000500000000)`,
+	)
+	preferences := `{"default_search_provider":{"guid":"guid-ddg"}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, types.FileChromiumPreferences), []byte(preferences), 0o600))
+
+	var c ChromiumSearchEngine
+	require.NoError(t, c.Extract(crypto.MasterKeys{}, webData))
+	assert.Equal(t, ChromiumSearchEngine{
+		{
+			GUID: "guid-ddg", Name: "DuckDuckGo", Keyword: "duckduckgo.com", URL: "https://duckduckgo.com/?q={searchTerms}",
+			IsDefault: true, UsageCount: 4, CreateDate: typeutil.TimeStamp(1700000000),
+			LastUsedDate: typeutil.TimeEpoch(13300000500000000),
+		},
+		{
+			GUID: "guid-google", Name: "Google", Keyword: "google.com", URL: "{google:baseURL}search?q={searchTerms}",
+			SuggestURL: "{google:baseSuggestURL}search?q={searchTerms}", CreateDate: typeutil.TimeStamp(1700000000),
+		},
+	}, c)
+}
+
+func TestChromiumSearchEngine_ExtractLegacyDefault(t *testing.T) {
+	// the old Web Data keeps the id of the default engine in meta, and keywords has no last_visited
+	dir := t.TempDir()
+	webData := filepath.Join(dir, types.FileChromiumWebData)
+	testutil.NewSQLite(t, webData,
+		`CREATE TABLE meta (key LONGVARCHAR NOT NULL UNIQUE PRIMARY KEY, value LONGVARCHAR)`,
+		`INSERT INTO meta VALUES ('Default Search Provider ID', '3')`,
+		`CREATE TABLE keywords (id INTEGER PRIMARY KEY, short_name VARCHAR NOT NULL, keyword VARCHAR NOT NULL,
+			url VARCHAR NOT NULL, date_created INTEGER DEFAULT 0, usage_count INTEGER DEFAULT 0, suggest_url VARCHAR,
+			sync_guid VARCHAR)`,
+		`INSERT INTO keywords VALUES (2, 'Google', 'google.com', 'https://www.google.com/search?q={searchTerms}', 0, 0, NULL, NULL)`,
+		`INSERT INTO keywords VALUES (3, 'Bing', 'bing.com', 'https://www.bing.com/search?q={searchTerms}', 0, 0, NULL, NULL)`,
+	)
+	var c ChromiumSearchEngine
+	require.NoError(t, c.Extract(crypto.MasterKeys{}, webData))
+	require.Len(t, c, 2)
+	assert.Equal(t, "Bing", c[0].Name)
+	assert.True(t, c[0].IsDefault)
+	assert.False(t, c[1].IsDefault)
+	assert.True(t, c[1].LastUsedDate.IsZero())
+}
+
+func TestChromiumSearchTerm_Extract(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "History")
+	testutil.NewSQLite(t, path,
+		`CREATE TABLE urls (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR, visit_count INTEGER, last_visit_time INTEGER)`,
+		`CREATE TABLE keyword_search_terms (keyword_id INTEGER NOT NULL, url_id INTEGER NOT NULL, term LONGVARCHAR NOT NULL,
+			normalized_term LONGVARCHAR NOT NULL)`,
+		`INSERT INTO urls VALUES (1, 'https://www.google.com/search?q=golang', 'golang - Google Search', 1, 13300000000000000)`,
+		`INSERT INTO urls VALUES (2, 'https://duckduckgo.com/?q=sqlite', NULL, 1, 13300000100000000)`,
+		`INSERT INTO keyword_search_terms VALUES (2, 1, 'golang', 'golang')`,
+		`INSERT INTO keyword_search_terms VALUES (3, 2, 'SQLite', 'sqlite')`,
+	)
+	var c ChromiumSearchTerm
+	require.NoError(t, c.Extract(crypto.MasterKeys{}, path))
+	assert.Equal(t, ChromiumSearchTerm{
+		{Term: "SQLite", URL: "https://duckduckgo.com/?q=sqlite", EngineID: 3, LastVisitTime: typeutil.TimeEpoch(13300000100000000)},
+		{
+			Term: "golang", URL: "https://www.google.com/search?q=golang", Title: "golang - Google Search", EngineID: 2,
+			LastVisitTime: typeutil.TimeEpoch(13300000000000000),
+		},
+	}, c)
+}
+
+const testSearchSettings = `{"version":9,"engines":[
+	{"id":"google@search.mozilla.orgdefault","_name":"Google","_isAppProvided":true,"_metaData":{"order":1}},
+	{"id":"a1b2","_name":"Startpage","_isAppProvided":false,"_metaData":{"alias":"sp","order":2},
+	 "_urls":[{"template":"https://www.startpage.com/sp/search","rels":[],"params":[{"name":"query","value":"{searchTerms}"}]},
+	  {"template":"https://www.startpage.com/suggestions?q={searchTerms}","type":"application/x-suggestions+json","rels":[],"params":[]}]},
+	{"id":"c3d4","_name":"Removed","_metaData":{"hidden":true},"_urls":[{"template":"https://example.org/?q={searchTerms}"}]}
+],"metaData":{"useSavedOrder":false,"defaultEngineId":"a1b2"}}`
+
+func TestFirefoxSearchEngine_Extract(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search.json.mozlz4")
+	require.NoError(t, os.WriteFile(path, lz4util.EncodeMozLz4([]byte(testSearchSettings)), 0o600))
+
+	var f FirefoxSearchEngine
+	require.NoError(t, f.Extract(crypto.MasterKeys{}, path))
+	assert.Equal(t, FirefoxSearchEngine{
+		{
+			GUID: "a1b2", Name: "Startpage", Keyword: "sp", URL: "https://www.startpage.com/sp/search?query={searchTerms}",
+			SuggestURL: "https://www.startpage.com/suggestions?q={searchTerms}", IsDefault: true,
+		},
+		{GUID: "google@search.mozilla.orgdefault", Name: "Google"},
+	}, f)
+}
+
+func TestFirefoxSearchEngine_ExtractCurrent(t *testing.T) {
+	// the old search.json.mozlz4 keeps the name of the default engine in current
+	settings := `{"version":1,"engines":[{"_name":"Google"},{"_name":"Bing","_definedAliases":["@bing"]}],"metaData":{"current":"Bing"}}`
+	path := filepath.Join(t.TempDir(), "search.json.mozlz4")
+	require.NoError(t, os.WriteFile(path, lz4util.EncodeMozLz4([]byte(settings)), 0o600))
+
+	var f FirefoxSearchEngine
+	require.NoError(t, f.Extract(crypto.MasterKeys{}, path))
+	require.Len(t, f, 2)
+	assert.Equal(t, Engine{Name: "Bing", Keyword: "@bing", IsDefault: true}, f[0])
+}
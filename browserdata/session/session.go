@@ -0,0 +1,200 @@
+package session
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/extractor"
+	"github.com/moond4rk/hackbrowserdata/log"
+	"github.com/moond4rk/hackbrowserdata/types"
+	"github.com/moond4rk/hackbrowserdata/utils/lz4util"
+)
+
+func init() {
+	extractor.RegisterExtractor(types.ChromiumSession, func() extractor.Extractor {
+		return new(ChromiumSession)
+	})
+	extractor.RegisterExtractor(types.FirefoxSession, func() extractor.Extractor {
+		return new(FirefoxSession)
+	})
+}
+
+// Entry is a navigation entry of a tab in the session, the open and closed tabs are flattened
+// into their entries, numbered by the window and the tab, Current marks the entry shown in the tab.
+type Entry struct {
+	// Source is the session file of Chromium the entry is read from, eg: Session_13350000000000000, Tabs_13350000000000000
+	Source   string
+	Window   int
+	Tab      int
+	Index    int
+	Current  bool
+	Closed   bool
+	URL      string
+	Title    string
+	Referrer string
+	FormData string
+	// Timestamp is the time the entry was visited, Firefox only keeps the last access time of the tab
+	Timestamp time.Time
+	ClosedAt  time.Time
+}
+
+type ChromiumSession []Entry
+
+const (
+	sessionFilePrefix = "Session_"
+	tabsFilePrefix    = "Tabs_"
+)
+
+// Extract replays the SNSS files of the Sessions dir, the Session_ files keep the windows of the current
+// and the last sessions, the Tabs_ files keep the recently closed tabs and windows, the files are named
+// by the time they are created, eg: Session_13350000000000000. The Current Session and Current Tabs
+// files of Chromium before 86 are not read.
+func (c *ChromiumSession) Extract(_ crypto.MasterKeys, path string) error {
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := f.Name()
+		isSession, isTabs := strings.HasPrefix(name, sessionFilePrefix), strings.HasPrefix(name, tabsFilePrefix)
+		if f.IsDir() || !isSession && !isTabs {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(path, name))
+		if err != nil {
+			log.Errorf("read chromium session %s error: %v", name, err)
+			continue
+		}
+		commands, err := readSNSS(b)
+		if err != nil {
+			log.Errorf("parse chromium session %s error: %v", name, err)
+			continue
+		}
+		s := newSNSSSession()
+		if isSession {
+			s.replaySession(commands)
+		} else {
+			s.replayTabRestore(commands)
+		}
+		*c = append(*c, s.entries(name)...)
+	}
+	return nil
+}
+
+func (c *ChromiumSession) Name() string {
+	return "session"
+}
+
+func (c *ChromiumSession) Len() int {
+	return len(*c)
+}
+
+type FirefoxSession []Entry
+
+// Extract reads the windows of sessionstore.jsonlz4, or of sessionstore-backups/recovery.jsonlz4 while
+// Firefox is running, the closed windows and tabs are numbered after the open ones.
+// eg: {"windows":[{"tabs":[{"entries":[{"url":"...","title":"..."}],"index":1,"lastAccessed":1700000000000}],
+// "_closedTabs":[{"state":{...},"closedAt":1700000000000}]}],"_closedWindows":[...]}
+// @https://searchfox.org/mozilla-central/source/browser/components/sessionstore/SessionStore.sys.mjs
+func (f *FirefoxSession) Extract(_ crypto.MasterKeys, path string) error {
+	b, err := lz4util.ReadMozLz4(path)
+	if err != nil {
+		return err
+	}
+	state := gjson.ParseBytes(b)
+	windows := state.Get("windows").Array()
+	for i, window := range windows {
+		f.addWindow(i+1, window, false)
+	}
+	for i, window := range state.Get("_closedWindows").Array() {
+		f.addWindow(len(windows)+i+1, window, true)
+	}
+	return nil
+}
+
+func (f *FirefoxSession) addWindow(window int, w gjson.Result, closed bool) {
+	closedAt := w.Get("closedAt").Int()
+	tabs := w.Get("tabs").Array()
+	for i, tab := range tabs {
+		f.addTab(window, i+1, tab, closed, closedAt)
+	}
+	for i, tab := range w.Get("_closedTabs").Array() {
+		f.addTab(window, len(tabs)+i+1, tab.Get("state"), true, tab.Get("closedAt").Int())
+	}
+}
+
+func (f *FirefoxSession) addTab(window, tab int, t gjson.Result, closed bool, closedAt int64) {
+	// index is the 1-based index of the current entry
+	current := int(t.Get("index").Int())
+	for i, e := range t.Get("entries").Array() {
+		entry := Entry{
+			Window:   window,
+			Tab:      tab,
+			Index:    i + 1,
+			Current:  i+1 == current,
+			Closed:   closed,
+			URL:      e.Get("url").String(),
+			Title:    e.Get("title").String(),
+			Referrer: firefoxReferrer(e),
+			FormData: e.Get("formdata").Raw,
+		}
+		if entry.Current {
+			entry.Timestamp = firefoxTime(t.Get("lastAccessed").Int())
+			// the older versions keep the form data of the current entry in the tab
+			if entry.FormData == "" {
+				entry.FormData = t.Get("formdata").Raw
+			}
+		}
+		if closed {
+			entry.ClosedAt = firefoxTime(closedAt)
+		}
+		*f = append(*f, entry)
+	}
+}
+
+// firefoxReferrer returns the referrer of the entry, which is serialized in referrerInfo since Firefox 70.
+func firefoxReferrer(e gjson.Result) string {
+	if referrer := e.Get("referrer").String(); referrer != "" {
+		return referrer
+	}
+	return decodeReferrerInfo(e.Get("referrerInfo").String())
+}
+
+// decodeReferrerInfo returns the original referrer of the base64 serialized nsIReferrerInfo, the class and
+// interface IDs are followed by a boolean of the referrer and the referrer prefixed by its big endian length.
+// @https://searchfox.org/mozilla-central/source/dom/security/ReferrerInfo.cpp
+func decodeReferrerInfo(s string) string {
+	const idsLen = 32
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) < idsLen+5 || b[idsLen] == 0 {
+		return ""
+	}
+	n := int(binary.BigEndian.Uint32(b[idsLen+1:]))
+	if n > len(b)-idsLen-5 {
+		return ""
+	}
+	return string(b[idsLen+5 : idsLen+5+n])
+}
+
+// firefoxTime converts the milliseconds since the epoch, zero is kept as the zero time.
+func firefoxTime(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+func (f *FirefoxSession) Name() string {
+	return "session"
+}
+
+func (f *FirefoxSession) Len() int {
+	return len(*f)
+}
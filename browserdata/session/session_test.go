@@ -0,0 +1,78 @@
+package session
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/utils/lz4util"
+)
+
+// newReferrerInfo serializes the referrer like nsIReferrerInfo.
+func newReferrerInfo(referrer string) string {
+	b := bytes.Repeat([]byte{0xaa}, 32)
+	b = append(b, 1)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(referrer)))
+	b = append(b, referrer...)
+	b = append(b, 1, 0, 0, 0, 2)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func TestFirefoxSession_Extract(t *testing.T) {
+	session := fmt.Sprintf(`{"version":["sessionrestore",1],
+	"windows":[{"tabs":[
+		{"entries":[{"url":"https://github.com/","title":"GitHub"},
+			{"url":"https://github.com/moonD4rk/HackBrowserData","title":"HackBrowserData","referrerInfo":%q,
+			 "formdata":{"id":{"q":"hack"}}}],
+		 "index":2,"lastAccessed":1700000000000}],
+	 "_closedTabs":[{"state":{"entries":[{"url":"https://example.com/","title":"Example","referrer":"https://www.google.com/"}],
+		"index":1,"lastAccessed":1690000000000},"closedAt":1690000100000}]}],
+	"_closedWindows":[{"tabs":[{"entries":[{"url":"about:blank"}],"index":1}],"closedAt":1680000000000}]}`,
+		newReferrerInfo("https://github.com/"))
+	path := filepath.Join(t.TempDir(), "recovery.jsonlz4")
+	require.NoError(t, os.WriteFile(path, lz4util.EncodeMozLz4([]byte(session)), 0o600))
+
+	var entries FirefoxSession
+	require.NoError(t, entries.Extract(crypto.MasterKeys{}, path))
+	require.Len(t, entries, 4)
+
+	assert.Equal(t, Entry{Window: 1, Tab: 1, Index: 1, URL: "https://github.com/", Title: "GitHub"}, entries[0])
+
+	current := entries[1]
+	assert.True(t, current.Current)
+	assert.Equal(t, 2, current.Index)
+	assert.Equal(t, "https://github.com/", current.Referrer)
+	assert.Equal(t, `{"id":{"q":"hack"}}`, current.FormData)
+	assert.Equal(t, int64(1700000000000), current.Timestamp.UnixMilli())
+	assert.False(t, current.Closed)
+
+	closedTab := entries[2]
+	assert.Equal(t, 1, closedTab.Window)
+	assert.Equal(t, 2, closedTab.Tab)
+	assert.True(t, closedTab.Closed)
+	assert.Equal(t, "https://www.google.com/", closedTab.Referrer)
+	assert.Equal(t, int64(1690000100000), closedTab.ClosedAt.UnixMilli())
+
+	closedWindow := entries[3]
+	assert.Equal(t, 2, closedWindow.Window)
+	assert.True(t, closedWindow.Closed)
+	assert.Equal(t, int64(1680000000000), closedWindow.ClosedAt.UnixMilli())
+}
+
+func TestDecodeReferrerInfo(t *testing.T) {
+	assert.Equal(t, "https://github.com/", decodeReferrerInfo(newReferrerInfo("https://github.com/")))
+	assert.Empty(t, decodeReferrerInfo(""))
+	assert.Empty(t, decodeReferrerInfo("not base64!"))
+
+	// no original referrer
+	noReferrer := append(bytes.Repeat([]byte{0xaa}, 32), 0, 1, 0, 0, 0, 2)
+	assert.Empty(t, decodeReferrerInfo(base64.StdEncoding.EncodeToString(noReferrer)))
+}
@@ -0,0 +1,462 @@
+package session
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"unicode/utf16"
+
+	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
+)
+
+// The SNSS file of Chromium is a log of the commands which build the session, replayed in order on restore.
+//
+//	| "SNSS" | version (int32) | size (uint16) | command id (uint8) | payload (size - 1 bytes) | ...
+//
+// The navigation commands hold a base::Pickle, the other commands hold a plain struct.
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/sessions/core/command_storage_backend.cc
+var (
+	errInvalidSNSS   = errors.New("invalid snss file")
+	errEncryptedSNSS = errors.New("encrypted snss file is not supported")
+	errInvalidPickle = errors.New("invalid pickle")
+)
+
+const snssSignature = "SNSS"
+
+// the versions of the SNSS file, the marker versions add a command marking the initial state
+const (
+	snssVersion                    = 1
+	snssEncryptedVersion           = 2
+	snssVersionWithMarker          = 3
+	snssEncryptedVersionWithMarker = 4
+)
+
+// the commands of the Session_ files
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/sessions/core/session_service_commands.cc
+const (
+	commandSetTabWindow                     = 0
+	commandSetTabIndexInWindow              = 2
+	commandTabNavigationPathPrunedFromBack  = 5
+	commandUpdateTabNavigation              = 6
+	commandSetSelectedNavigationIndex       = 7
+	commandTabNavigationPathPrunedFromFront = 11
+	commandTabClosed                        = 16
+	commandWindowClosed                     = 17
+	commandTabNavigationPathPruned          = 24
+)
+
+// the commands of the Tabs_ files, which keep the recently closed tabs and windows, the window command
+// of the old files holds a plain struct, it's replaced by the window command holding a pickle.
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/sessions/core/tab_restore_service_impl.cc
+const (
+	restoreCommandUpdateTabNavigation     = 1
+	restoreCommandRestoredEntry           = 2
+	restoreCommandWindowDeprecated        = 3
+	restoreCommandSelectedNavigationInTab = 4
+	restoreCommandWindow                  = 9
+)
+
+type snssCommand struct {
+	id      byte
+	payload []byte
+}
+
+// readSNSS returns the commands of the SNSS file, the last command truncated by a crash is dropped.
+func readSNSS(b []byte) ([]snssCommand, error) {
+	if len(b) < 8 || string(b[:4]) != snssSignature {
+		return nil, errInvalidSNSS
+	}
+	switch version := int32(binary.LittleEndian.Uint32(b[4:])); version {
+	case snssVersion, snssVersionWithMarker:
+	case snssEncryptedVersion, snssEncryptedVersionWithMarker:
+		return nil, errEncryptedSNSS
+	default:
+		return nil, fmt.Errorf("%w: unknown version %d", errInvalidSNSS, version)
+	}
+	var commands []snssCommand
+	for off := 8; off+2 <= len(b); {
+		size := int(binary.LittleEndian.Uint16(b[off:]))
+		off += 2
+		if size == 0 || off+size > len(b) {
+			break
+		}
+		commands = append(commands, snssCommand{id: b[off], payload: b[off+1 : off+size]})
+		off += size
+	}
+	return commands, nil
+}
+
+// pickle reads the fields of a base::Pickle, the fields follow the uint32 payload size and are aligned to 4 bytes.
+// @https://source.chromium.org/chromium/chromium/src/+/main:base/pickle.cc
+type pickle struct {
+	b   []byte
+	off int
+	err error
+}
+
+func newPickle(b []byte) *pickle {
+	p := &pickle{b: b}
+	if size := p.uint32(); p.err == nil && int(size) > len(b)-4 {
+		p.err = fmt.Errorf("%w: payload size %d exceeds %d bytes", errInvalidPickle, size, len(b)-4)
+	}
+	return p
+}
+
+func (p *pickle) read(n int) []byte {
+	if p.err != nil {
+		return nil
+	}
+	if n < 0 || p.off+n > len(p.b) {
+		p.err = fmt.Errorf("%w: unexpected end at offset %d", errInvalidPickle, p.off)
+		return nil
+	}
+	v := p.b[p.off : p.off+n]
+	p.off += (n + 3) &^ 3
+	if p.off > len(p.b) {
+		p.off = len(p.b)
+	}
+	return v
+}
+
+func (p *pickle) uint32() uint32 {
+	if b := p.read(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (p *pickle) int32() int32 {
+	return int32(p.uint32())
+}
+
+func (p *pickle) int64() int64 {
+	if b := p.read(8); b != nil {
+		return int64(binary.LittleEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (p *pickle) bool() bool {
+	return p.uint32() != 0
+}
+
+// string reads the bytes prefixed by the int32 length.
+func (p *pickle) string() string {
+	return string(p.read(int(p.int32())))
+}
+
+// string16 reads the UTF-16 string prefixed by the int32 count of the code units.
+func (p *pickle) string16() string {
+	n := int(p.int32())
+	b := p.read(2 * n)
+	if b == nil {
+		return ""
+	}
+	u := make([]uint16, n)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// navigation is a serialized navigation entry of a tab, id is the session id of the tab,
+// or the entry id of the closed tab in the Tabs_ files.
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/sessions/core/serialized_navigation_entry.cc
+type navigation struct {
+	id        int32
+	index     int
+	url       string
+	title     string
+	referrer  string
+	timestamp time.Time
+}
+
+// readNavigation reads the navigation of the UpdateTabNavigation command, the fields after the
+// title are missing in the old files, so only the url and the title are required.
+func readNavigation(payload []byte) (navigation, error) {
+	p := newPickle(payload)
+	nav := navigation{
+		id:    p.int32(),
+		index: int(p.int32()),
+		url:   p.string(),
+		title: p.string16(),
+	}
+	if p.err != nil {
+		return nav, p.err
+	}
+	p.string() // encoded page state
+	p.int32()  // transition type
+	p.int32()  // type mask
+	nav.referrer = p.string()
+	p.int32()  // obsolete referrer policy
+	p.string() // original request url
+	p.bool()   // is overriding user agent
+	nav.timestamp = chromiumTime(p.int64())
+	return nav, nil
+}
+
+// chromiumTime converts the microseconds since 1601, zero is kept as the zero time.
+func chromiumTime(us int64) time.Time {
+	if us == 0 {
+		return time.Time{}
+	}
+	return typeutil.TimeEpoch(us)
+}
+
+// int32At returns the int32 at the offset of the struct payload, 0 if it's out of range.
+func int32At(b []byte, off int) int32 {
+	if off+4 > len(b) {
+		return 0
+	}
+	return int32(binary.LittleEndian.Uint32(b[off:]))
+}
+
+// timeAt returns the time of the int64 at the offset of the struct payload, zero if it's out of range.
+func timeAt(b []byte, off int) time.Time {
+	if off+8 > len(b) {
+		return time.Time{}
+	}
+	return chromiumTime(int64(binary.LittleEndian.Uint64(b[off:])))
+}
+
+// closedTime returns the time of struct { id_type id; int64_t close_time; }, the int64 is
+// aligned to 8 bytes except on 32-bit x86, where the payload is 12 bytes.
+func closedTime(b []byte) time.Time {
+	if len(b) < 16 {
+		return timeAt(b, 4)
+	}
+	return timeAt(b, 8)
+}
+
+// windowClosedTime returns the time of the deprecated window struct, the int64 is aligned to 8 bytes
+// except on 32-bit x86, where the payload is 20 bytes, the oldest payload of 12 bytes has no time.
+func windowClosedTime(b []byte) time.Time {
+	if len(b) < 24 {
+		return timeAt(b, 12)
+	}
+	return timeAt(b, 16)
+}
+
+type snssTab struct {
+	id          int32
+	window      int32
+	index       int
+	selected    int
+	closed      bool
+	closedAt    time.Time
+	navigations map[int]navigation
+	order       int
+}
+
+type snssWindow struct {
+	closed   bool
+	closedAt time.Time
+	order    int
+}
+
+// snssSession replays the commands of a Session_ or Tabs_ file.
+type snssSession struct {
+	tabs    map[int32]*snssTab
+	windows map[int32]*snssWindow
+}
+
+func newSNSSSession() *snssSession {
+	return &snssSession{tabs: make(map[int32]*snssTab), windows: make(map[int32]*snssWindow)}
+}
+
+func (s *snssSession) tab(id int32) *snssTab {
+	t, ok := s.tabs[id]
+	if !ok {
+		t = &snssTab{id: id, navigations: make(map[int]navigation), order: len(s.tabs)}
+		s.tabs[id] = t
+	}
+	return t
+}
+
+func (s *snssSession) window(id int32) *snssWindow {
+	w, ok := s.windows[id]
+	if !ok {
+		w = &snssWindow{order: len(s.windows) + 1}
+		s.windows[id] = w
+	}
+	return w
+}
+
+// replaySession replays the commands of a Session_ file.
+func (s *snssSession) replaySession(commands []snssCommand) {
+	for _, c := range commands {
+		b := c.payload
+		switch c.id {
+		case commandSetTabWindow:
+			window := int32At(b, 0)
+			s.window(window)
+			s.tab(int32At(b, 4)).window = window
+		case commandSetTabIndexInWindow:
+			s.tab(int32At(b, 0)).index = int(int32At(b, 4))
+		case commandUpdateTabNavigation:
+			s.addNavigation(b)
+		case commandSetSelectedNavigationIndex:
+			s.tab(int32At(b, 0)).selected = int(int32At(b, 4))
+		case commandTabNavigationPathPrunedFromBack:
+			// the navigations from the index are removed
+			s.tab(int32At(b, 0)).prune(int(int32At(b, 4)), -1)
+		case commandTabNavigationPathPrunedFromFront:
+			s.tab(int32At(b, 0)).prune(0, int(int32At(b, 4)))
+		case commandTabNavigationPathPruned:
+			s.tab(int32At(b, 0)).prune(int(int32At(b, 4)), int(int32At(b, 8)))
+		case commandTabClosed:
+			t := s.tab(int32At(b, 0))
+			t.closed, t.closedAt = true, closedTime(b)
+		case commandWindowClosed:
+			w := s.window(int32At(b, 0))
+			w.closed, w.closedAt = true, closedTime(b)
+		}
+	}
+}
+
+// replayTabRestore replays the commands of a Tabs_ file, a closed window is followed by its tabs,
+// each tab starts with the selected navigation command holding the time it was closed.
+func (s *snssSession) replayTabRestore(commands []snssCommand) {
+	var (
+		window    int32
+		remaining int
+	)
+	for _, c := range commands {
+		b := c.payload
+		switch c.id {
+		case restoreCommandWindow:
+			p := newPickle(b)
+			id := p.int32()
+			p.int32() // selected tab index
+			count := int(p.int32())
+			timestamp := chromiumTime(p.int64())
+			if p.err == nil {
+				window, remaining = id, count
+				w := s.window(window)
+				w.closed, w.closedAt = true, timestamp
+			}
+		case restoreCommandWindowDeprecated:
+			// struct { id_type window_id; int32_t selected_tab_index; int32_t num_tabs; int64_t timestamp; }
+			if len(b) < 12 {
+				continue
+			}
+			window, remaining = int32At(b, 0), int(int32At(b, 8))
+			w := s.window(window)
+			w.closed, w.closedAt = true, windowClosedTime(b)
+		case restoreCommandSelectedNavigationInTab:
+			t := s.tab(int32At(b, 0))
+			t.selected = int(int32At(b, 4))
+			// struct { id_type id; int32_t index; int64_t timestamp; }
+			t.closed, t.closedAt = true, timeAt(b, 8)
+			if remaining > 0 {
+				t.window = window
+				remaining--
+			}
+		case restoreCommandUpdateTabNavigation:
+			s.addNavigation(b)
+		case restoreCommandRestoredEntry:
+			s.restore(int32At(b, 0))
+		}
+	}
+}
+
+// restore removes the tab or the window with its tabs, which is restored by the user.
+func (s *snssSession) restore(id int32) {
+	delete(s.tabs, id)
+	if _, ok := s.windows[id]; !ok {
+		return
+	}
+	delete(s.windows, id)
+	for tabID, t := range s.tabs {
+		if t.window == id {
+			delete(s.tabs, tabID)
+		}
+	}
+}
+
+func (s *snssSession) addNavigation(payload []byte) {
+	nav, err := readNavigation(payload)
+	if err != nil {
+		return
+	}
+	s.tab(nav.id).navigations[nav.index] = nav
+}
+
+// prune removes count navigations from the index, the later navigations are shifted to fill the gap,
+// a negative count removes all the navigations from the index.
+func (t *snssTab) prune(index, count int) {
+	navigations := make(map[int]navigation, len(t.navigations))
+	for i, nav := range t.navigations {
+		switch {
+		case i < index:
+			navigations[i] = nav
+		case count < 0 || i < index+count:
+		default:
+			nav.index = i - count
+			navigations[nav.index] = nav
+		}
+	}
+	t.navigations = navigations
+}
+
+// entries returns the navigations of the tabs ordered by the window and the index of the tab in the window,
+// a tab whose window isn't known, eg: a closed tab in the Tabs_ file, is in the window 0.
+func (s *snssSession) entries(source string) []Entry {
+	tabs := make([]*snssTab, 0, len(s.tabs))
+	for _, t := range s.tabs {
+		tabs = append(tabs, t)
+	}
+	windowOrder := func(t *snssTab) int {
+		if w, ok := s.windows[t.window]; ok {
+			return w.order
+		}
+		return 0
+	}
+	sort.Slice(tabs, func(i, j int) bool {
+		a, b := tabs[i], tabs[j]
+		if windowOrder(a) != windowOrder(b) {
+			return windowOrder(a) < windowOrder(b)
+		}
+		if a.index != b.index {
+			return a.index < b.index
+		}
+		return a.order < b.order
+	})
+
+	var (
+		entries []Entry
+		tabNum  = make(map[int]int)
+	)
+	for _, t := range tabs {
+		if len(t.navigations) == 0 {
+			continue
+		}
+		window := windowOrder(t)
+		tabNum[window]++
+		closed, closedAt := t.closed, t.closedAt
+		if w, ok := s.windows[t.window]; ok && w.closed && !closed {
+			closed, closedAt = true, w.closedAt
+		}
+		indexes := typeutil.Keys(t.navigations)
+		sort.Ints(indexes)
+		for _, i := range indexes {
+			nav := t.navigations[i]
+			entries = append(entries, Entry{
+				Source:    source,
+				Window:    window,
+				Tab:       tabNum[window],
+				Index:     i + 1,
+				Current:   i == t.selected,
+				Closed:    closed,
+				URL:       nav.url,
+				Title:     nav.title,
+				Referrer:  nav.referrer,
+				Timestamp: nav.timestamp,
+				ClosedAt:  closedAt,
+			})
+		}
+	}
+	return entries
+}
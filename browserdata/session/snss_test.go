@@ -0,0 +1,271 @@
+package session
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+)
+
+// pickleWriter writes the fields of a base::Pickle aligned to 4 bytes.
+type pickleWriter struct {
+	bytes.Buffer
+}
+
+func (w *pickleWriter) align() {
+	for w.Len()%4 != 0 {
+		w.WriteByte(0)
+	}
+}
+
+func (w *pickleWriter) int32(v int32) {
+	_ = binary.Write(w, binary.LittleEndian, v)
+}
+
+func (w *pickleWriter) int64(v int64) {
+	_ = binary.Write(w, binary.LittleEndian, v)
+}
+
+func (w *pickleWriter) string(s string) {
+	w.int32(int32(len(s)))
+	w.WriteString(s)
+	w.align()
+}
+
+func (w *pickleWriter) string16(s string) {
+	u := utf16.Encode([]rune(s))
+	w.int32(int32(len(u)))
+	_ = binary.Write(w, binary.LittleEndian, u)
+	w.align()
+}
+
+// bytes returns the pickle prefixed by the payload size.
+func (w *pickleWriter) bytes() []byte {
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(w.Len())), w.Buffer.Bytes()...)
+}
+
+// chromiumMicros is the time in the microseconds since 1601 used by Chromium.
+func chromiumMicros(t time.Time) int64 {
+	return t.UnixMicro() + 11644473600000000
+}
+
+func navigationPayload(id int32, index int32, url, title, referrer string, timestamp time.Time) []byte {
+	var w pickleWriter
+	w.int32(id)
+	w.int32(index)
+	w.string(url)
+	w.string16(title)
+	w.string("page state")
+	w.int32(0) // transition
+	w.int32(0) // type mask
+	w.string(referrer)
+	w.int32(0)
+	w.string(url)
+	w.int32(0)
+	w.int64(chromiumMicros(timestamp))
+	w.string16("")
+	w.int32(200)
+	return w.bytes()
+}
+
+func structPayload(fields ...any) []byte {
+	var b bytes.Buffer
+	for _, f := range fields {
+		_ = binary.Write(&b, binary.LittleEndian, f)
+	}
+	return b.Bytes()
+}
+
+// newSNSS writes the commands into a SNSS file.
+func newSNSS(version int32, commands ...snssCommand) []byte {
+	b := []byte(snssSignature)
+	b = binary.LittleEndian.AppendUint32(b, uint32(version))
+	for _, c := range commands {
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(c.payload)+1))
+		b = append(b, c.id)
+		b = append(b, c.payload...)
+	}
+	return b
+}
+
+var (
+	testVisitTime = time.Date(2024, 7, 15, 10, 0, 0, 0, time.UTC)
+	testCloseTime = time.Date(2024, 7, 15, 11, 0, 0, 0, time.UTC)
+)
+
+func testSessionFile() []byte {
+	b := newSNSS(snssVersionWithMarker,
+		snssCommand{commandSetTabWindow, structPayload(int32(1), int32(10))},
+		snssCommand{commandSetTabIndexInWindow, structPayload(int32(10), int32(1))},
+		snssCommand{commandSetTabWindow, structPayload(int32(1), int32(11))},
+		snssCommand{commandSetTabIndexInWindow, structPayload(int32(11), int32(0))},
+		snssCommand{commandUpdateTabNavigation, navigationPayload(10, 0, "https://github.com/", "GitHub", "", testVisitTime)},
+		snssCommand{commandUpdateTabNavigation, navigationPayload(10, 1, "https://github.com/moonD4rk/HackBrowserData", "HackBrowserData", "https://github.com/", testVisitTime)},
+		snssCommand{commandUpdateTabNavigation, navigationPayload(10, 2, "https://github.com/pruned", "Pruned", "", testVisitTime)},
+		snssCommand{commandTabNavigationPathPrunedFromBack, structPayload(int32(10), int32(2))},
+		snssCommand{commandSetSelectedNavigationIndex, structPayload(int32(10), int32(1))},
+		snssCommand{commandUpdateTabNavigation, navigationPayload(11, 0, "https://example.com/", "Example 例子", "", testVisitTime)},
+		snssCommand{commandSetTabWindow, structPayload(int32(2), int32(20))},
+		snssCommand{commandUpdateTabNavigation, navigationPayload(20, 0, "https://closed.example.com/", "Closed", "", testVisitTime)},
+		snssCommand{commandWindowClosed, structPayload(int32(2), int32(0), chromiumMicros(testCloseTime))},
+	)
+	// the last command truncated by a crash is dropped
+	return append(b, 0x10, 0x00, commandSetTabWindow, 0x01)
+}
+
+func testTabsFile() []byte {
+	return newSNSS(snssVersion,
+		// a closed window of two tabs
+		snssCommand{restoreCommandWindow, func() []byte {
+			var w pickleWriter
+			w.int32(100)
+			w.int32(0)
+			w.int32(2)
+			w.int64(chromiumMicros(testCloseTime))
+			return w.bytes()
+		}()},
+		snssCommand{restoreCommandSelectedNavigationInTab, structPayload(int32(101), int32(0), chromiumMicros(testCloseTime))},
+		snssCommand{restoreCommandUpdateTabNavigation, navigationPayload(101, 0, "https://a.example.com/", "A", "", testVisitTime)},
+		snssCommand{restoreCommandSelectedNavigationInTab, structPayload(int32(102), int32(0), chromiumMicros(testCloseTime))},
+		snssCommand{restoreCommandUpdateTabNavigation, navigationPayload(102, 0, "https://b.example.com/", "B", "", testVisitTime)},
+		// a closed tab
+		snssCommand{restoreCommandSelectedNavigationInTab, structPayload(int32(103), int32(1), chromiumMicros(testCloseTime))},
+		snssCommand{restoreCommandUpdateTabNavigation, navigationPayload(103, 0, "https://c.example.com/", "C", "", testVisitTime)},
+		snssCommand{restoreCommandUpdateTabNavigation, navigationPayload(103, 1, "https://c.example.com/next", "C next", "https://c.example.com/", testVisitTime)},
+		// a closed tab restored by the user
+		snssCommand{restoreCommandSelectedNavigationInTab, structPayload(int32(104), int32(0), chromiumMicros(testCloseTime))},
+		snssCommand{restoreCommandUpdateTabNavigation, navigationPayload(104, 0, "https://restored.example.com/", "Restored", "", testVisitTime)},
+		snssCommand{restoreCommandRestoredEntry, structPayload(int32(104))},
+	)
+}
+
+func TestChromiumSession_Extract(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Session_13366000000000000"), testSessionFile(), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Tabs_13366000000000000"), testTabsFile(), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Session_13365000000000000"), newSNSS(snssEncryptedVersion), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "LOCK"), nil, 0o600))
+
+	var entries ChromiumSession
+	require.NoError(t, entries.Extract(crypto.MasterKeys{}, dir))
+	require.Len(t, entries, 8)
+
+	session := entries[:4]
+	assert.Equal(t, Entry{
+		Source: "Session_13366000000000000", Window: 1, Tab: 1, Index: 1,
+		URL: "https://example.com/", Title: "Example 例子", Current: true,
+		Timestamp: testVisitTime,
+	}, withUTC(session[0]))
+	assert.Equal(t, "https://github.com/", session[1].URL)
+	assert.Equal(t, 2, session[1].Tab)
+	assert.False(t, session[1].Current)
+	assert.Equal(t, "https://github.com/moonD4rk/HackBrowserData", session[2].URL)
+	assert.Equal(t, "https://github.com/", session[2].Referrer)
+	assert.True(t, session[2].Current)
+	assert.Equal(t, 2, session[3].Window)
+	assert.True(t, session[3].Closed)
+	assert.Equal(t, testCloseTime, session[3].ClosedAt.UTC())
+
+	tabs := entries[4:]
+	// the closed tab without a window is in the window 0
+	assert.Equal(t, "https://c.example.com/", tabs[0].URL)
+	assert.Equal(t, 0, tabs[0].Window)
+	assert.False(t, tabs[0].Current)
+	assert.Equal(t, "https://c.example.com/next", tabs[1].URL)
+	assert.True(t, tabs[1].Current)
+	assert.Equal(t, "https://a.example.com/", tabs[2].URL)
+	assert.Equal(t, 1, tabs[2].Window)
+	assert.Equal(t, 1, tabs[2].Tab)
+	assert.Equal(t, "https://b.example.com/", tabs[3].URL)
+	assert.Equal(t, 2, tabs[3].Tab)
+	for _, e := range tabs {
+		assert.Equal(t, "Tabs_13366000000000000", e.Source)
+		assert.True(t, e.Closed)
+		assert.Equal(t, testCloseTime, e.ClosedAt.UTC())
+	}
+}
+
+func TestChromiumSession_ExtractDeprecatedWindow(t *testing.T) {
+	testCases := []struct {
+		name    string
+		payload []byte
+	}{
+		{name: "aligned", payload: structPayload(int32(100), int32(0), int32(2), int32(0), chromiumMicros(testCloseTime))},
+		{name: "x86", payload: structPayload(int32(100), int32(0), int32(2), chromiumMicros(testCloseTime))},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tabs := newSNSS(snssVersion,
+				snssCommand{restoreCommandWindowDeprecated, tc.payload},
+				snssCommand{restoreCommandSelectedNavigationInTab, structPayload(int32(101), int32(0), chromiumMicros(testCloseTime))},
+				snssCommand{restoreCommandUpdateTabNavigation, navigationPayload(101, 0, "https://a.example.com/", "A", "", testVisitTime)},
+				snssCommand{restoreCommandSelectedNavigationInTab, structPayload(int32(102), int32(0), chromiumMicros(testCloseTime))},
+				snssCommand{restoreCommandUpdateTabNavigation, navigationPayload(102, 0, "https://b.example.com/", "B", "", testVisitTime)},
+			)
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "Tabs_13366000000000000"), tabs, 0o600))
+
+			var entries ChromiumSession
+			require.NoError(t, entries.Extract(crypto.MasterKeys{}, dir))
+			require.Len(t, entries, 2)
+			for i, url := range []string{"https://a.example.com/", "https://b.example.com/"} {
+				assert.Equal(t, url, entries[i].URL)
+				assert.Equal(t, 1, entries[i].Window)
+				assert.Equal(t, i+1, entries[i].Tab)
+				assert.True(t, entries[i].Closed)
+				assert.Equal(t, testCloseTime, entries[i].ClosedAt.UTC())
+			}
+		})
+	}
+}
+
+func withUTC(e Entry) Entry {
+	e.Timestamp = e.Timestamp.UTC()
+	return e
+}
+
+func TestReadSNSS_Invalid(t *testing.T) {
+	_, err := readSNSS([]byte("SNSX\x01\x00\x00\x00"))
+	assert.ErrorIs(t, err, errInvalidSNSS)
+	_, err = readSNSS(newSNSS(snssEncryptedVersionWithMarker))
+	assert.ErrorIs(t, err, errEncryptedSNSS)
+	_, err = readSNSS(newSNSS(9))
+	assert.ErrorIs(t, err, errInvalidSNSS)
+}
+
+func TestTabPrune(t *testing.T) {
+	newTab := func() *snssTab {
+		tab := &snssTab{navigations: make(map[int]navigation)}
+		for i := 0; i < 5; i++ {
+			tab.navigations[i] = navigation{index: i, url: string(rune('a' + i))}
+		}
+		return tab
+	}
+	urls := func(tab *snssTab) []string {
+		var urls []string
+		for i := 0; i < len(tab.navigations); i++ {
+			urls = append(urls, tab.navigations[i].url)
+		}
+		return urls
+	}
+
+	tab := newTab()
+	tab.prune(3, -1)
+	assert.Equal(t, []string{"a", "b", "c"}, urls(tab))
+
+	tab = newTab()
+	tab.prune(0, 2)
+	assert.Equal(t, []string{"c", "d", "e"}, urls(tab))
+
+	tab = newTab()
+	tab.prune(1, 2)
+	assert.Equal(t, []string{"a", "d", "e"}, urls(tab))
+}
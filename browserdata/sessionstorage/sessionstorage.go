@@ -2,20 +2,18 @@ package sessionstorage
 
 import (
 	"bytes"
-	"database/sql"
 	"fmt"
-	"os"
-	"strings"
 
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/tidwall/gjson"
 	"golang.org/x/text/encoding/unicode"
 	"golang.org/x/text/transform"
 
+	"github.com/moond4rk/hackbrowserdata/crypto"
 	"github.com/moond4rk/hackbrowserdata/extractor"
-	"github.com/moond4rk/hackbrowserdata/log"
 	"github.com/moond4rk/hackbrowserdata/types"
 	"github.com/moond4rk/hackbrowserdata/utils/byteutil"
-	"github.com/moond4rk/hackbrowserdata/utils/typeutil"
+	"github.com/moond4rk/hackbrowserdata/utils/lz4util"
 )
 
 func init() {
@@ -27,9 +25,10 @@ func init() {
 	})
 }
 
-type ChromiumSessionStorage []session
+type ChromiumSessionStorage []Session
 
-type session struct {
+// Session is a sessionStorage entry of an origin, IsMeta marks origin metadata entries.
+type Session struct {
 	IsMeta bool
 	URL    string
 	Key    string
@@ -38,19 +37,18 @@ type session struct {
 
 const maxLocalStorageValueLength = 1024 * 2
 
-func (c *ChromiumSessionStorage) Extract(_ []byte) error {
-	db, err := leveldb.OpenFile(types.ChromiumSessionStorage.TempFilename(), nil)
+func (c *ChromiumSessionStorage) Extract(_ crypto.MasterKeys, path string) error {
+	db, err := leveldb.OpenFile(path, nil)
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(types.ChromiumSessionStorage.TempFilename())
 	defer db.Close()
 
 	iter := db.NewIterator(nil, nil)
 	for iter.Next() {
 		key := iter.Key()
 		value := iter.Value()
-		s := new(session)
+		s := new(Session)
 		s.fillKey(key)
 		// don't all value upper than 2KB
 		if len(value) < maxLocalStorageValueLength {
@@ -76,7 +74,7 @@ func (c *ChromiumSessionStorage) Len() int {
 	return len(*c)
 }
 
-func (s *session) fillKey(b []byte) {
+func (s *Session) fillKey(b []byte) {
 	keys := bytes.Split(b, []byte("-"))
 	if len(keys) == 1 && bytes.HasPrefix(keys[0], []byte("META:")) {
 		s.IsMeta = true
@@ -95,11 +93,11 @@ func (s *session) fillKey(b []byte) {
 	}
 }
 
-func (s *session) fillMetaHeader(b []byte) {
+func (s *Session) fillMetaHeader(b []byte) {
 	s.URL = string(bytes.Trim(b, "META:"))
 }
 
-func (s *session) fillHeader(url, key []byte) {
+func (s *Session) fillHeader(url, key []byte) {
 	s.URL = string(bytes.Trim(url, "_"))
 	s.Key = string(bytes.Trim(key, "\x01"))
 }
@@ -111,61 +109,40 @@ func convertUTF16toUTF8(source []byte, endian unicode.Endianness) ([]byte, error
 
 // fillValue fills value of the storage
 // TODO: support unicode charter
-func (s *session) fillValue(b []byte) {
+func (s *Session) fillValue(b []byte) {
 	value := bytes.Map(byteutil.OnSplitUTF8Func, b)
 	s.Value = string(value)
 }
 
-type FirefoxSessionStorage []session
+type FirefoxSessionStorage []Session
 
-const (
-	querySessionStorage = `SELECT originKey, key, value FROM webappsstore2`
-	closeJournalMode    = `PRAGMA journal_mode=off`
-)
-
-func (f *FirefoxSessionStorage) Extract(_ []byte) error {
-	db, err := sql.Open("sqlite", types.FirefoxSessionStorage.TempFilename())
-	if err != nil {
-		return err
-	}
-	defer os.Remove(types.FirefoxSessionStorage.TempFilename())
-	defer db.Close()
-
-	_, err = db.Exec(closeJournalMode)
-	if err != nil {
-		log.Errorf("close journal mode error: %v", err)
-	}
-	rows, err := db.Query(querySessionStorage)
+// Extract reads the sessionStorage of the open and closed tabs in sessionstore.jsonlz4,
+// kept by the origin in the storage of each tab, eg: "storage":{"https://github.com":{"key":"value"}}
+func (f *FirefoxSessionStorage) Extract(_ crypto.MasterKeys, path string) error {
+	b, err := lz4util.ReadMozLz4(path)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var originKey, key, value string
-		if err = rows.Scan(&originKey, &key, &value); err != nil {
-			log.Errorf("scan session storage error: %v", err)
+	state := gjson.ParseBytes(b)
+	windows := append(state.Get("windows").Array(), state.Get("_closedWindows").Array()...)
+	for _, window := range windows {
+		tabs := window.Get("tabs").Array()
+		for _, tab := range window.Get("_closedTabs").Array() {
+			tabs = append(tabs, tab.Get("state"))
+		}
+		for _, tab := range tabs {
+			tab.Get("storage").ForEach(func(origin, storage gjson.Result) bool {
+				storage.ForEach(func(key, value gjson.Result) bool {
+					*f = append(*f, Session{URL: origin.String(), Key: key.String(), Value: value.String()})
+					return true
+				})
+				return true
+			})
 		}
-		s := new(session)
-		s.fillFirefox(originKey, key, value)
-		*f = append(*f, *s)
 	}
 	return nil
 }
 
-func (s *session) fillFirefox(originKey, key, value string) {
-	// originKey = moc.buhtig.:https:443
-	p := strings.Split(originKey, ":")
-	h := typeutil.Reverse([]byte(p[0]))
-	if bytes.HasPrefix(h, []byte(".")) {
-		h = h[1:]
-	}
-	if len(p) == 3 {
-		s.URL = fmt.Sprintf("%s://%s:%s", p[1], string(h), p[2])
-	}
-	s.Key = key
-	s.Value = value
-}
-
 func (f *FirefoxSessionStorage) Name() string {
 	return "sessionStorage"
 }
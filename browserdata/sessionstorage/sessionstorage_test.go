@@ -0,0 +1,30 @@
+package sessionstorage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+	"github.com/moond4rk/hackbrowserdata/utils/lz4util"
+)
+
+func TestFirefoxSessionStorage_Extract(t *testing.T) {
+	session := `{"windows":[{"tabs":[{"entries":[],"storage":{"https://github.com":{"theme":"dark","lang":"en"}}}],
+	"_closedTabs":[{"state":{"entries":[],"storage":{"https://example.com":{"cart":"[1,2]"}}}}]}],
+	"_closedWindows":[{"tabs":[{"entries":[],"storage":{"https://closed.example.com":{"k":"v"}}}]}]}`
+	path := filepath.Join(t.TempDir(), "sessionstore.jsonlz4")
+	require.NoError(t, os.WriteFile(path, lz4util.EncodeMozLz4([]byte(session)), 0o600))
+
+	var storage FirefoxSessionStorage
+	require.NoError(t, storage.Extract(crypto.MasterKeys{}, path))
+	assert.Equal(t, FirefoxSessionStorage{
+		{URL: "https://github.com", Key: "theme", Value: "dark"},
+		{URL: "https://github.com", Key: "lang", Value: "en"},
+		{URL: "https://example.com", Key: "cart", Value: "[1,2]"},
+		{URL: "https://closed.example.com", Key: "k", Value: "v"},
+	}, storage)
+}
@@ -6,18 +6,37 @@ import (
 	"github.com/urfave/cli/v2"
 
 	"github.com/moond4rk/hackbrowserdata/browser"
+	"github.com/moond4rk/hackbrowserdata/browserdata/localstorage"
 	"github.com/moond4rk/hackbrowserdata/log"
 	"github.com/moond4rk/hackbrowserdata/utils/fileutil"
+	"github.com/moond4rk/hackbrowserdata/workspace"
 )
 
 var (
-	browserName  string
-	outputDir    string
-	outputFormat string
-	verbose      bool
-	compress     bool
-	profilePath  string
-	isFullExport bool
+	browserName         string
+	outputDir           string
+	outputFormat        string
+	verbose             bool
+	compress            bool
+	profilePath         string
+	isFullExport        bool
+	masterKey           string
+	masterKeyFile       string
+	safeStoragePassword string
+	safeStorageOS       string
+	passwordStore       string
+	keyringFile         string
+	keyringPassword     string
+	dpapiMasterKeyDir   string
+	dpapiPassword       string
+	dpapiNTHash         string
+	dpapiBackupKeyFile  string
+	keychainFile        string
+	keychainPassword    string
+	firefoxPassword     string
+	firefoxKeyStoreKey  string
+	storageValueLimit   int
+	storageValueDir     string
 )
 
 func main() {
@@ -38,20 +57,75 @@ func Execute() {
 			&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Destination: &outputFormat, Value: "csv", Usage: "output format: csv|json"},
 			&cli.StringFlag{Name: "profile-path", Aliases: []string{"p"}, Destination: &profilePath, Value: "", Usage: "custom profile dir path, get with chrome://version"},
 			&cli.BoolFlag{Name: "full-export", Aliases: []string{"full"}, Destination: &isFullExport, Value: true, Usage: "is export full browsing data"},
+			&cli.StringFlag{Name: "master-key", Destination: &masterKey, Value: "", Usage: "hex or base64 encoded chromium master key, skip key discovery for offline analysis"},
+			&cli.StringFlag{Name: "master-key-file", Destination: &masterKeyFile, Value: "", Usage: "file containing the raw, hex or base64 encoded chromium master key"},
+			&cli.StringFlag{Name: "safe-storage-password", Destination: &safeStoragePassword, Value: "", Usage: "chromium safe storage password to derive the master key from"},
+			&cli.StringFlag{Name: "safe-storage-os", Destination: &safeStorageOS, Value: "", Usage: "os of the copied chromium profile, linux or darwin, defaults to the current os"},
+			&cli.StringFlag{Name: "password-store", Destination: &passwordStore, Value: "", Usage: "linux key store of the chromium safe storage password: basic|gnome-libsecret|kwallet|kwallet5|kwallet6, detected if empty"},
+			&cli.StringFlag{Name: "keyring-file", Destination: &keyringFile, Value: "", Usage: "linux gnome keyring file to read the chromium safe storage password from offline, eg: login.keyring"},
+			&cli.StringFlag{Name: "keyring-password", Destination: &keyringPassword, Value: "", Usage: "login password of the user which encrypts the keyring file"},
+			&cli.StringFlag{Name: "dpapi-masterkey-dir", Destination: &dpapiMasterKeyDir, Value: "", Usage: "Protect/<SID> dir of a windows user to decrypt the chromium master key offline, eg: AppData/Roaming/Microsoft/Protect/S-1-5-21-..."},
+			&cli.StringFlag{Name: "dpapi-password", Destination: &dpapiPassword, Value: "", Usage: "logon password of the windows user which encrypts the dpapi master key files"},
+			&cli.StringFlag{Name: "dpapi-nthash", Destination: &dpapiNTHash, Value: "", Usage: "hex encoded ntlm hash of the windows user password"},
+			&cli.StringFlag{Name: "dpapi-backup-key", Destination: &dpapiBackupKeyFile, Value: "", Usage: "domain dpapi backup key file in pvk format"},
+			&cli.StringFlag{Name: "keychain-file", Destination: &keychainFile, Value: "", Usage: "macos keychain file to read the chromium safe storage password from offline, eg: login.keychain-db"},
+			&cli.StringFlag{Name: "keychain-password", Destination: &keychainPassword, Value: "", Usage: "login password of the macos user which unlocks the keychain file"},
+			&cli.StringFlag{Name: "firefox-password", Destination: &firefoxPassword, Value: "", Usage: "firefox primary password, required if the profile is protected by one"},
+			&cli.StringFlag{Name: "firefox-keystore-key", Destination: &firefoxKeyStoreKey, Value: "", Usage: "hex or base64 encoded os key store key of firefox to decrypt the credit card numbers, read from the secret service on linux if empty"},
+			&cli.IntFlag{Name: "storage-value-limit", Destination: &storageValueLimit, Value: localstorage.DefaultMaxValueLength, Usage: "max length of a localStorage value in the result, 0 means no limit"},
+			&cli.StringFlag{Name: "storage-value-dir", Destination: &storageValueDir, Value: "", Usage: "export the localStorage values longer than the limit into the dir"},
 		},
 		HideHelpCommand: true,
 		Action: func(c *cli.Context) error {
 			if verbose {
 				log.SetVerbose()
 			}
-			browsers, err := browser.PickBrowsers(browserName, profilePath)
+			browsers, err := browser.PickBrowsers(browser.Options{
+				Name:                browserName,
+				ProfilePath:         profilePath,
+				MasterKey:           masterKey,
+				MasterKeyFile:       masterKeyFile,
+				SafeStoragePassword: safeStoragePassword,
+				SafeStorageOS:       safeStorageOS,
+				PasswordStore:       passwordStore,
+				KeyringFile:         keyringFile,
+				KeyringPassword:     keyringPassword,
+				DPAPIMasterKeyDir:   dpapiMasterKeyDir,
+				DPAPIPassword:       dpapiPassword,
+				DPAPINTHash:         dpapiNTHash,
+				DPAPIBackupKeyFile:  dpapiBackupKeyFile,
+				KeychainFile:        keychainFile,
+				KeychainPassword:    keychainPassword,
+				FirefoxPassword:     firefoxPassword,
+				FirefoxKeyStoreKey:  firefoxKeyStoreKey,
+				StorageValueLimit:   storageValueLimit,
+				StorageValueDir:     storageValueDir,
+			})
 			if err != nil {
 				log.Errorf("pick browsers %v", err)
 				return err
 			}
 
+			ws, err := workspace.New()
+			if err != nil {
+				log.Errorf("create workspace %v", err)
+				return err
+			}
+			stop := ws.RemoveOnSignal()
+			defer stop()
+			defer func() {
+				if err := ws.Remove(); err != nil {
+					log.Errorf("remove workspace %v", err)
+				}
+			}()
+
 			for _, b := range browsers {
-				data, err := b.BrowsingData(isFullExport)
+				workDir, err := ws.Dir(b.Name())
+				if err != nil {
+					log.Errorf("create workspace dir error %v", err)
+					continue
+				}
+				data, err := b.BrowsingData(isFullExport, workDir)
 				if err != nil {
 					log.Errorf("get browsing data error %v", err)
 					continue
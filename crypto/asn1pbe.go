@@ -34,6 +34,16 @@ func NewASN1PBE(b []byte) (pbe ASN1PBE, err error) {
 
 var ErrDecodeASN1Failed = errors.New("decode ASN1 data failed")
 
+// NewNSSPBE returns the nssPBE of an entry which stores the entry salt and the
+// encrypted data without ASN1 encoding, eg: password-check of the legacy key3.db
+func NewNSSPBE(entrySalt, encrypted []byte) ASN1PBE {
+	var n nssPBE
+	n.AlgoAttr.SaltAttr.EntrySalt = entrySalt
+	n.AlgoAttr.SaltAttr.Len = len(entrySalt)
+	n.Encrypted = encrypted
+	return n
+}
+
 // nssPBE Struct
 //
 //	SEQUENCE (2 elem)
@@ -295,3 +295,12 @@ func TestLoginPBE_Decrypt(t *testing.T) {
 		assert.Equal(t, pbePlaintext, decrypted)
 	}
 }
+
+func TestNewNSSPBE(t *testing.T) {
+	for _, tc := range nssPBETestCases {
+		pbe := NewNSSPBE(tc.GlobalSalt, tc.Encrypted)
+		decrypted, err := pbe.Decrypt(tc.GlobalSalt)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, tc.Plaintext, decrypted)
+	}
+}
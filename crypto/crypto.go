@@ -5,11 +5,80 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/des"
+	"crypto/sha1"
 	"errors"
 	"fmt"
 )
 
-var ErrCiphertextLengthIsInvalid = errors.New("ciphertext length is invalid")
+var (
+	ErrCiphertextLengthIsInvalid = errors.New("ciphertext length is invalid")
+	ErrMasterKeyNotFound         = errors.New("master key of the ciphertext scheme not found")
+)
+
+// the schemes of the Chromium ciphertexts, named by their version prefix
+const (
+	SchemeV10   = "v10"
+	SchemeV11   = "v11"
+	SchemeDPAPI = "dpapi"
+)
+
+// EncryptScheme returns the scheme of the Chromium ciphertext by its version prefix, eg: v10, v11.
+// The ciphertext without a version prefix is encrypted by DPAPI on Windows.
+func EncryptScheme(ciphertext []byte) string {
+	if len(ciphertext) >= 3 && ciphertext[0] == 'v' && isDigit(ciphertext[1]) && isDigit(ciphertext[2]) {
+		return string(ciphertext[:3])
+	}
+	return SchemeDPAPI
+}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+// MasterKeys are the keys passed to the extractors, a Chromium ciphertext is decrypted by the key of its
+// version prefix only. V10 is the key of Windows and macOS, and the key derived from "peanuts" on Linux,
+// V11 is the key derived from the Safe Storage password in the Linux keyring. The browsers with a single
+// key keep it in V10, eg: the NSS key of Firefox.
+type MasterKeys struct {
+	V10 []byte
+	V11 []byte
+}
+
+// IsEmpty reports whether no key is set, the Chromium values of Windows are decrypted by DPAPI then.
+func (k MasterKeys) IsEmpty() bool {
+	return len(k.V10) == 0 && len(k.V11) == 0
+}
+
+// peanutsKey is derived from the hardcoded password "peanuts", which Chromium uses for the v10
+// ciphertexts of Linux whatever the keyring is.
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/os_crypt/sync/os_crypt_linux.cc
+var peanutsKey = PBKDF2Key([]byte("peanuts"), []byte("saltysalt"), 1, 16, sha1.New)
+
+// LinuxMasterKeys returns the keys of a Linux profile, the v10 ciphertexts are encrypted by the key
+// derived from "peanuts", and the v11 ciphertexts by the key of the keyring.
+func LinuxMasterKeys(key []byte) MasterKeys {
+	return MasterKeys{V10: peanutsKey, V11: key}
+}
+
+// DecryptWithChromium decrypts the ciphertext by the key of its version prefix, a 32 bytes key is the
+// AES-256-GCM key of Windows, the others are the AES-128-CBC keys of Linux and macOS.
+func DecryptWithChromium(keys MasterKeys, ciphertext []byte) ([]byte, error) {
+	var key []byte
+	switch EncryptScheme(ciphertext) {
+	case SchemeV10:
+		key = keys.V10
+	case SchemeV11:
+		key = keys.V11
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMasterKeyNotFound, EncryptScheme(ciphertext))
+	}
+	if len(key) == 32 {
+		return decryptWithAESGCM(key, ciphertext)
+	}
+	iv := []byte{32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32}
+	return AES128CBCDecrypt(key, iv, ciphertext[3:])
+}
 
 func AES128CBCDecrypt(key, iv, ciphertext []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
@@ -90,6 +159,11 @@ func DES3Encrypt(key, iv, plaintext []byte) ([]byte, error) {
 
 // AESGCMDecrypt chromium > 80 https://source.chromium.org/chromium/chromium/src/+/master:components/os_crypt/os_crypt_win.cc
 func AESGCMDecrypt(key, nounce, ciphertext []byte) ([]byte, error) {
+	return AESGCMDecryptWithAAD(key, nounce, ciphertext, nil)
+}
+
+// AESGCMDecryptWithAAD decrypts the ciphertext authenticated with the additional data.
+func AESGCMDecryptWithAAD(key, nounce, ciphertext, additionalData []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -98,13 +172,29 @@ func AESGCMDecrypt(key, nounce, ciphertext []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	origData, err := blockMode.Open(nil, nounce, ciphertext, nil)
+	origData, err := blockMode.Open(nil, nounce, ciphertext, additionalData)
 	if err != nil {
 		return nil, err
 	}
 	return origData, nil
 }
 
+const (
+	// Assuming the nonce size is 12 bytes and the minimum encrypted data size is 3 bytes
+	minEncryptedDataSize = 15
+	nonceSize            = 12
+)
+
+// decryptWithAESGCM decrypts the v10 ciphertext of Windows, the AES-256-GCM nonce follows the prefix.
+func decryptWithAESGCM(key, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < minEncryptedDataSize {
+		return nil, ErrCiphertextLengthIsInvalid
+	}
+	nonce := ciphertext[3 : 3+nonceSize]
+	encryptedPassword := ciphertext[3+nonceSize:]
+	return AESGCMDecrypt(key, nonce, encryptedPassword)
+}
+
 // AESGCMEncrypt encrypts plaintext using AES encryption in GCM mode.
 func AESGCMEncrypt(key, nonce, plaintext []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
@@ -135,9 +225,13 @@ func pkcs5UnPadding(src []byte) ([]byte, error) {
 		return nil, errors.New("pkcs5UnPadding: src should not be empty")
 	}
 	padding := int(src[length-1])
-	if padding < 1 || padding > aes.BlockSize {
+	if padding < 1 || padding > aes.BlockSize || padding > length {
 		return nil, errors.New("pkcs5UnPadding: invalid padding size")
 	}
+	// every byte of the padding is its size, it rejects most plaintexts of a wrong key
+	if !bytes.Equal(src[length-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errors.New("pkcs5UnPadding: invalid padding")
+	}
 	return src[:length-padding], nil
 }
 
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const baseKey = "moond4rk"
@@ -70,3 +71,58 @@ func TestAESGCMDecrypt(t *testing.T) {
 	assert.Equal(t, true, len(decrypted) > 0)
 	assert.Equal(t, plainText, decrypted)
 }
+
+func TestEncryptScheme(t *testing.T) {
+	assert.Equal(t, SchemeV10, EncryptScheme([]byte("v10ciphertext")))
+	assert.Equal(t, SchemeV11, EncryptScheme([]byte("v11ciphertext")))
+	assert.Equal(t, "v20", EncryptScheme([]byte("v20ciphertext")))
+	assert.Equal(t, SchemeDPAPI, EncryptScheme([]byte{0x01, 0x00, 0x00, 0x00, 0xd0, 0x8c}))
+	assert.Equal(t, SchemeDPAPI, EncryptScheme(nil))
+}
+
+func TestDecryptWithChromium(t *testing.T) {
+	iv := bytes.Repeat([]byte{' '}, 16)
+	v10, err := AES128CBCEncrypt(peanutsKey, iv, plainText)
+	require.NoError(t, err)
+	v11, err := AES128CBCEncrypt(aesKey, iv, plainText)
+	require.NoError(t, err)
+	keys := LinuxMasterKeys(aesKey)
+
+	decrypted, err := DecryptWithChromium(keys, append([]byte(SchemeV10), v10...))
+	require.NoError(t, err)
+	assert.Equal(t, plainText, decrypted)
+	decrypted, err = DecryptWithChromium(keys, append([]byte(SchemeV11), v11...))
+	require.NoError(t, err)
+	assert.Equal(t, plainText, decrypted)
+
+	// the key is selected by the prefix only, the other key is never tried
+	_, err = DecryptWithChromium(keys, append([]byte(SchemeV11), v10...))
+	assert.Error(t, err)
+	_, err = DecryptWithChromium(MasterKeys{V10: aesKey}, append([]byte(SchemeV11), v11...))
+	assert.ErrorIs(t, err, ErrMasterKeyNotFound)
+	_, err = DecryptWithChromium(keys, []byte("dpapi blob"))
+	assert.ErrorIs(t, err, ErrMasterKeyNotFound)
+}
+
+func TestDecryptWithChromium_MacKey(t *testing.T) {
+	// the v10 ciphertexts of macOS are encrypted by the keychain key instead of the peanuts key
+	iv := bytes.Repeat([]byte{' '}, 16)
+	v10, err := AES128CBCEncrypt(aesKey, iv, plainText)
+	require.NoError(t, err)
+
+	decrypted, err := DecryptWithChromium(MasterKeys{V10: aesKey}, append([]byte(SchemeV10), v10...))
+	require.NoError(t, err)
+	assert.Equal(t, plainText, decrypted)
+}
+
+func TestDecryptWithChromium_WindowsKey(t *testing.T) {
+	key := bytes.Repeat([]byte{'w'}, 32)
+	nonce := bytes.Repeat([]byte{'n'}, nonceSize)
+	encrypted, err := AESGCMEncrypt(key, nonce, plainText)
+	require.NoError(t, err)
+	ciphertext := append(append([]byte(SchemeV10), nonce...), encrypted...)
+
+	decrypted, err := DecryptWithChromium(MasterKeys{V10: key}, ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, plainText, decrypted)
+}
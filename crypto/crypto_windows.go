@@ -8,38 +8,6 @@ import (
 	"unsafe"
 )
 
-const (
-	// Assuming the nonce size is 12 bytes and the minimum encrypted data size is 3 bytes
-	minEncryptedDataSize = 15
-	nonceSize            = 12
-)
-
-func DecryptWithChromium(key, ciphertext []byte) ([]byte, error) {
-	if len(ciphertext) < minEncryptedDataSize {
-		return nil, ErrCiphertextLengthIsInvalid
-	}
-
-	nonce := ciphertext[3 : 3+nonceSize]
-	encryptedPassword := ciphertext[3+nonceSize:]
-
-	return AESGCMDecrypt(key, nonce, encryptedPassword)
-}
-
-// DecryptWithYandex decrypts the password with AES-GCM
-func DecryptWithYandex(key, ciphertext []byte) ([]byte, error) {
-	if len(ciphertext) < minEncryptedDataSize {
-		return nil, ErrCiphertextLengthIsInvalid
-	}
-	// remove Prefix 'v10'
-	// gcmBlockSize         = 16
-	// gcmTagSize           = 16
-	// gcmMinimumTagSize    = 12 // NIST SP 800-38D recommends tags with 12 or more bytes.
-	// gcmStandardNonceSize = 12
-	nonce := ciphertext[3 : 3+nonceSize]
-	encryptedPassword := ciphertext[3+nonceSize:]
-	return AESGCMDecrypt(key, nonce, encryptedPassword)
-}
-
 type dataBlob struct {
 	cbData uint32
 	pbData *byte
@@ -0,0 +1,90 @@
+package dpapi
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var errInvalidBackupKey = errors.New("invalid dpapi backup key")
+
+const (
+	pvkMagic           = 0xb0b5f11e
+	privateKeyBlob     = 0x07
+	rsaPrivateKeyMagic = "RSA2"
+)
+
+// ParseBackupKey parses the domain backup key in the PVK format, as exported by
+// mimikatz lsadump::backupkeys or impacket dpapi backupkeys, eg: ntds_capi_0_<GUID>.pvk
+//
+//	| magic | reserved | key type | encrypted | salt length | key length | salt | PRIVATEKEYBLOB |
+//
+// @https://learn.microsoft.com/en-us/windows/win32/seccrypto/base-provider-key-blobs
+func ParseBackupKey(b []byte) (*rsa.PrivateKey, error) {
+	r := &reader{b: b}
+	magic := r.uint32()
+	r.uint32() // reserved
+	r.uint32() // key type
+	encrypted := r.uint32()
+	saltLen := r.uint32()
+	keyLen := r.uint32()
+	r.bytes(int(saltLen))
+	blob := r.bytes(int(keyLen))
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidBackupKey, r.err)
+	}
+	if magic != pvkMagic {
+		return nil, fmt.Errorf("%w: bad magic %#x", errInvalidBackupKey, magic)
+	}
+	if encrypted != 0 {
+		return nil, fmt.Errorf("%w: encrypted pvk is not supported", errInvalidBackupKey)
+	}
+	return parsePrivateKeyBlob(blob)
+}
+
+// parsePrivateKeyBlob parses the RSA PRIVATEKEYBLOB, the numbers are little endian.
+//
+//	| type | version | reserved | algorithm | magic | bit length | public exponent |
+//	| modulus | prime1 | prime2 | exponent1 | exponent2 | coefficient | private exponent |
+func parsePrivateKeyBlob(b []byte) (*rsa.PrivateKey, error) {
+	r := &reader{b: b}
+	header := r.bytes(8)
+	magic := r.bytes(4)
+	bitLen := int(r.uint32())
+	pubExp := r.uint32()
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidBackupKey, r.err)
+	}
+	if header[0] != privateKeyBlob || string(magic) != rsaPrivateKeyMagic {
+		return nil, fmt.Errorf("%w: not a rsa private key", errInvalidBackupKey)
+	}
+	number := func(n int) *big.Int {
+		le := r.bytes(n)
+		be := make([]byte, len(le))
+		for i, v := range le {
+			be[len(le)-1-i] = v
+		}
+		return new(big.Int).SetBytes(be)
+	}
+	n := number(bitLen / 8)
+	p := number(bitLen / 16)
+	q := number(bitLen / 16)
+	number(bitLen / 16) // exponent1
+	number(bitLen / 16) // exponent2
+	number(bitLen / 16) // coefficient
+	d := number(bitLen / 8)
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidBackupKey, r.err)
+	}
+	key := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: n, E: int(pubExp)},
+		D:         d,
+		Primes:    []*big.Int{p, q},
+	}
+	if err := key.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidBackupKey, err)
+	}
+	key.Precompute()
+	return key, nil
+}
@@ -0,0 +1,106 @@
+package dpapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"fmt"
+	"hash"
+)
+
+// Blob is the output of CryptProtectData, laid out as:
+//
+//	| version | provider GUID | master key version | master key GUID | flags | description |
+//	| cipher algorithm | cipher key length | salt | strong key | hash algorithm | hash length |
+//	| HMAC key | ciphertext | sign |
+//
+// The sign is the HMAC of the fields from the master key version to the ciphertext.
+type Blob struct {
+	// MasterKeyGUID names the master key file the blob is encrypted with.
+	MasterKeyGUID string
+	Description   string
+	cryptID       uint32
+	hashID        uint32
+	salt          []byte
+	hmacKey       []byte
+	ciphertext    []byte
+	signed        []byte
+	sign          []byte
+}
+
+// ParseBlob parses the DPAPI blob, eg: os_crypt.encrypted_key in Local State without the "DPAPI" prefix.
+func ParseBlob(b []byte) (*Blob, error) {
+	r := &reader{b: b}
+	r.uint32()  // version
+	r.bytes(16) // provider GUID
+	start := r.off
+	r.uint32() // master key version
+	guid := r.bytes(16)
+	r.uint32() // flags
+	blob := &Blob{Description: utf16String(r.lengthPrefixed())}
+	blob.cryptID = r.uint32()
+	r.uint32() // cipher key length
+	blob.salt = r.lengthPrefixed()
+	r.lengthPrefixed() // strong key, the entropy of the prompt
+	blob.hashID = r.uint32()
+	r.uint32() // hash length
+	blob.hmacKey = r.lengthPrefixed()
+	blob.ciphertext = r.lengthPrefixed()
+	end := r.off
+	blob.sign = r.lengthPrefixed()
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidBlob, r.err)
+	}
+	blob.MasterKeyGUID = formatGUID(guid)
+	blob.signed = b[start:end]
+	return blob, nil
+}
+
+// Decrypt decrypts the blob with the 64 bytes master key, which is verified by the sign of the blob.
+// @https://github.com/jordanbtucker/dpapick/blob/master/DPAPI/Core/blob.py
+func (b *Blob) Decrypt(masterKey []byte) ([]byte, error) {
+	h, err := hashAlgorithm(b.hashID)
+	if err != nil {
+		return nil, err
+	}
+	alg, err := cryptAlgorithm(b.cryptID)
+	if err != nil {
+		return nil, err
+	}
+	keyHash := sha1.Sum(masterKey)
+	sign := hmacSum(h, keyHash[:], b.hmacKey, b.signed)
+	if !hmac.Equal(sign, b.sign) {
+		return nil, ErrCredentialIncorrect
+	}
+	sessionKey := hmacSum(h, keyHash[:], b.salt)
+	key := deriveKey(sessionKey, alg.keyLen, h)
+	plaintext, err := alg.decryptCBC(key, make([]byte, alg.blockSize), b.ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidBlob, err)
+	}
+	padding := int(plaintext[len(plaintext)-1])
+	if padding < 1 || padding > alg.blockSize {
+		return nil, fmt.Errorf("%w: invalid padding", errInvalidBlob)
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// deriveKey expands the session key to the cipher key like CryptDeriveKey,
+// the session key is used as is if it's long enough.
+func deriveKey(sessionKey []byte, keyLen int, h func() hash.Hash) []byte {
+	if len(sessionKey) >= keyLen {
+		return sessionKey[:keyLen]
+	}
+	blockSize := h().BlockSize()
+	ipad := make([]byte, blockSize)
+	opad := make([]byte, blockSize)
+	copy(ipad, sessionKey)
+	copy(opad, sessionKey)
+	for i := range ipad {
+		ipad[i] ^= 0x36
+		opad[i] ^= 0x5c
+	}
+	inner, outer := h(), h()
+	inner.Write(ipad)
+	outer.Write(opad)
+	return append(inner.Sum(nil), outer.Sum(nil)...)[:keyLen]
+}
@@ -0,0 +1,221 @@
+// Package dpapi decrypts Windows DPAPI blobs offline, without the Windows API.
+// The blob is decrypted with a master key of the user, which is decrypted from the
+// master key files in %APPDATA%\Microsoft\Protect\<SID> with the password or NTLM hash
+// of the user, or with the domain backup key.
+// @https://www.passcape.com/index.php?section=docsys&cmd=details&id=28
+package dpapi
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	errInvalidBlob          = errors.New("invalid dpapi blob")
+	errInvalidMasterKeyFile = errors.New("invalid dpapi master key file")
+	errUnsupportedAlgorithm = errors.New("unsupported dpapi algorithm")
+	ErrMasterKeyNotFound    = errors.New("dpapi master key file not found")
+	ErrCredentialIncorrect  = errors.New("dpapi credential is incorrect")
+	ErrCredentialRequired   = errors.New("dpapi credential is required, one of password, nt hash and backup key")
+)
+
+// the algorithm ids of the CryptoAPI
+// @https://learn.microsoft.com/en-us/windows/win32/seccrypto/alg-id
+const (
+	calgSHA1   = 0x8004
+	calgHMAC   = 0x8009
+	calgSHA256 = 0x800c
+	calgSHA512 = 0x800e
+	calg3DES   = 0x6603
+	calgAES256 = 0x6610
+)
+
+// hashAlgorithm returns the hash function of the algorithm id, CALG_HMAC is HMAC-SHA1.
+func hashAlgorithm(id uint32) (func() hash.Hash, error) {
+	switch id {
+	case calgSHA1, calgHMAC:
+		return sha1.New, nil
+	case calgSHA256:
+		return sha256.New, nil
+	case calgSHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("%w: hash %#x", errUnsupportedAlgorithm, id)
+}
+
+// cipherAlgorithm describes a block cipher of the algorithm id.
+type cipherAlgorithm struct {
+	keyLen    int
+	blockSize int
+	newCipher func(key []byte) (cipher.Block, error)
+}
+
+func cryptAlgorithm(id uint32) (cipherAlgorithm, error) {
+	switch id {
+	case calg3DES:
+		return cipherAlgorithm{keyLen: 24, blockSize: des.BlockSize, newCipher: des.NewTripleDESCipher}, nil
+	case calgAES256:
+		return cipherAlgorithm{keyLen: 32, blockSize: aes.BlockSize, newCipher: aes.NewCipher}, nil
+	}
+	return cipherAlgorithm{}, fmt.Errorf("%w: cipher %#x", errUnsupportedAlgorithm, id)
+}
+
+// decryptCBC decrypts the ciphertext in CBC mode, the padding is kept.
+func (c cipherAlgorithm) decryptCBC(key, iv, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) == 0 || len(ciphertext)%c.blockSize != 0 {
+		return nil, fmt.Errorf("ciphertext length %d is not a multiple of the block size", len(ciphertext))
+	}
+	block, err := c.newCipher(key[:c.keyLen])
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv[:c.blockSize]).CryptBlocks(plaintext, ciphertext)
+	return plaintext, nil
+}
+
+// Unprotect decrypts the blob like CryptUnprotectData, with the master key file named by the
+// GUID in the blob under masterKeyDir, the Protect/<SID> dir of the user. The SID of the
+// credential is taken from the name of masterKeyDir if it's empty.
+func Unprotect(data []byte, masterKeyDir string, cred Credential) ([]byte, error) {
+	blob, err := ParseBlob(data)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(filepath.Join(masterKeyDir, blob.MasterKeyGUID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrMasterKeyNotFound, blob.MasterKeyGUID)
+		}
+		return nil, err
+	}
+	mkf, err := ParseMasterKeyFile(b)
+	if err != nil {
+		return nil, err
+	}
+	if cred.SID == "" {
+		cred.SID = filepath.Base(masterKeyDir)
+	}
+	masterKey, err := mkf.Decrypt(cred)
+	if err != nil {
+		return nil, err
+	}
+	return blob.Decrypt(masterKey)
+}
+
+// pbkdf2 is the PBKDF2 of DPAPI, which differs from RFC 2898 by feeding the XOR
+// accumulated block instead of the last HMAC into the next round.
+// @https://github.com/gentilkiwi/mimikatz/blob/master/modules/kull_m_crypto.c
+func pbkdf2(password, salt []byte, iter, keyLen int, h func() hash.Hash) []byte {
+	prf := hmac.New(h, password)
+	hashLen := prf.Size()
+	var buf [4]byte
+	dk := make([]byte, 0, keyLen+hashLen)
+	for block := uint32(1); len(dk) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], block)
+		prf.Write(buf[:])
+		t := prf.Sum(nil)
+		for n := 1; n < iter; n++ {
+			prf.Reset()
+			prf.Write(t)
+			u := prf.Sum(nil)
+			for x := range t {
+				t[x] ^= u[x]
+			}
+		}
+		dk = append(dk, t...)
+	}
+	return dk[:keyLen]
+}
+
+func hmacSum(h func() hash.Hash, key []byte, data ...[]byte) []byte {
+	mac := hmac.New(h, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// utf16le encodes the string in UTF-16LE, the encoding of the Windows strings.
+func utf16le(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(u))
+	for i, v := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], v)
+	}
+	return b
+}
+
+// formatGUID formats the little endian GUID, eg: 0b5ffc38-4cdd-4a6f-9d0d-2a85ea1e8c74
+func formatGUID(b []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b[0:4]),
+		binary.LittleEndian.Uint16(b[4:6]),
+		binary.LittleEndian.Uint16(b[6:8]),
+		b[8:10], b[10:16])
+}
+
+// reader reads the little endian fields of the DPAPI structures, the first error is kept in err.
+type reader struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.off+n > len(r.b) {
+		r.err = fmt.Errorf("unexpected end of data at offset %d", r.off)
+		return nil
+	}
+	b := r.b[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *reader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *reader) uint64() uint64 {
+	b := r.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// lengthPrefixed reads the bytes prefixed by an uint32 length.
+func (r *reader) lengthPrefixed() []byte {
+	return r.bytes(int(r.uint32()))
+}
+
+// utf16String decodes the UTF-16LE string and drops the trailing NUL.
+func utf16String(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return strings.TrimRight(string(utf16.Decode(u)), "\x00")
+}
@@ -0,0 +1,191 @@
+package dpapi
+
+import (
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/moond4rk/hackbrowserdata/crypto"
+)
+
+// Credential decrypts the master key files of a user, set one of Password, NTHash and BackupKey.
+type Credential struct {
+	// SID is the security identifier of the user, eg: S-1-5-21-1004336348-1177238915-682003330-1001
+	SID string
+	// Password is the logon password of the user.
+	Password string
+	// NTHash is the NTLM hash of the password, MD4 of the UTF-16LE password.
+	NTHash []byte
+	// BackupKey is the RSA private key of the domain, it decrypts the master keys of every domain user.
+	BackupKey *rsa.PrivateKey
+}
+
+// preKeys returns the keys the master key may be encrypted with, the SHA1 of the password for
+// local accounts, the NTLM hash for domain accounts and its PBKDF2 for protected users.
+// @https://github.com/fortra/impacket/blob/master/impacket/dpapi.py
+func (c Credential) preKeys() [][]byte {
+	var hashes [][]byte
+	nt := c.NTHash
+	if c.Password != "" {
+		sum := sha1.Sum(utf16le(c.Password))
+		hashes = append(hashes, sum[:])
+		if nt == nil {
+			nt = ntHash(c.Password)
+		}
+	}
+	sid := utf16le(c.SID + "\x00")
+	keys := make([][]byte, 0, len(hashes)+2)
+	for _, h := range hashes {
+		keys = append(keys, hmacSum(sha1.New, h, sid))
+	}
+	if nt != nil {
+		keys = append(keys, hmacSum(sha1.New, nt, sid))
+		sidNoNul := utf16le(c.SID)
+		protected := crypto.PBKDF2Key(nt, sidNoNul, 10000, sha256.Size, sha256.New)
+		protected = crypto.PBKDF2Key(protected, sidNoNul, 1, 16, sha256.New)
+		keys = append(keys, hmacSum(sha1.New, protected, sid))
+	}
+	return keys
+}
+
+// MasterKeyFile is a file in the Protect/<SID> dir, named by the GUID of the master key.
+//
+//	| version | reserved | GUID | unused | policy | master key length | backup key length |
+//	| credhist length | domain key length | master key | backup key | credhist | domain key |
+type MasterKeyFile struct {
+	// GUID names the master key, the DPAPI blob refers to it.
+	GUID      string
+	masterKey []byte
+	domainKey []byte
+}
+
+// ParseMasterKeyFile parses the sections of the master key file.
+func ParseMasterKeyFile(b []byte) (*MasterKeyFile, error) {
+	r := &reader{b: b}
+	r.uint32() // version
+	r.bytes(8) // reserved
+	guid := utf16String(r.bytes(72))
+	r.bytes(8) // unused
+	r.uint32() // policy
+	masterKeyLen := r.uint64()
+	backupKeyLen := r.uint64()
+	credHistLen := r.uint64()
+	domainKeyLen := r.uint64()
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidMasterKeyFile, r.err)
+	}
+	if masterKeyLen+backupKeyLen+credHistLen+domainKeyLen > uint64(len(b)) {
+		return nil, errInvalidMasterKeyFile
+	}
+	f := &MasterKeyFile{GUID: guid}
+	f.masterKey = r.bytes(int(masterKeyLen))
+	r.bytes(int(backupKeyLen))
+	r.bytes(int(credHistLen))
+	f.domainKey = r.bytes(int(domainKeyLen))
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidMasterKeyFile, r.err)
+	}
+	return f, nil
+}
+
+// Decrypt returns the 64 bytes master key, decrypted with the backup key of the credential
+// if it's set, otherwise with the keys derived from the password or NTLM hash.
+func (f *MasterKeyFile) Decrypt(cred Credential) ([]byte, error) {
+	if cred.BackupKey != nil {
+		return f.decryptDomainKey(cred.BackupKey)
+	}
+	keys := cred.preKeys()
+	if len(keys) == 0 {
+		return nil, ErrCredentialRequired
+	}
+	for _, key := range keys {
+		masterKey, err := decryptMasterKey(f.masterKey, key)
+		if err != nil {
+			return nil, err
+		}
+		if masterKey != nil {
+			return masterKey, nil
+		}
+	}
+	return nil, ErrCredentialIncorrect
+}
+
+// decryptMasterKey decrypts the master key section with the pre-key, it returns nil if the
+// HMAC of the decrypted key doesn't match, the section is laid out as:
+//
+//	| version | salt | rounds | hash algorithm | cipher algorithm | encrypted key |
+//
+// The decrypted key is | HMAC salt | HMAC | padding | master key |
+func decryptMasterKey(section, preKey []byte) ([]byte, error) {
+	r := &reader{b: section}
+	r.uint32() // version
+	salt := r.bytes(16)
+	rounds := r.uint32()
+	hashID := r.uint32()
+	cryptID := r.uint32()
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidMasterKeyFile, r.err)
+	}
+	encrypted := r.b[r.off:]
+	h, err := hashAlgorithm(hashID)
+	if err != nil {
+		return nil, err
+	}
+	alg, err := cryptAlgorithm(cryptID)
+	if err != nil {
+		return nil, err
+	}
+	derived := pbkdf2(preKey, salt, int(rounds), alg.keyLen+alg.blockSize, h)
+	plaintext, err := alg.decryptCBC(derived[:alg.keyLen], derived[alg.keyLen:], encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidMasterKeyFile, err)
+	}
+	hashLen := h().Size()
+	if len(plaintext) < 16+hashLen+64 {
+		return nil, errInvalidMasterKeyFile
+	}
+	hmacSalt, mac := plaintext[:16], plaintext[16:16+hashLen]
+	masterKey := plaintext[len(plaintext)-64:]
+	want := hmacSum(h, hmacSum(h, preKey, hmacSalt), masterKey)
+	if !hmac.Equal(want, mac) {
+		return nil, nil
+	}
+	return masterKey, nil
+}
+
+// decryptDomainKey decrypts the domain key section with the domain backup key,
+// the section is laid out as:
+//
+//	| version | secret length | access check length | GUID | secret | access check |
+//
+// The secret is encrypted with RSA in little endian, it holds | key length | supplement length | key |
+func (f *MasterKeyFile) decryptDomainKey(backupKey *rsa.PrivateKey) ([]byte, error) {
+	r := &reader{b: f.domainKey}
+	r.uint32() // version
+	secretLen := r.uint32()
+	r.uint32() // access check length
+	r.bytes(16)
+	secret := r.bytes(int(secretLen))
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: no domain key: %v", errInvalidMasterKeyFile, r.err)
+	}
+	reversed := make([]byte, len(secret))
+	for i, v := range secret {
+		reversed[len(secret)-1-i] = v
+	}
+	plaintext, err := rsa.DecryptPKCS1v15(nil, backupKey, reversed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCredentialIncorrect, err)
+	}
+	if len(plaintext) < 8 {
+		return nil, errInvalidMasterKeyFile
+	}
+	keyLen := binary.LittleEndian.Uint32(plaintext)
+	if uint64(keyLen) > uint64(len(plaintext)-8) {
+		return nil, errInvalidMasterKeyFile
+	}
+	return plaintext[8 : 8+keyLen], nil
+}
@@ -0,0 +1,23 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// SchemeOSKeyStore is the scheme of the Firefox credit card numbers, encrypted by the key of the OS key store
+const SchemeOSKeyStore = "oskeystore"
+
+// DecryptWithOSKeyStore decrypts the base64 encoded value of Firefox OSKeyStore with the 32 bytes key,
+// the 12 bytes nonce prefixes the AES-256-GCM ciphertext.
+// @https://searchfox.org/mozilla-central/source/security/manager/ssl/OSKeyStore.cpp
+func DecryptWithOSKeyStore(key []byte, encoded string) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode os key store value: %w", err)
+	}
+	if len(ciphertext) < nonceSize {
+		return nil, ErrCiphertextLengthIsInvalid
+	}
+	return AESGCMDecrypt(key, ciphertext[:nonceSize], ciphertext[nonceSize:])
+}
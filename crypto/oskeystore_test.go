@@ -0,0 +1,29 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecryptWithOSKeyStore(t *testing.T) {
+	key := bytes.Repeat([]byte{'k'}, 32)
+	nonce := bytes.Repeat([]byte{'n'}, nonceSize)
+	encrypted, err := AESGCMEncrypt(key, nonce, []byte("4111111111111111"))
+	require.NoError(t, err)
+	encoded := base64.StdEncoding.EncodeToString(append(nonce, encrypted...))
+
+	decrypted, err := DecryptWithOSKeyStore(key, encoded)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("4111111111111111"), decrypted)
+
+	_, err = DecryptWithOSKeyStore(bytes.Repeat([]byte{'w'}, 32), encoded)
+	assert.Error(t, err)
+	_, err = DecryptWithOSKeyStore(key, base64.StdEncoding.EncodeToString(nonce[:4]))
+	assert.ErrorIs(t, err, ErrCiphertextLengthIsInvalid)
+	_, err = DecryptWithOSKeyStore(key, "not base64!")
+	assert.Error(t, err)
+}
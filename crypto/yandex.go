@@ -0,0 +1,74 @@
+package crypto
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SchemeYandex is the scheme of the Yandex passwords and cards, encrypted by the data key of the profile
+const SchemeYandex = "yandex"
+
+var (
+	ErrYandexDataKeyInvalid = errors.New("yandex local encryptor data key is invalid")
+	ErrYandexMasterPassword = errors.New("yandex profile is protected by a master password")
+)
+
+// yandexDataKeySignature prefixes the decrypted local encryptor data,
+// it's the protobuf header of the 32 bytes data key.
+var yandexDataKeySignature = []byte{0x08, 0x01, 0x12, 0x20}
+
+const (
+	queryYandexLocalEncryptor = `SELECT value FROM meta WHERE key = 'local_encryptor_data'`
+	queryYandexSealedKey      = `SELECT sealed_key FROM active_keys`
+)
+
+// YandexDataKey returns the data key of Ya Passman Data and Ya Credit Cards, the profile protected by a
+// master password keeps a sealed key in active_keys, which isn't supported.
+func YandexDataKey(db *sql.DB, keys MasterKeys) ([]byte, error) {
+	var sealedKey []byte
+	// active_keys is missing in the profiles before the master password was introduced
+	if err := db.QueryRow(queryYandexSealedKey).Scan(&sealedKey); err == nil && len(sealedKey) > 0 {
+		return nil, ErrYandexMasterPassword
+	}
+	var localEncryptorData []byte
+	if err := db.QueryRow(queryYandexLocalEncryptor).Scan(&localEncryptorData); err != nil {
+		return nil, fmt.Errorf("query yandex local encryptor data: %w", err)
+	}
+	return unsealYandexDataKey(keys, localEncryptorData)
+}
+
+// unsealYandexDataKey unseals the data key from the local_encryptor_data of the meta table,
+// which holds the key encrypted by the browser master key like a Chromium v10 value.
+func unsealYandexDataKey(keys MasterKeys, localEncryptorData []byte) ([]byte, error) {
+	i := bytes.Index(localEncryptorData, []byte(SchemeV10))
+	if i < 0 {
+		return nil, fmt.Errorf("%w: no %s ciphertext", ErrYandexDataKeyInvalid, SchemeV10)
+	}
+	// the 68 bytes of the sealed key are encrypted with AES-256-GCM on Windows, with AES-128-CBC on Linux and macOS
+	size := len(SchemeV10) + nonceSize + 68 + 16
+	if len(keys.V10) != 32 {
+		size = len(SchemeV10) + 80
+	}
+	if len(localEncryptorData) < i+size {
+		return nil, fmt.Errorf("%w: %v", ErrYandexDataKeyInvalid, ErrCiphertextLengthIsInvalid)
+	}
+	decrypted, err := DecryptWithChromium(keys, localEncryptorData[i:i+size])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrYandexDataKeyInvalid, err)
+	}
+	if !bytes.HasPrefix(decrypted, yandexDataKeySignature) || len(decrypted) < len(yandexDataKeySignature)+32 {
+		return nil, fmt.Errorf("%w: bad signature", ErrYandexDataKeyInvalid)
+	}
+	return decrypted[len(yandexDataKeySignature) : len(yandexDataKeySignature)+32], nil
+}
+
+// DecryptWithYandex decrypts the value with AES-GCM by the data key of the profile, the 12 bytes nonce
+// prefixes the ciphertext, and the additional data binds the value to its record.
+func DecryptWithYandex(dataKey, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < nonceSize {
+		return nil, ErrCiphertextLengthIsInvalid
+	}
+	return AESGCMDecryptWithAAD(dataKey, ciphertext[:nonceSize], ciphertext[nonceSize:], additionalData)
+}
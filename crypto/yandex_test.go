@@ -0,0 +1,58 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newYandexLocalEncryptorData seals the data key with the 32 bytes master key of Windows.
+func newYandexLocalEncryptorData(t *testing.T, masterKey, dataKey []byte) []byte {
+	t.Helper()
+	nonce := bytes.Repeat([]byte{'n'}, nonceSize)
+	plaintext := append(append([]byte{}, yandexDataKeySignature...), dataKey...)
+	plaintext = append(plaintext, make([]byte, 68-len(plaintext))...)
+	encrypted, err := AESGCMEncrypt(masterKey, nonce, plaintext)
+	require.NoError(t, err)
+	data := []byte("\x0a\x63")
+	data = append(data, SchemeV10...)
+	data = append(data, nonce...)
+	return append(data, encrypted...)
+}
+
+func TestUnsealYandexDataKey(t *testing.T) {
+	masterKey := bytes.Repeat([]byte{'m'}, 32)
+	dataKey := bytes.Repeat([]byte{'d'}, 32)
+	data := newYandexLocalEncryptorData(t, masterKey, dataKey)
+
+	key, err := unsealYandexDataKey(MasterKeys{V10: masterKey}, data)
+	require.NoError(t, err)
+	assert.Equal(t, dataKey, key)
+
+	_, err = unsealYandexDataKey(MasterKeys{V10: bytes.Repeat([]byte{'w'}, 32)}, data)
+	assert.ErrorIs(t, err, ErrYandexDataKeyInvalid)
+	_, err = unsealYandexDataKey(MasterKeys{V10: masterKey}, []byte("no ciphertext"))
+	assert.ErrorIs(t, err, ErrYandexDataKeyInvalid)
+}
+
+func TestDecryptWithYandex(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{'d'}, 32)
+	nonce := bytes.Repeat([]byte{'n'}, nonceSize)
+	aad := []byte("1b4bdc5c-8a3d-4a1c-a1b4-5f2e0e6c2f3a")
+	block, err := aes.NewCipher(dataKey)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	sealed := gcm.Seal(append([]byte{}, nonce...), nonce, plainText, aad)
+
+	decrypted, err := DecryptWithYandex(dataKey, sealed, aad)
+	require.NoError(t, err)
+	assert.Equal(t, plainText, decrypted)
+
+	_, err = DecryptWithYandex(dataKey, sealed, []byte("another record"))
+	assert.Error(t, err)
+}
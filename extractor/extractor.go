@@ -1,8 +1,13 @@
 package extractor
 
+import (
+	"github.com/moond4rk/hackbrowserdata/crypto"
+)
+
 // Extractor is an interface for extracting data from browser data files
 type Extractor interface {
-	Extract(masterKey []byte) error
+	// Extract parses the copied data file at path, keys decrypt the sensitive values
+	Extract(keys crypto.MasterKeys, path string) error
 
 	Name() string
 
@@ -0,0 +1,262 @@
+// Package hackbrowserdata exposes the browser data extraction as a Go library.
+// Extract picks the browsers installed on the current machine, decrypts their
+// data and returns the typed records instead of writing them to files.
+package hackbrowserdata
+
+import (
+	"context"
+	"errors"
+
+	"github.com/moond4rk/hackbrowserdata/browser"
+	"github.com/moond4rk/hackbrowserdata/browserdata/address"
+	"github.com/moond4rk/hackbrowserdata/browserdata/autofill"
+	"github.com/moond4rk/hackbrowserdata/browserdata/bookmark"
+	"github.com/moond4rk/hackbrowserdata/browserdata/cookie"
+	"github.com/moond4rk/hackbrowserdata/browserdata/creditcard"
+	"github.com/moond4rk/hackbrowserdata/browserdata/download"
+	"github.com/moond4rk/hackbrowserdata/browserdata/extension"
+	"github.com/moond4rk/hackbrowserdata/browserdata/history"
+	"github.com/moond4rk/hackbrowserdata/browserdata/indexeddb"
+	"github.com/moond4rk/hackbrowserdata/browserdata/localstorage"
+	"github.com/moond4rk/hackbrowserdata/browserdata/password"
+	"github.com/moond4rk/hackbrowserdata/browserdata/search"
+	"github.com/moond4rk/hackbrowserdata/browserdata/session"
+	"github.com/moond4rk/hackbrowserdata/browserdata/sessionstorage"
+	"github.com/moond4rk/hackbrowserdata/extractor"
+	"github.com/moond4rk/hackbrowserdata/log"
+	"github.com/moond4rk/hackbrowserdata/workspace"
+)
+
+// Options configures which browsers Extract reads and what data it returns.
+type Options struct {
+	// Browser is the browser name, one of browser.ListBrowsers, "all" or empty picks every browser.
+	Browser string
+	// ProfilePath is a custom profile dir path, empty uses the default path of the browser.
+	ProfilePath string
+	// SensitiveOnly only extracts passwords, cookies and credit cards.
+	SensitiveOnly bool
+
+	// MasterKey is the hex or base64 encoded Chromium master key, set one of MasterKey,
+	// MasterKeyFile and SafeStoragePassword to analyze a profile copied from another machine.
+	MasterKey string
+	// MasterKeyFile is a file containing the raw, hex or base64 encoded Chromium master key.
+	MasterKeyFile string
+	// SafeStoragePassword is the Chromium Safe Storage password the master key is derived from.
+	SafeStoragePassword string
+	// SafeStorageOS is the OS of the copied profile, linux or darwin, it's the OS of this host if empty.
+	SafeStorageOS string
+	// PasswordStore is the Linux key store holding the Chromium Safe Storage password,
+	// one of basic, gnome-libsecret, kwallet, kwallet5 and kwallet6, detected if empty.
+	PasswordStore string
+	// KeyringFile is a Linux GNOME keyring file, eg: ~/.local/share/keyrings/login.keyring,
+	// the Safe Storage password is read from it offline with KeyringPassword, the login password.
+	KeyringFile     string
+	KeyringPassword string
+	// DPAPIMasterKeyDir is the Protect/<SID> dir of a Windows user, the master key of a copied Windows
+	// profile is decrypted offline with the DPAPI master key files in it and one of DPAPIPassword,
+	// DPAPINTHash (hex encoded NTLM hash) and DPAPIBackupKeyFile (domain backup key in PVK format).
+	DPAPIMasterKeyDir  string
+	DPAPIPassword      string
+	DPAPINTHash        string
+	DPAPIBackupKeyFile string
+	// KeychainFile is a macOS login.keychain-db, the Safe Storage password of a copied macOS profile
+	// is read from it offline with KeychainPassword, the login password.
+	KeychainFile     string
+	KeychainPassword string
+
+	// FirefoxPassword is the Firefox primary (master) password, empty if the profile doesn't set one.
+	FirefoxPassword string
+	// FirefoxKeyStoreKey is the hex or base64 encoded key of the OS key store encrypting the Firefox
+	// credit card numbers, it's read from the Secret Service on Linux if empty.
+	FirefoxKeyStoreKey string
+
+	// StorageValueLimit is the max length in bytes of a localStorage value kept in the result, eg:
+	// localstorage.DefaultMaxValueLength, a longer value is replaced by a note and exported to
+	// StorageValueDir if it's set, 0 means no limit.
+	StorageValueLimit int
+	StorageValueDir   string
+}
+
+// Result holds the extracted data of every browser profile.
+type Result struct {
+	Browsers []*BrowserResult
+}
+
+// BrowserResult holds the records extracted from a single browser profile.
+type BrowserResult struct {
+	// Name is the browser and profile name, eg: chrome_default, firefox-xxxxxxxx.default-release
+	Name           string
+	Passwords      []password.LoginData
+	Cookies        []cookie.Cookie
+	Bookmarks      []bookmark.Bookmark
+	Histories      []history.History
+	Visits         []history.Visit
+	Downloads      []download.Download
+	CreditCards    []creditcard.Card
+	Addresses      []address.Address
+	FormEntries    []autofill.Entry
+	SearchEngines  []search.Engine
+	SearchTerms    []search.Term
+	LocalStorage   []localstorage.Storage
+	SessionStorage []sessionstorage.Session
+	IndexedDB      []indexeddb.Entry
+	Sessions       []session.Entry
+	Extensions     []extension.Extension
+}
+
+var ErrNoBrowserFound = errors.New("no browser found")
+
+// Extract extracts the browsing data of the browsers matched by opts.
+// A browser which fails to extract is logged and skipped, the context is
+// checked before each browser so a canceled context stops the extraction,
+// the result of the browsers extracted before is returned with the error.
+// Browser files are copied into a private per-run workspace which is removed
+// before Extract returns, use signal.NotifyContext to stop on SIGINT/SIGTERM.
+func Extract(ctx context.Context, opts Options) (*Result, error) {
+	name := opts.Browser
+	if name == "" {
+		name = "all"
+	}
+	browsers, err := browser.PickBrowsers(browser.Options{
+		Name:                name,
+		ProfilePath:         opts.ProfilePath,
+		MasterKey:           opts.MasterKey,
+		MasterKeyFile:       opts.MasterKeyFile,
+		SafeStoragePassword: opts.SafeStoragePassword,
+		SafeStorageOS:       opts.SafeStorageOS,
+		PasswordStore:       opts.PasswordStore,
+		KeyringFile:         opts.KeyringFile,
+		KeyringPassword:     opts.KeyringPassword,
+		DPAPIMasterKeyDir:   opts.DPAPIMasterKeyDir,
+		DPAPIPassword:       opts.DPAPIPassword,
+		DPAPINTHash:         opts.DPAPINTHash,
+		DPAPIBackupKeyFile:  opts.DPAPIBackupKeyFile,
+		KeychainFile:        opts.KeychainFile,
+		KeychainPassword:    opts.KeychainPassword,
+		FirefoxPassword:     opts.FirefoxPassword,
+		FirefoxKeyStoreKey:  opts.FirefoxKeyStoreKey,
+		StorageValueLimit:   opts.StorageValueLimit,
+		StorageValueDir:     opts.StorageValueDir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(browsers) == 0 {
+		return nil, ErrNoBrowserFound
+	}
+
+	ws, err := workspace.New()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := ws.Remove(); err != nil {
+			log.Errorf("remove workspace %s error %v", ws.Root(), err)
+		}
+	}()
+
+	result := &Result{}
+	for _, b := range browsers {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		workDir, err := ws.Dir(b.Name())
+		if err != nil {
+			log.Errorf("create workspace dir of %s error %v", b.Name(), err)
+			continue
+		}
+		data, err := b.BrowsingData(!opts.SensitiveOnly, workDir)
+		if err != nil {
+			log.Errorf("get browsing data of %s error %v", b.Name(), err)
+			continue
+		}
+		br := &BrowserResult{Name: b.Name()}
+		for _, source := range data.Extractors() {
+			br.add(source)
+		}
+		result.Browsers = append(result.Browsers, br)
+	}
+	return result, nil
+}
+
+// add appends the records of the extractor to the matching category.
+func (r *BrowserResult) add(source extractor.Extractor) {
+	switch s := source.(type) {
+	case *password.ChromiumPassword:
+		r.Passwords = append(r.Passwords, *s...)
+	case *password.YandexPassword:
+		r.Passwords = append(r.Passwords, *s...)
+	case *password.FirefoxPassword:
+		r.Passwords = append(r.Passwords, *s...)
+	case *password.FirefoxLegacyPassword:
+		r.Passwords = append(r.Passwords, *s...)
+	case *cookie.ChromiumCookie:
+		r.Cookies = append(r.Cookies, *s...)
+	case *cookie.FirefoxCookie:
+		r.Cookies = append(r.Cookies, *s...)
+	case *cookie.FirefoxSessionCookie:
+		r.Cookies = append(r.Cookies, *s...)
+	case *bookmark.ChromiumBookmark:
+		r.Bookmarks = append(r.Bookmarks, *s...)
+	case *bookmark.FirefoxBookmark:
+		r.Bookmarks = append(r.Bookmarks, *s...)
+	case *history.ChromiumHistory:
+		r.Histories = append(r.Histories, *s...)
+	case *history.FirefoxHistory:
+		r.Histories = append(r.Histories, *s...)
+	case *history.ChromiumVisit:
+		r.Visits = append(r.Visits, *s...)
+	case *history.FirefoxVisit:
+		r.Visits = append(r.Visits, *s...)
+	case *download.ChromiumDownload:
+		r.Downloads = append(r.Downloads, *s...)
+	case *download.FirefoxDownload:
+		r.Downloads = append(r.Downloads, *s...)
+	case *creditcard.ChromiumCreditCard:
+		r.CreditCards = append(r.CreditCards, *s...)
+	case *creditcard.YandexCreditCard:
+		r.CreditCards = append(r.CreditCards, *s...)
+	case *creditcard.FirefoxCreditCard:
+		r.CreditCards = append(r.CreditCards, *s...)
+	case *address.FirefoxAddress:
+		r.Addresses = append(r.Addresses, *s...)
+	case *address.ChromiumAddress:
+		r.Addresses = append(r.Addresses, *s...)
+	case *autofill.ChromiumAutofill:
+		r.FormEntries = append(r.FormEntries, *s...)
+	case *autofill.FirefoxFormHistory:
+		r.FormEntries = append(r.FormEntries, *s...)
+	case *search.ChromiumSearchEngine:
+		r.SearchEngines = append(r.SearchEngines, *s...)
+	case *search.FirefoxSearchEngine:
+		r.SearchEngines = append(r.SearchEngines, *s...)
+	case *search.ChromiumSearchTerm:
+		r.SearchTerms = append(r.SearchTerms, *s...)
+	case *localstorage.ChromiumLocalStorage:
+		r.LocalStorage = append(r.LocalStorage, *s...)
+	case *localstorage.FirefoxLocalStorage:
+		r.LocalStorage = append(r.LocalStorage, *s...)
+	case *sessionstorage.ChromiumSessionStorage:
+		r.SessionStorage = append(r.SessionStorage, *s...)
+	case *sessionstorage.FirefoxSessionStorage:
+		r.SessionStorage = append(r.SessionStorage, *s...)
+	case *indexeddb.ChromiumIndexedDB:
+		r.IndexedDB = append(r.IndexedDB, *s...)
+	case *indexeddb.FirefoxIndexedDB:
+		r.IndexedDB = append(r.IndexedDB, *s...)
+	case *session.ChromiumSession:
+		r.Sessions = append(r.Sessions, *s...)
+	case *session.FirefoxSession:
+		r.Sessions = append(r.Sessions, *s...)
+	case *extension.ChromiumExtension:
+		for _, e := range *s {
+			r.Extensions = append(r.Extensions, *e)
+		}
+	case *extension.FirefoxExtension:
+		for _, e := range *s {
+			r.Extensions = append(r.Extensions, *e)
+		}
+	default:
+		log.Debugf("unknown extractor %s", source.Name())
+	}
+}
@@ -0,0 +1,65 @@
+package hackbrowserdata
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moond4rk/hackbrowserdata/browserdata/cookie"
+	"github.com/moond4rk/hackbrowserdata/browserdata/extension"
+	"github.com/moond4rk/hackbrowserdata/browserdata/password"
+)
+
+func TestBrowserResult_Add(t *testing.T) {
+	t.Parallel()
+	chromiumPassword := password.ChromiumPassword{{UserName: "chromium"}}
+	firefoxPassword := password.FirefoxPassword{{UserName: "firefox"}}
+	chromiumCookie := cookie.ChromiumCookie{{Host: "github.com"}}
+	chromiumExtension := extension.ChromiumExtension{{ID: "extension"}}
+
+	r := &BrowserResult{}
+	r.add(&chromiumPassword)
+	r.add(&firefoxPassword)
+	r.add(&chromiumCookie)
+	r.add(&chromiumExtension)
+
+	assert.Len(t, r.Passwords, 2)
+	assert.Equal(t, "chromium", r.Passwords[0].UserName)
+	assert.Equal(t, "firefox", r.Passwords[1].UserName)
+	assert.Len(t, r.Cookies, 1)
+	assert.Equal(t, "github.com", r.Cookies[0].Host)
+	assert.Len(t, r.Extensions, 1)
+	assert.Equal(t, "extension", r.Extensions[0].ID)
+	assert.Empty(t, r.Histories)
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+	profile := filepath.Join(t.TempDir(), "User Data", "Default")
+	require.NoError(t, os.MkdirAll(profile, 0o700))
+	bookmarks := `{"roots":{"bookmark_bar":{"type":"folder","children":[
+		{"id":"1","type":"url","name":"GitHub","url":"https://github.com","date_added":"13300000000000000"}]}}}`
+	require.NoError(t, os.WriteFile(filepath.Join(profile, "Bookmarks"), []byte(bookmarks), 0o600))
+	opts := Options{Browser: "chrome", ProfilePath: profile, MasterKey: "000102030405060708090a0b0c0d0e0f"}
+
+	result, err := Extract(context.Background(), opts)
+	require.NoError(t, err)
+	require.Len(t, result.Browsers, 1)
+	require.Len(t, result.Browsers[0].Bookmarks, 2)
+	assert.Equal(t, "https://github.com", result.Browsers[0].Bookmarks[0].URL)
+
+	// a canceled context stops before the first browser and still returns the result
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err = Extract(ctx, opts)
+	assert.ErrorIs(t, err, context.Canceled)
+	require.NotNil(t, result)
+	assert.Empty(t, result.Browsers)
+
+	_, err = Extract(context.Background(), Options{Browser: "unknown"})
+	assert.ErrorIs(t, err, ErrNoBrowserFound)
+}
@@ -0,0 +1,7 @@
+// Package keystore reads the secrets which the browsers keep in the key stores of the OS.
+package keystore
+
+import "errors"
+
+// ErrSecretNotFound is returned if no item of the key store holds the secret
+var ErrSecretNotFound = errors.New("secret not found in key store")
@@ -0,0 +1,56 @@
+//go:build linux
+
+package keystore
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	keyring "github.com/ppacher/go-dbus-keyring"
+
+	"github.com/moond4rk/hackbrowserdata/log"
+)
+
+// SecretServiceSecret reads the secret of the item labeled label from the freedesktop Secret Service,
+// eg: GNOME Keyring and KWallet 5.97+
+// what is d-bus @https://dbus.freedesktop.org/
+func SecretServiceSecret(conn *dbus.Conn, label string) ([]byte, error) {
+	svc, err := keyring.GetSecretService(conn)
+	if err != nil {
+		return nil, err
+	}
+	session, err := svc.OpenSession()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := session.Close(); err != nil {
+			log.Errorf("close dbus session error: %v", err)
+		}
+	}()
+	collections, err := svc.GetAllCollections()
+	if err != nil {
+		return nil, err
+	}
+	for _, col := range collections {
+		items, err := col.GetAllItems()
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range items {
+			itemLabel, err := i.GetLabel()
+			if err != nil {
+				log.Warnf("get label from dbus: %v", err)
+				continue
+			}
+			if itemLabel == label {
+				se, err := i.GetSecret(session.Path())
+				if err != nil {
+					return nil, fmt.Errorf("get %s from dbus: %w", label, err)
+				}
+				return se.Value, nil
+			}
+		}
+	}
+	return nil, ErrSecretNotFound
+}
@@ -0,0 +1,31 @@
+// Package testutil holds the fixtures shared by the tests of the browser data.
+package testutil
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	// import sqlite3 driver
+	_ "modernc.org/sqlite"
+)
+
+// NewSQLite creates the SQLite database at path if it's missing and executes the statements on it,
+// eg: the CREATE TABLE and INSERT statements of a Chromium History
+func NewSQLite(t *testing.T, path string, stmts ...string) {
+	t.Helper()
+	for _, stmt := range stmts {
+		ExecSQLite(t, path, stmt)
+	}
+}
+
+// ExecSQLite executes the statement with its arguments on the SQLite database at path,
+// eg: the INSERT of an encrypted value
+func ExecSQLite(t *testing.T, path, stmt string, args ...any) {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(stmt, args...)
+	require.NoError(t, err)
+}
@@ -2,7 +2,6 @@ package types
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 )
 
@@ -19,12 +18,21 @@ const (
 	ChromiumLocalStorage
 	ChromiumSessionStorage
 	ChromiumExtension
+	ChromiumSession
+	ChromiumIndexedDB
+	ChromiumVisit
+	ChromiumAutofill
+	ChromiumAddress
+	ChromiumSearchEngine
+	ChromiumSearchTerm
 
 	YandexPassword
 	YandexCreditCard
 
 	FirefoxKey4
+	FirefoxKey3
 	FirefoxPassword
+	FirefoxLegacyPassword
 	FirefoxCookie
 	FirefoxBookmark
 	FirefoxHistory
@@ -33,6 +41,13 @@ const (
 	FirefoxLocalStorage
 	FirefoxSessionStorage
 	FirefoxExtension
+	FirefoxAddress
+	FirefoxSession
+	FirefoxSessionCookie
+	FirefoxIndexedDB
+	FirefoxVisit
+	FirefoxFormHistory
+	FirefoxSearchEngine
 )
 
 var itemFileNames = map[DataType]string{
@@ -43,21 +58,37 @@ var itemFileNames = map[DataType]string{
 	ChromiumDownload:       fileChromiumDownload,
 	ChromiumLocalStorage:   fileChromiumLocalStorage,
 	ChromiumSessionStorage: fileChromiumSessionStorage,
-	ChromiumCreditCard:     fileChromiumCredit,
+	ChromiumCreditCard:     FileChromiumWebData,
 	ChromiumExtension:      fileChromiumExtension,
+	ChromiumSession:        fileChromiumSession,
+	ChromiumIndexedDB:      fileChromiumIndexedDB,
+	ChromiumVisit:          fileChromiumHistory,
+	ChromiumAutofill:       FileChromiumWebData,
+	ChromiumAddress:        FileChromiumWebData,
+	ChromiumSearchEngine:   FileChromiumWebData,
+	ChromiumSearchTerm:     fileChromiumHistory,
 	ChromiumHistory:        fileChromiumHistory,
 	YandexPassword:         fileYandexPassword,
 	YandexCreditCard:       fileYandexCredit,
 	FirefoxKey4:            fileFirefoxKey4,
+	FirefoxKey3:            fileFirefoxKey3,
 	FirefoxPassword:        fileFirefoxPassword,
+	FirefoxLegacyPassword:  fileFirefoxLegacyPassword,
 	FirefoxCookie:          fileFirefoxCookie,
 	FirefoxBookmark:        fileFirefoxData,
 	FirefoxDownload:        fileFirefoxData,
 	FirefoxLocalStorage:    fileFirefoxLocalStorage,
 	FirefoxHistory:         fileFirefoxData,
 	FirefoxExtension:       fileFirefoxExtension,
-	FirefoxSessionStorage:  UnsupportedItem,
-	FirefoxCreditCard:      UnsupportedItem,
+	FirefoxSessionStorage:  fileFirefoxSessionStore,
+	FirefoxCreditCard:      fileFirefoxAutofill,
+	FirefoxAddress:         fileFirefoxAutofill,
+	FirefoxSession:         fileFirefoxSessionStore,
+	FirefoxSessionCookie:   fileFirefoxSessionStore,
+	FirefoxIndexedDB:       fileFirefoxStorage,
+	FirefoxVisit:           fileFirefoxData,
+	FirefoxFormHistory:     fileFirefoxFormHistory,
+	FirefoxSearchEngine:    fileFirefoxSearch,
 }
 
 func (i DataType) String() string {
@@ -82,14 +113,32 @@ func (i DataType) String() string {
 		return "ChromiumSessionStorage"
 	case ChromiumExtension:
 		return "ChromiumExtension"
+	case ChromiumSession:
+		return "ChromiumSession"
+	case ChromiumIndexedDB:
+		return "ChromiumIndexedDB"
+	case ChromiumVisit:
+		return "ChromiumVisit"
+	case ChromiumAutofill:
+		return "ChromiumAutofill"
+	case ChromiumAddress:
+		return "ChromiumAddress"
+	case ChromiumSearchEngine:
+		return "ChromiumSearchEngine"
+	case ChromiumSearchTerm:
+		return "ChromiumSearchTerm"
 	case YandexPassword:
 		return "YandexPassword"
 	case YandexCreditCard:
 		return "YandexCreditCard"
 	case FirefoxKey4:
 		return "FirefoxKey4"
+	case FirefoxKey3:
+		return "FirefoxKey3"
 	case FirefoxPassword:
 		return "FirefoxPassword"
+	case FirefoxLegacyPassword:
+		return "FirefoxLegacyPassword"
 	case FirefoxCookie:
 		return "FirefoxCookie"
 	case FirefoxBookmark:
@@ -106,6 +155,20 @@ func (i DataType) String() string {
 		return "FirefoxSessionStorage"
 	case FirefoxExtension:
 		return "FirefoxExtension"
+	case FirefoxAddress:
+		return "FirefoxAddress"
+	case FirefoxSession:
+		return "FirefoxSession"
+	case FirefoxSessionCookie:
+		return "FirefoxSessionCookie"
+	case FirefoxIndexedDB:
+		return "FirefoxIndexedDB"
+	case FirefoxVisit:
+		return "FirefoxVisit"
+	case FirefoxFormHistory:
+		return "FirefoxFormHistory"
+	case FirefoxSearchEngine:
+		return "FirefoxSearchEngine"
 	default:
 		return "UnsupportedItem"
 	}
@@ -120,12 +183,27 @@ func (i DataType) Filename() string {
 	return UnsupportedItem
 }
 
-// TempFilename returns the temp filename for the item with suffix
-// eg: chromiumKey_0.temp
+// the secondary files of the items kept in more than one file of the profile,
+// eg: the passwords of the Google account are kept in Login Data For Account
+var itemSecondaryFileNames = map[DataType][]string{
+	ChromiumPassword: {fileChromiumAccountPassword},
+}
+
+// Filenames returns the filename of the item and its secondary files
+func (i DataType) Filenames() []string {
+	return append([]string{i.Filename()}, itemSecondaryFileNames[i]...)
+}
+
+// HasSecondaryFiles returns whether the item is kept in more than one file
+func (i DataType) HasSecondaryFiles() bool {
+	return len(itemSecondaryFileNames[i]) > 0
+}
+
+// TempFilename returns the filename of the item's copy in the workspace with suffix
+// eg: Local State_0.temp, leveldb_7.temp
 func (i DataType) TempFilename() string {
 	const tempSuffix = "temp"
-	tempFile := fmt.Sprintf("%s_%d.%s", i.Filename(), i, tempSuffix)
-	return filepath.Join(os.TempDir(), tempFile)
+	return fmt.Sprintf("%s_%d.%s", filepath.Base(i.Filename()), i, tempSuffix)
 }
 
 // IsSensitive returns whether the item is sensitive data
@@ -133,7 +211,7 @@ func (i DataType) TempFilename() string {
 func (i DataType) IsSensitive() bool {
 	switch i {
 	case ChromiumKey, ChromiumCookie, ChromiumPassword, ChromiumCreditCard,
-		FirefoxKey4, FirefoxPassword, FirefoxCookie, FirefoxCreditCard,
+		FirefoxKey4, FirefoxKey3, FirefoxPassword, FirefoxLegacyPassword, FirefoxCookie, FirefoxSessionCookie, FirefoxCreditCard,
 		YandexPassword, YandexCreditCard:
 		return true
 	default:
@@ -155,14 +233,23 @@ func FilterSensitiveItems(items []DataType) []DataType {
 // DefaultFirefoxTypes returns the default items for the firefox browser
 var DefaultFirefoxTypes = []DataType{
 	FirefoxKey4,
+	FirefoxKey3,
 	FirefoxPassword,
+	FirefoxLegacyPassword,
 	FirefoxCookie,
 	FirefoxBookmark,
 	FirefoxHistory,
+	FirefoxVisit,
 	FirefoxDownload,
 	FirefoxCreditCard,
+	FirefoxAddress,
+	FirefoxFormHistory,
+	FirefoxSearchEngine,
 	FirefoxLocalStorage,
 	FirefoxSessionStorage,
+	FirefoxSession,
+	FirefoxSessionCookie,
+	FirefoxIndexedDB,
 	FirefoxExtension,
 }
 
@@ -177,6 +264,13 @@ var DefaultYandexTypes = []DataType{
 	YandexPassword,
 	ChromiumLocalStorage,
 	ChromiumSessionStorage,
+	ChromiumSession,
+	ChromiumIndexedDB,
+	ChromiumVisit,
+	ChromiumAutofill,
+	ChromiumAddress,
+	ChromiumSearchEngine,
+	ChromiumSearchTerm,
 	YandexCreditCard,
 }
 
@@ -192,30 +286,53 @@ var DefaultChromiumTypes = []DataType{
 	ChromiumLocalStorage,
 	ChromiumSessionStorage,
 	ChromiumExtension,
+	ChromiumSession,
+	ChromiumIndexedDB,
+	ChromiumVisit,
+	ChromiumAutofill,
+	ChromiumAddress,
+	ChromiumSearchEngine,
+	ChromiumSearchTerm,
 }
 
+// the files of the Chromium profile shared by several items, Web Data keeps the credit cards, addresses,
+// autofill and search engines, and the Preferences next to it keeps the default search engine
+const (
+	FileChromiumWebData     = "Web Data"
+	FileChromiumPreferences = "Preferences"
+)
+
 // item's default filename
 const (
-	fileChromiumKey            = "Local State"
-	fileChromiumCredit         = "Web Data"
-	fileChromiumPassword       = "Login Data"
-	fileChromiumHistory        = "History"
-	fileChromiumDownload       = "History"
-	fileChromiumCookie         = "Cookies"
-	fileChromiumBookmark       = "Bookmarks"
-	fileChromiumLocalStorage   = "Local Storage/leveldb"
-	fileChromiumSessionStorage = "Session Storage"
-	fileChromiumExtension      = "Secure Preferences" // TODO: add more extension files and folders, eg: Preferences
+	fileChromiumKey             = "Local State"
+	fileChromiumPassword        = "Login Data"
+	fileChromiumAccountPassword = "Login Data For Account"
+	fileChromiumHistory         = "History"
+	fileChromiumDownload        = "History"
+	fileChromiumCookie          = "Cookies"
+	fileChromiumBookmark        = "Bookmarks"
+	fileChromiumLocalStorage    = "Local Storage/leveldb"
+	fileChromiumSessionStorage  = "Session Storage"
+	fileChromiumExtension       = "Secure Preferences" // TODO: add more extension files and folders, eg: Preferences
+	fileChromiumSession         = "Sessions"
+	fileChromiumIndexedDB       = "IndexedDB"
 
 	fileYandexPassword = "Ya Passman Data"
 	fileYandexCredit   = "Ya Credit Cards"
 
-	fileFirefoxKey4         = "key4.db"
-	fileFirefoxCookie       = "cookies.sqlite"
-	fileFirefoxPassword     = "logins.json"
-	fileFirefoxData         = "places.sqlite"
-	fileFirefoxLocalStorage = "webappsstore.sqlite"
-	fileFirefoxExtension    = "extensions.json"
+	fileFirefoxKey4           = "key4.db"
+	fileFirefoxKey3           = "key3.db"
+	fileFirefoxCookie         = "cookies.sqlite"
+	fileFirefoxPassword       = "logins.json"
+	fileFirefoxLegacyPassword = "signons.sqlite"
+	fileFirefoxData           = "places.sqlite"
+	fileFirefoxLocalStorage   = "webappsstore.sqlite"
+	fileFirefoxExtension      = "extensions.json"
+	fileFirefoxAutofill       = "autofill-profiles.json"
+	fileFirefoxFormHistory    = "formhistory.sqlite"
+	fileFirefoxSearch         = "search.json.mozlz4"
+	fileFirefoxSessionStore   = "sessionstore.jsonlz4"
+	fileFirefoxStorage        = "storage"
 
 	UnsupportedItem = "unsupported item"
 )
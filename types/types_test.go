@@ -2,7 +2,7 @@ package types
 
 import (
 	"fmt"
-	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 
@@ -21,6 +21,13 @@ func TestDataType_FileName(t *testing.T) {
 	}
 }
 
+func TestDataType_Filenames(t *testing.T) {
+	assert.Equal(t, []string{"Login Data", "Login Data For Account"}, ChromiumPassword.Filenames())
+	assert.True(t, ChromiumPassword.HasSecondaryFiles())
+	assert.Equal(t, []string{"Cookies"}, ChromiumCookie.Filenames())
+	assert.False(t, ChromiumCookie.HasSecondaryFiles())
+}
+
 func TestDataType_TempFilename(t *testing.T) {
 	asserts := assert.New(t)
 
@@ -30,18 +37,18 @@ func TestDataType_TempFilename(t *testing.T) {
 	}{
 		{ChromiumKey, "Local State"},
 		{ChromiumPassword, "Login Data"},
-		{ChromiumLocalStorage, "Local Storage/leveldb"},
-		{FirefoxSessionStorage, "unsupported item"},
+		{ChromiumLocalStorage, "leveldb"},
+		{FirefoxSessionStorage, "sessionstore.jsonlz4"},
 		{FirefoxLocalStorage, "webappsstore.sqlite"},
 		{YandexPassword, "Ya Passman Data"},
 		{YandexCreditCard, "Ya Credit Cards"},
 	}
 
 	for _, tc := range testCases {
-		expectedPrefix := tc.expected + "_" + strconv.Itoa(int(tc.item)) + ".temp"
-		actualPath := tc.item.TempFilename()
-		asserts.Contains(actualPath, expectedPrefix, "TempFilename should contain the correct prefix for "+tc.expected)
-		asserts.Contains(actualPath, os.TempDir(), "TempFilename should be in the system temp directory for "+tc.expected)
+		expected := tc.expected + "_" + strconv.Itoa(int(tc.item)) + ".temp"
+		actual := tc.item.TempFilename()
+		asserts.Equal(expected, actual, "TempFilename should be the base filename with suffix for "+tc.item.String())
+		asserts.Equal(filepath.Base(actual), actual, "TempFilename should not contain a directory for "+tc.item.String())
 	}
 }
 
@@ -95,11 +102,15 @@ func (i DataType) filename() string {
 		return fileChromiumLocalStorage
 	case ChromiumSessionStorage:
 		return fileChromiumSessionStorage
-	case ChromiumCreditCard:
-		return fileChromiumCredit
+	case ChromiumCreditCard, ChromiumAutofill, ChromiumAddress, ChromiumSearchEngine:
+		return FileChromiumWebData
 	case ChromiumExtension:
 		return fileChromiumExtension
-	case ChromiumHistory:
+	case ChromiumSession:
+		return fileChromiumSession
+	case ChromiumIndexedDB:
+		return fileChromiumIndexedDB
+	case ChromiumHistory, ChromiumVisit, ChromiumSearchTerm:
 		return fileChromiumHistory
 	case YandexPassword:
 		return fileYandexPassword
@@ -107,8 +118,12 @@ func (i DataType) filename() string {
 		return fileYandexCredit
 	case FirefoxKey4:
 		return fileFirefoxKey4
+	case FirefoxKey3:
+		return fileFirefoxKey3
 	case FirefoxPassword:
 		return fileFirefoxPassword
+	case FirefoxLegacyPassword:
+		return fileFirefoxLegacyPassword
 	case FirefoxCookie:
 		return fileFirefoxCookie
 	case FirefoxBookmark:
@@ -117,12 +132,20 @@ func (i DataType) filename() string {
 		return fileFirefoxData
 	case FirefoxLocalStorage:
 		return fileFirefoxLocalStorage
-	case FirefoxHistory:
+	case FirefoxSessionStorage, FirefoxSession, FirefoxSessionCookie:
+		return fileFirefoxSessionStore
+	case FirefoxIndexedDB:
+		return fileFirefoxStorage
+	case FirefoxHistory, FirefoxVisit:
 		return fileFirefoxData
 	case FirefoxExtension:
 		return fileFirefoxExtension
-	case FirefoxCreditCard:
-		return UnsupportedItem
+	case FirefoxFormHistory:
+		return fileFirefoxFormHistory
+	case FirefoxSearchEngine:
+		return fileFirefoxSearch
+	case FirefoxCreditCard, FirefoxAddress:
+		return fileFirefoxAutofill
 	default:
 		return UnsupportedItem
 	}
@@ -42,10 +42,15 @@ func ReadFile(filename string) (string, error) {
 }
 
 // CopyDir copies the directory from the source to the destination
-// skip the file if you don't want to copy
-func CopyDir(src, dst, skip string) error {
+// skip the files and dirs with any of the suffixes if you don't want to copy
+func CopyDir(src, dst string, skip ...string) error {
 	s := cp.Options{Skip: func(info os.FileInfo, src, dst string) (bool, error) {
-		return strings.HasSuffix(strings.ToLower(src), skip), nil
+		for _, suffix := range skip {
+			if strings.HasSuffix(strings.ToLower(src), suffix) {
+				return true, nil
+			}
+		}
+		return false, nil
 	}}
 	return cp.Copy(src, dst, s)
 }
@@ -157,3 +162,48 @@ func writeFile(buffer *bytes.Buffer, filename string) error {
 
 	return nil
 }
+
+// DecodeOriginDir decodes the origin from the directory name of the Firefox quota manager, which replaces
+// the ':' and '/' of the origin with '+', and keeps the origin attributes after '^'.
+// eg: https+++github.com+8443^userContextId=1 -> https://github.com:8443^userContextId=1
+func DecodeOriginDir(name string) string {
+	name, suffix, hasSuffix := strings.Cut(name, "^")
+	if hasSuffix {
+		suffix = "^" + suffix
+	}
+	scheme, rest, ok := strings.Cut(name, "+++")
+	if !ok {
+		return name + suffix
+	}
+	var host, port string
+	if strings.HasPrefix(rest, "[") {
+		// the colons of an IPv6 host are replaced as well, eg: [++1]+8080
+		if end := strings.Index(rest, "]"); end > 0 {
+			host = strings.ReplaceAll(rest[:end+1], "+", ":")
+			rest = rest[end+1:]
+		}
+	}
+	parts := strings.Split(rest, "+")
+	host += parts[0]
+	parts = parts[1:]
+	if n := len(parts); n > 0 && isPort(parts[n-1]) && scheme != "file" {
+		port = ":" + parts[n-1]
+		parts = parts[:n-1]
+	}
+	for _, p := range parts {
+		host += "/" + p
+	}
+	return scheme + "://" + host + port + suffix
+}
+
+func isPort(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -50,3 +50,18 @@ func TestCompressDir(t *testing.T) {
 		assert.Error(t, err, "should return an error for an empty directory")
 	})
 }
+
+func TestDecodeOriginDir(t *testing.T) {
+	testCases := map[string]string{
+		"https+++github.com":                      "https://github.com",
+		"https+++github.com+8443":                 "https://github.com:8443",
+		"https+++mail.google.com^userContextId=1": "https://mail.google.com^userContextId=1",
+		"http+++[++1]+8080":                       "http://[::1]:8080",
+		"file++++home+user+index.html":            "file:///home/user/index.html",
+		"moz-extension+++0a1b2c3d-uuid":           "moz-extension://0a1b2c3d-uuid",
+		"chrome":                                  "chrome",
+	}
+	for in, want := range testCases {
+		assert.Equal(t, want, DecodeOriginDir(in), in)
+	}
+}
@@ -0,0 +1,154 @@
+// Package lz4util decodes the LZ4 blocks of the mozLz4 files of Firefox,
+// eg: sessionstore.jsonlz4, search.json.mozlz4
+package lz4util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	ErrInvalidMozLz4 = errors.New("invalid mozlz4 file")
+	ErrCorruptBlock  = errors.New("corrupt lz4 block")
+)
+
+// mozLz4Magic prefixes the mozLz4 files, followed by the uint32 little endian size of the decompressed data.
+var mozLz4Magic = []byte("mozLz40\x00")
+
+// maxMozLz4Size bounds the decompressed size read from the header of a mozLz4 file.
+const maxMozLz4Size = 1 << 30
+
+// maxExpansion is the most bytes an LZ4 block expands a byte to, a length byte of 255 adds 255 bytes
+// of match, and maxExpansionSlack covers the minimum match and the lengths in the tokens.
+const (
+	maxExpansion      = 255
+	maxExpansionSlack = 64
+)
+
+// maxDecompressedSize returns the most bytes a LZ4 block of n bytes decompresses to.
+func maxDecompressedSize(n int) int {
+	return n*maxExpansion + maxExpansionSlack
+}
+
+// DecodeMozLz4 decompresses the mozLz4 file, which is a single LZ4 block with a header.
+// @https://searchfox.org/mozilla-central/source/toolkit/components/lz4/lz4.js
+func DecodeMozLz4(b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, mozLz4Magic) || len(b) < len(mozLz4Magic)+4 {
+		return nil, ErrInvalidMozLz4
+	}
+	size := binary.LittleEndian.Uint32(b[len(mozLz4Magic):])
+	block := b[len(mozLz4Magic)+4:]
+	// the size in the header is checked against the block before it's allocated
+	if size > maxMozLz4Size || int(size) > maxDecompressedSize(len(block)) {
+		return nil, fmt.Errorf("%w: decompressed size %d is too large", ErrInvalidMozLz4, size)
+	}
+	return DecompressBlock(block, int(size))
+}
+
+// DecompressBlock decompresses the LZ4 block into size bytes, the block is a sequence of
+// a token, the literals and a match copied from the output, the last sequence has no match.
+//
+//	| token | literal length+ | literals | offset (2 bytes) | match length+ |
+//
+// @https://github.com/lz4/lz4/blob/dev/doc/lz4_Block_format.md
+func DecompressBlock(src []byte, size int) ([]byte, error) {
+	if size < 0 || size > maxDecompressedSize(len(src)) {
+		return nil, fmt.Errorf("%w: decompressed size %d is out of range of %d bytes", ErrCorruptBlock, size, len(src))
+	}
+	dst := make([]byte, 0, size)
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+
+		literalLen, n, err := sequenceLength(src[i:], int(token>>4))
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		if literalLen > len(src)-i || len(dst)+literalLen > size {
+			return nil, fmt.Errorf("%w: literals out of range at offset %d", ErrCorruptBlock, i)
+		}
+		dst = append(dst, src[i:i+literalLen]...)
+		i += literalLen
+		if i == len(src) {
+			break
+		}
+
+		if i+2 > len(src) {
+			return nil, fmt.Errorf("%w: missing match offset", ErrCorruptBlock)
+		}
+		offset := int(binary.LittleEndian.Uint16(src[i:]))
+		i += 2
+		if offset == 0 || offset > len(dst) {
+			return nil, fmt.Errorf("%w: match offset %d out of range", ErrCorruptBlock, offset)
+		}
+		matchLen, n, err := sequenceLength(src[i:], int(token&0x0f))
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		matchLen += 4
+		if len(dst)+matchLen > size {
+			return nil, fmt.Errorf("%w: match exceeds the decompressed size", ErrCorruptBlock)
+		}
+		// the match may overlap the bytes it produces, so copy it byte by byte
+		start := len(dst) - offset
+		for j := 0; j < matchLen; j++ {
+			dst = append(dst, dst[start+j])
+		}
+	}
+	if len(dst) != size {
+		return nil, fmt.Errorf("%w: decompressed %d bytes, expected %d", ErrCorruptBlock, len(dst), size)
+	}
+	return dst, nil
+}
+
+// sequenceLength returns the length of the literals or match, a length of 15 in the token
+// is followed by bytes added to it until a byte isn't 255, n is the count of the bytes read.
+func sequenceLength(b []byte, length int) (int, int, error) {
+	if length != 15 {
+		return length, 0, nil
+	}
+	n := 0
+	for {
+		if n >= len(b) {
+			return 0, 0, fmt.Errorf("%w: unterminated length", ErrCorruptBlock)
+		}
+		v := b[n]
+		n++
+		length += int(v)
+		if v != 255 {
+			return length, n, nil
+		}
+	}
+}
+
+// ReadMozLz4 reads and decompresses the mozLz4 file.
+func ReadMozLz4(filename string) ([]byte, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeMozLz4(b)
+}
+
+// EncodeMozLz4 wraps the data into a mozLz4 file of a literal only LZ4 block, the data
+// is stored uncompressed, which is still read by Firefox like a compressed file.
+func EncodeMozLz4(data []byte) []byte {
+	b := append([]byte{}, mozLz4Magic...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	if len(data) < 15 {
+		b = append(b, byte(len(data))<<4)
+	} else {
+		b = append(b, 0xf0)
+		n := len(data) - 15
+		for ; n >= 255; n -= 255 {
+			b = append(b, 255)
+		}
+		b = append(b, byte(n))
+	}
+	return append(b, data...)
+}
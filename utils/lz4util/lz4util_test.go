@@ -0,0 +1,87 @@
+package lz4util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBlock is compressed by the lz4 command, it has extended literal and match lengths and an overlapping match.
+const testBlock = "f2027b2277696e646f7773223a5b7b227461620900ff2575726c223a2268747470733a2f2f6769746875622e636f6d2f6d6f6f6e4434726b2f4861636b42726f7773657244617461227d2c3600fffffffaf00261626f75743a626c616e6b227d5d7d5d7d"
+
+func testPlaintext() []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"windows":[{"tabs":[`)
+	for i := 0; i < 20; i++ {
+		b.WriteString(`{"url":"https://github.com/moonD4rk/HackBrowserData"},`)
+	}
+	b.WriteString(`{"url":"about:blank"}]}]}`)
+	return b.Bytes()
+}
+
+func TestDecompressBlock(t *testing.T) {
+	block, err := hex.DecodeString(testBlock)
+	require.NoError(t, err)
+	want := testPlaintext()
+
+	got, err := DecompressBlock(block, len(want))
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	testCases := []struct {
+		name  string
+		block []byte
+		size  int
+	}{
+		{name: "wrong size", block: block, size: len(want) - 1},
+		{name: "truncated", block: block[:len(block)-20], size: len(want)},
+		{name: "offset out of range", block: []byte{0x10, 'a', 0x02, 0x00}, size: 5},
+		{name: "zero offset", block: []byte{0x10, 'a', 0x00, 0x00}, size: 5},
+		{name: "unterminated length", block: []byte{0xf0, 0xff}, size: 300},
+		{name: "size out of range", block: []byte{0x10, 'a'}, size: 1 << 20},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecompressBlock(tc.block, tc.size)
+			assert.ErrorIs(t, err, ErrCorruptBlock)
+		})
+	}
+}
+
+func TestDecodeMozLz4(t *testing.T) {
+	block, err := hex.DecodeString(testBlock)
+	require.NoError(t, err)
+	want := testPlaintext()
+
+	file := append([]byte{}, mozLz4Magic...)
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(want)))
+	file = append(file, block...)
+	got, err := DecodeMozLz4(file)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// a header size far larger than the block can expand to is rejected before it's allocated
+	corrupt := append([]byte{}, mozLz4Magic...)
+	corrupt = binary.LittleEndian.AppendUint32(corrupt, 1<<29)
+	corrupt = append(corrupt, block...)
+	_, err = DecodeMozLz4(corrupt)
+	assert.ErrorIs(t, err, ErrInvalidMozLz4)
+
+	_, err = DecodeMozLz4(block)
+	assert.ErrorIs(t, err, ErrInvalidMozLz4)
+	_, err = DecodeMozLz4(mozLz4Magic)
+	assert.ErrorIs(t, err, ErrInvalidMozLz4)
+}
+
+func TestEncodeMozLz4(t *testing.T) {
+	for _, n := range []int{0, 14, 15, 269, 270, 1000} {
+		data := bytes.Repeat([]byte{'a'}, n)
+		got, err := DecodeMozLz4(EncodeMozLz4(data))
+		require.NoError(t, err)
+		assert.Equal(t, data, got)
+	}
+}
@@ -0,0 +1,93 @@
+// Package workspace manages the private directory that browser files are
+// copied into before they are parsed. Every run gets its own directory which
+// is only accessible by the current user, so concurrent runs never share
+// copies and other local users can't read them.
+package workspace
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/moond4rk/hackbrowserdata/log"
+)
+
+const (
+	rootPattern = "hack-browser-data-"
+	dirPerm     = 0o700
+)
+
+// Workspace is a per-run temporary directory, each browser profile owns a sub directory.
+type Workspace struct {
+	root string
+	once sync.Once
+	err  error
+}
+
+// New creates a workspace in the system temp directory with 0700 permission.
+func New() (*Workspace, error) {
+	root, err := os.MkdirTemp("", rootPattern)
+	if err != nil {
+		return nil, err
+	}
+	// MkdirTemp creates the directory with 0700, chmod again in case of a permissive umask policy
+	if err := os.Chmod(root, dirPerm); err != nil {
+		_ = os.RemoveAll(root)
+		return nil, err
+	}
+	return &Workspace{root: root}, nil
+}
+
+// Root returns the root directory of the workspace.
+func (w *Workspace) Root() string {
+	return w.root
+}
+
+// Dir returns the directory for the browser profile, eg: chrome_default, firefox-xxxx.default-release
+// the directory is created if it doesn't exist.
+func (w *Workspace) Dir(name string) (string, error) {
+	dir := filepath.Join(w.root, filepath.Base(filepath.Clean(name)))
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Remove removes the workspace and all copied files, it is safe to call multiple times.
+func (w *Workspace) Remove() error {
+	w.once.Do(func() {
+		w.err = os.RemoveAll(w.root)
+		if w.err == nil {
+			log.Debugf("remove workspace %s success", w.root)
+		}
+	})
+	return w.err
+}
+
+// RemoveOnSignal removes the workspace and exits the process when it receives
+// SIGINT or SIGTERM. The returned function stops watching the signals.
+func (w *Workspace) RemoveOnSignal() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			if err := w.Remove(); err != nil {
+				log.Errorf("remove workspace %s error: %v", w.root, err)
+			}
+			log.Warnf("received signal %s, workspace removed", sig)
+			os.Exit(1)
+		case <-done:
+		}
+	}()
+	var stopOnce sync.Once
+	return func() {
+		stopOnce.Do(func() {
+			signal.Stop(signals)
+			close(done)
+		})
+	}
+}
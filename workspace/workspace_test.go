@@ -0,0 +1,50 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspace(t *testing.T) {
+	ws, err := New()
+	require.NoError(t, err)
+	defer ws.Remove()
+
+	info, err := os.Stat(ws.Root())
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+	}
+
+	dir, err := ws.Dir("chrome_default")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(ws.Root(), "chrome_default"), dir)
+	assert.DirExists(t, dir)
+
+	// the profile name can't escape the workspace
+	dir, err = ws.Dir("../../chrome_default")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(ws.Root(), "chrome_default"), dir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Cookies_2.temp"), []byte("cookies"), 0o600))
+	assert.NoError(t, ws.Remove())
+	assert.NoDirExists(t, ws.Root())
+	assert.NoError(t, ws.Remove(), "remove should be safe to call multiple times")
+}
+
+func TestWorkspace_Unique(t *testing.T) {
+	ws1, err := New()
+	require.NoError(t, err)
+	defer ws1.Remove()
+	ws2, err := New()
+	require.NoError(t, err)
+	defer ws2.Remove()
+
+	assert.NotEqual(t, ws1.Root(), ws2.Root())
+}